@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// parseAuditTime accepts an RFC 3339 timestamp or a plain date. A plain
+// date used as an upper bound covers the whole day.
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return t, errors.New("must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Microsecond)
+	}
+
+	return t, nil
+}
+
+// auditFilter reads the audit query parameters, reporting every invalid
+// one at once.
+func auditFilter(c *gin.Context) (store.AuditFilter, error) {
+	var filter store.AuditFilter
+	var fields []apierr.FieldError
+	var err error
+
+	invalid := func(name, message string) {
+		fields = append(fields, apierr.FieldError{Field: name, Code: "format", Message: message})
+	}
+
+	if v := c.Query("patient_id"); v != "" {
+		if filter.PatientID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			invalid("patient_id", "must be an integer")
+		}
+	}
+
+	if v := c.Query("user_id"); v != "" {
+		if filter.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			invalid("user_id", "must be an integer")
+		}
+	}
+
+	if v := c.Query("from"); v != "" {
+		if filter.From, err = parseAuditTime(v, false); err != nil {
+			invalid("from", err.Error())
+		}
+	}
+
+	if v := c.Query("to"); v != "" {
+		if filter.To, err = parseAuditTime(v, true); err != nil {
+			invalid("to", err.Error())
+		}
+	}
+
+	if len(fields) > 0 {
+		return filter, apierr.Validation("invalid query parameters", fields...)
+	}
+
+	return filter, nil
+}
+
+func (s *server) getAudit(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	q, err := s.pageQuery(c, false)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	writeOffsetPage(c, q,
+		func() (int64, error) { return s.store.CountAudit(ctx, filter) },
+		func(offset, limit int) ([]model.AuditEntry, error) {
+			return s.store.ListAudit(ctx, filter, offset, limit)
+		})
+}
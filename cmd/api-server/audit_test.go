@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/audit"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func TestAuditTrail(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	requests := []struct {
+		role, method, path string
+	}{
+		// Requests without a valid token are not logged.
+		{"", "GET", "/patients/1"},
+		{auth.RoleNurse, "GET", "/patients/1"},
+		{auth.RoleNurse, "GET", "/records/1"},
+		{auth.RoleNurse, "GET", "/patients"},
+		{auth.RoleNurse, "DELETE", "/patients/2"},
+	}
+	for _, r := range requests {
+		api.do(r.role, r.method, r.path, nil)
+	}
+
+	entries, err := api.server.store.ListAudit(ctx, store.AuditFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type logged struct {
+		username, action, resource string
+		patientID                  int64
+		status                     int
+		outcome                    string
+	}
+	// Newest first.
+	want := []logged{
+		{auth.RoleNurse, audit.ActionDelete, "patient", 2, http.StatusForbidden, audit.OutcomeDenied},
+		{auth.RoleNurse, audit.ActionList, "patient", 0, http.StatusOK, audit.OutcomeSuccess},
+		{auth.RoleNurse, audit.ActionRead, "record", 1, http.StatusOK, audit.OutcomeSuccess},
+		{auth.RoleNurse, audit.ActionRead, "patient", 1, http.StatusOK, audit.OutcomeSuccess},
+	}
+
+	if len(entries) != len(want) {
+		t.Fatalf("audit log = %+v, want %d entries", entries, len(want))
+	}
+	for i, entry := range entries {
+		got := logged{entry.Username, entry.Action, entry.ResourceType, entry.PatientID, entry.Status, entry.Outcome}
+		if got != want[i] || entry.UserID == 0 {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func (s *server) postLogin(c *gin.Context) {
+	var request loginRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	user, err := s.store.GetUserByUsername(c.Request.Context(), request.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	// Unknown usernames are checked against a dummy hash so they take as
+	// long to reject as wrong passwords and cannot be told apart.
+	hash := auth.DummyHash
+	if err == nil {
+		hash = user.PasswordHash
+	}
+
+	if !auth.CheckPassword(hash, request.Password) || err != nil {
+		apierr.Write(c, apierr.Unauthorized(auth.ErrInvalidCredentials.Error()))
+		return
+	}
+
+	pair, err := s.tokens.Issue(user)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, pair)
+}
+
+func (s *server) postRefresh(c *gin.Context) {
+	var request refreshRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	claims, err := s.tokens.Verify(request.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		apierr.Write(c, apierr.Unauthorized(err.Error()))
+		return
+	}
+
+	// Reload the user so deleted accounts cannot keep refreshing.
+	user, err := s.store.GetUser(c.Request.Context(), claims.UserID())
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierr.Write(c, apierr.Unauthorized(auth.ErrInvalidToken.Error()))
+			return
+		}
+
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	pair, err := s.tokens.Issue(user)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, pair)
+}
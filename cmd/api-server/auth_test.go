@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func TestLogin(t *testing.T) {
+	api := newTestAPI(t)
+
+	hash, err := auth.HashPassword("s3cret pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := model.User{Username: "doctor", PasswordHash: hash, Role: auth.RolePhysician}
+	if err := api.server.store.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	pair := decode[auth.TokenPair](t,
+		api.do("", "POST", "/auth/login", map[string]string{"username": "doctor", "password": "s3cret pass"}),
+		http.StatusOK)
+	claims, err := api.server.tokens.Verify(pair.AccessToken, auth.AccessToken)
+	if err != nil || claims.UserID() != user.ID || claims.Role != auth.RolePhysician {
+		t.Errorf("access token claims = %+v, %v, want those of %+v", claims, err, user)
+	}
+
+	wrongPassword := api.do("", "POST", "/auth/login", map[string]string{"username": "doctor", "password": "wrong"})
+	unknownUser := api.do("", "POST", "/auth/login", map[string]string{"username": "nobody", "password": "s3cret pass"})
+
+	if wrongPassword.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d, want 401", wrongPassword.Code)
+	}
+	// Unknown usernames cannot be told apart from wrong passwords.
+	if unknownUser.Code != wrongPassword.Code || unknownUser.Body.String() != wrongPassword.Body.String() {
+		t.Errorf("unknown user answered %d %s, wrong password %d %s",
+			unknownUser.Code, unknownUser.Body, wrongPassword.Code, wrongPassword.Body)
+	}
+
+	missing := decode[problem](t, api.do("", "POST", "/auth/login", map[string]string{"username": "doctor"}), http.StatusBadRequest)
+	if fields := missing.fields(); len(fields) != 1 || fields[0] != "password:required" {
+		t.Errorf("missing password errors = %v", fields)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	api := newTestAPI(t)
+
+	user, err := api.server.store.GetUserByUsername(context.Background(), auth.RoleNurse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := api.server.tokens.Issue(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tokens of accounts that no longer exist.
+	gone, err := api.server.tokens.Issue(model.User{ID: 999, Username: "gone", Role: auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := decode[auth.TokenPair](t,
+		api.do("", "POST", "/auth/refresh", map[string]string{"refresh_token": pair.RefreshToken}),
+		http.StatusOK)
+	if claims, err := api.server.tokens.Verify(refreshed.AccessToken, auth.AccessToken); err != nil || claims.UserID() != user.ID {
+		t.Errorf("refreshed access token claims = %+v, %v", claims, err)
+	}
+
+	tests := []struct {
+		name, token string
+	}{
+		{"access token", pair.AccessToken},
+		{"deleted user", gone.RefreshToken},
+		{"malformed", "garbage"},
+	}
+	for _, tt := range tests {
+		w := api.do("", "POST", "/auth/refresh", map[string]string{"refresh_token": tt.token})
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("refreshing with %s: status = %d, want 401", tt.name, w.Code)
+		}
+	}
+}
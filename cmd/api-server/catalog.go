@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jctorrestone/web-service-mr/internal/catalog"
+	"github.com/jctorrestone/web-service-mr/internal/config"
+)
+
+const catalogUsage = `usage: api-server catalog [flags] import <catalog> <file>
+
+Imports rows of a catalog (diseases, symptoms, exams or medicines) from a
+CSV or JSON file, told apart by their extension. Rows duplicating a row
+of the catalog are skipped; nothing is imported if a row is invalid.
+
+flags:
+`
+
+// runCatalog implements the catalog subcommand.
+func runCatalog(args []string) {
+	flags := flag.NewFlagSet("catalog", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), catalogUsage)
+		flags.PrintDefaults()
+	}
+
+	dryRun := flags.Bool("dry-run", false, "validate the file without importing it")
+
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() != 3 || flags.Arg(0) != "import" || !catalog.Valid(flags.Arg(1)) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if cfg.Store == "memory" {
+		log.Fatal("catalogs cannot be imported into the memory store")
+	}
+
+	path := flags.Arg(2)
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format != catalog.CSV && format != catalog.JSON {
+		log.Fatalf("%s: unknown format, the file must end in .csv or .json", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	result, err := catalog.Import(context.Background(), openStore(cfg), flags.Arg(1), format, file, *dryRun)
+
+	var invalid *catalog.Error
+	if errors.As(err, &invalid) {
+		for _, row := range invalid.Rows {
+			log.Printf("row %d: %s %s", row.Index, row.Field, row.Message)
+		}
+		log.Fatalf("%d invalid rows, nothing was imported", len(invalid.Rows))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	verb := "created"
+	if result.DryRun {
+		verb = "would be created"
+	}
+	log.Printf("read %d rows: %d %s %s, %d duplicates skipped",
+		len(result.Rows), result.Created, result.Catalog, verb, result.Duplicates)
+}
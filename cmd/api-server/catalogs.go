@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func (s *server) getExams(c *gin.Context) {
+	exams, err := s.store.ListExams(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, exams)
+}
+
+func (s *server) getDiseases(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	q, err := s.pageQuery(c, false)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	filter, sort, err := diseaseListing(c, q)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	writeOffsetPage(c, q,
+		func() (int64, error) { return s.store.CountDiseases(ctx, filter) },
+		func(offset, limit int) ([]model.Disease, error) {
+			return s.store.ListDiseases(ctx, filter, sort, offset, limit)
+		})
+}
+
+// postDiseases creates a disease. Its ICD-10 code is optional but must be
+// well formed and unused.
+func (s *server) postDiseases(c *gin.Context) {
+	var disease model.Disease
+
+	if !bindJSON(c, &disease) {
+		return
+	}
+
+	if disease.Code != "" {
+		code, ok := icd10.Normalize(disease.Code)
+		if !ok {
+			apierr.Write(c, apierr.Validation("request has invalid fields",
+				apierr.FieldError{Field: "code", Code: "format", Message: "must be an ICD-10 code, such as J02 or J02.9"}))
+			return
+		}
+		disease.Code = code
+	}
+	disease.Category, disease.Chapter = "", nil
+
+	if err := s.store.CreateDisease(c.Request.Context(), &disease); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			apierr.Write(c, apierr.Conflict("another disease has code "+disease.Code))
+			return
+		}
+		apierr.Write(c, storeError(err, "disease"))
+		return
+	}
+
+	s.search.diseases.put(disease)
+	c.IndentedJSON(http.StatusCreated, disease)
+}
+
+func (s *server) getSymptoms(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	q, err := s.pageQuery(c, false)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	writeOffsetPage(c, q,
+		func() (int64, error) { return s.store.CountSymptoms(ctx, "") },
+		func(offset, limit int) ([]model.Symptom, error) {
+			return s.store.ListSymptoms(ctx, "", offset, limit)
+		})
+}
+
+func (s *server) postSymptoms(c *gin.Context) {
+	var symptom model.Symptom
+
+	if !bindJSON(c, &symptom) {
+		return
+	}
+
+	if err := s.store.CreateSymptom(c.Request.Context(), &symptom); err != nil {
+		apierr.Write(c, storeError(err, "symptom"))
+		return
+	}
+
+	s.search.symptoms.put(symptom)
+	c.IndentedJSON(http.StatusCreated, symptom)
+}
+
+func (s *server) getVitalSigns(c *gin.Context) {
+	vitalSigns, err := s.store.ListVitalSigns(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, vitalSigns)
+}
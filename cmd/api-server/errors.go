@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// storeError translates an error returned by the store into the error
+// reported to the client. what names the resource in messages, e.g.
+// "patient".
+func storeError(err error, what string) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return apierr.NotFound("no such " + what)
+	case errors.Is(err, store.ErrConflict):
+		return apierr.Conflict(what + " conflicts with its current state")
+	case errors.Is(err, store.ErrInvalidReference):
+		return apierr.Validation(what + " has an " + err.Error())
+	}
+
+	return apierr.Internal(err)
+}
+
+// bindJSON decodes the request body into obj, reporting malformed bodies
+// and binding tag violations as a validation problem.
+func bindJSON(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		apierr.Write(c, apierr.Bind(err))
+		return false
+	}
+
+	return true
+}
+
+func noRoute(c *gin.Context) {
+	apierr.Write(c, apierr.NotFound("no such route"))
+}
+
+func recovered(c *gin.Context, value any) {
+	apierr.Write(c, apierr.Internal(fmt.Errorf("panic: %v\n%s", value, debug.Stack())))
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/metrics"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// testAPI is the api-server over a memory store seeded with the demo
+// data, holding an access token for a user of each role.
+type testAPI struct {
+	t      *testing.T
+	server *server
+	router *gin.Engine
+	tokens map[string]string
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	st := store.NewMemory()
+	if err := st.Seed(); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	srv := &server{
+		store:       st,
+		tokens:      auth.NewTokens(secret, time.Hour, time.Hour),
+		cursors:     newCursorCodec(secret),
+		pageSize:    20,
+		maxPageSize: 100,
+		search:      newSearchIndexes(st),
+		metrics:     metrics.New(),
+	}
+
+	registerValidations()
+	router := gin.New()
+	router.NoRoute(noRoute)
+	srv.routes(router)
+
+	api := &testAPI{t: t, server: srv, router: router, tokens: map[string]string{}}
+	for _, role := range []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse, auth.RoleReception} {
+		// Tokens are issued directly; logging in is tested on its own.
+		user := model.User{Username: role, PasswordHash: auth.DummyHash, Role: role}
+		if err := st.CreateUser(context.Background(), &user); err != nil {
+			t.Fatal(err)
+		}
+
+		pair, err := srv.tokens.Issue(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		api.tokens[role] = pair.AccessToken
+	}
+
+	return api
+}
+
+// do sends a request as the demo user of role, or unauthenticated if role
+// is empty. A string body is sent as is, any other non-nil body as JSON.
+func (api *testAPI) do(role, method, target string, body any) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			api.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if role != "" {
+		r.Header.Set("Authorization", "Bearer "+api.tokens[role])
+	}
+
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, r)
+
+	return w
+}
+
+// decode decodes the JSON body of w into a T, failing the test unless w
+// has status.
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+
+	var v T
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+
+	return v
+}
+
+// problem is the part of a problem response the tests check.
+type problem struct {
+	Status int `json:"status"`
+	Errors []struct {
+		Field string `json:"field"`
+		Code  string `json:"code"`
+	} `json:"errors"`
+}
+
+// fields returns the fields of the errors of p, as "field:code".
+func (p problem) fields() []string {
+	var fields []string
+	for _, e := range p.Errors {
+		fields = append(fields, e.Field+":"+e.Code)
+	}
+
+	return fields
+}
+
+// searchItems returns the items of a search response, best first.
+func searchItems[T any](t *testing.T, w *httptest.ResponseRecorder) []T {
+	t.Helper()
+
+	response := decode[struct {
+		Data []struct {
+			Item T `json:"item"`
+		} `json:"data"`
+	}](t, w, http.StatusOK)
+
+	var items []T
+	for _, hit := range response.Data {
+		items = append(items, hit.Item)
+	}
+
+	return items
+}
+
+func TestPatientRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	created := decode[model.Patient](t,
+		api.do(auth.RoleReception, "POST", "/patients", map[string]any{"name": "Rosa", "last_name": "Quispe", "gender": false}),
+		http.StatusCreated)
+	if created.ID == 0 || created.Name != "Rosa" {
+		t.Fatalf("created %+v", created)
+	}
+	path := fmt.Sprintf("/patients/%d", created.ID)
+
+	if got := decode[model.Patient](t, api.do(auth.RoleNurse, "GET", path, nil), http.StatusOK); got != created {
+		t.Errorf("GET = %+v, want %+v", got, created)
+	}
+
+	// The new patient is searchable at once.
+	found := searchItems[model.Patient](t, api.do(auth.RoleNurse, "GET", "/patients/search?q=quis", nil))
+	if len(found) != 1 || found[0].ID != created.ID {
+		t.Errorf("search quis = %+v", found)
+	}
+
+	put := decode[model.Patient](t,
+		api.do(auth.RoleReception, "PUT", path, map[string]any{"name": " Rosa María ", "last_name": "Quispe", "gender": false}),
+		http.StatusOK)
+	if put.Name != "Rosa María" {
+		t.Errorf("PUT name = %q, want it trimmed", put.Name)
+	}
+
+	patched := decode[model.Patient](t, api.do(auth.RoleReception, "PATCH", path, map[string]any{"gender": true}), http.StatusOK)
+	if !patched.Gender || patched.Name != "Rosa María" {
+		t.Errorf("PATCH = %+v, want only the gender changed", patched)
+	}
+
+	// José and Luis are the other male demo patients.
+	page := decode[model.Response](t, api.do(auth.RoleNurse, "GET", "/patients?gender=true", nil), http.StatusOK)
+	if page.Total != 3 {
+		t.Errorf("male patients total = %d, want 3", page.Total)
+	}
+
+	blank := decode[problem](t, api.do(auth.RoleReception, "PATCH", path, map[string]any{"name": " "}), http.StatusBadRequest)
+	if got := strings.Join(blank.fields(), " "); got != "name:blank" {
+		t.Errorf("blank name errors = %s", got)
+	}
+
+	// POST validates its body like PUT.
+	invalid := []struct {
+		name string
+		body map[string]any
+		want string
+	}{
+		{"blank names", map[string]any{"name": " ", "last_name": "\t", "gender": true}, "name:blank last_name:blank"},
+		{"missing fields", map[string]any{"name": "Rosa"}, "last_name:required gender:required"},
+		{"long name", map[string]any{"name": strings.Repeat("a", 101), "last_name": "Quispe", "gender": false}, "name:max"},
+	}
+	for _, tt := range invalid {
+		for _, method := range []string{"POST", "PUT"} {
+			target := "/patients"
+			if method == "PUT" {
+				target = path
+			}
+
+			got := decode[problem](t, api.do(auth.RoleReception, method, target, tt.body), http.StatusBadRequest)
+			if fields := strings.Join(got.fields(), " "); fields != tt.want {
+				t.Errorf("%s with %s: errors = %s, want %s", method, tt.name, fields, tt.want)
+			}
+		}
+	}
+
+	// The first demo patient has medical records.
+	if w := api.do(auth.RoleAdmin, "DELETE", "/patients/1", nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting a patient with records: status = %d, want 409", w.Code)
+	}
+
+	if w := api.do(auth.RoleAdmin, "DELETE", path, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d: %s", w.Code, w.Body)
+	}
+	if w := api.do(auth.RoleNurse, "GET", path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET after DELETE status = %d, want 404", w.Code)
+	}
+	if found := searchItems[model.Patient](t, api.do(auth.RoleNurse, "GET", "/patients/search?q=quis", nil)); len(found) != 0 {
+		t.Errorf("search after DELETE = %+v", found)
+	}
+}
+
+func TestRecordRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	body := map[string]any{
+		"record":      map[string]any{"category": "primary", "patient": map[string]any{"id": 2}, "rdate": "2024-06-01", "age": 40},
+		"symptoms":    []any{map[string]any{"id": 2}},
+		"vital_signs": []any{map[string]any{"vital_sign_id": 1, "value": 39.2}},
+		"idx":         []any{map[string]any{"id": 3}},
+		"treatments":  []any{map[string]any{"medicine_id": 1, "quantity": 10, "dosage": 1, "frequency": 8}},
+	}
+	created := decode[model.Record](t, api.do(auth.RolePhysician, "POST", "/records", body), http.StatusCreated)
+	path := fmt.Sprintf("/records/%d", created.ID)
+
+	record := decode[model.FullRecord](t, api.do(auth.RoleNurse, "GET", path, nil), http.StatusOK)
+	if record.RecordObj.PatientObj.ID != 2 || len(record.Treatments) != 1 || record.RecordObj.Version != 1 {
+		t.Fatalf("GET = %+v", record)
+	}
+	if vs := record.VitalSigns; len(vs) != 1 || vs[0].Flag != "high" {
+		t.Errorf("vital signs = %+v, want the temperature flagged high", vs)
+	}
+
+	// A secondary record belongs to the patient of its primary record.
+	secondary := decode[model.Record](t, api.do(auth.RolePhysician, "POST", "/records", map[string]any{
+		"record": map[string]any{"category": "secondary", "primary_record_id": created.ID, "patient": map[string]any{"id": 3}, "rdate": "2024-06-08"},
+	}), http.StatusCreated)
+	if secondary.PatientObj.ID != 2 {
+		t.Errorf("secondary record patient = %d, want 2", secondary.PatientObj.ID)
+	}
+
+	// Amending the record adds a version, and the first stays readable.
+	body["record"] = map[string]any{"rdate": "2024-06-01", "age": 41, "version": 1}
+	amended := decode[model.FullRecord](t, api.do(auth.RolePhysician, "PUT", path, body), http.StatusOK)
+	if amended.RecordObj.Age != 41 || amended.RecordObj.Version != 2 {
+		t.Errorf("PUT = %+v, want age 41 at version 2", amended.RecordObj)
+	}
+	body["record"] = map[string]any{"rdate": "2024-06-01", "age": 42, "version": 1}
+	if w := api.do(auth.RolePhysician, "PUT", path, body); w.Code != http.StatusConflict {
+		t.Errorf("PUT of a stale version: status = %d, want 409", w.Code)
+	}
+
+	versions := decode[[]model.RecordVersion](t, api.do(auth.RoleNurse, "GET", path+"/versions", nil), http.StatusOK)
+	if len(versions) != 2 {
+		t.Errorf("versions = %+v, want 2", versions)
+	}
+	first := decode[model.FullRecord](t, api.do(auth.RoleNurse, "GET", path+"?version=1", nil), http.StatusOK)
+	if first.RecordObj.Age != 40 {
+		t.Errorf("version 1 age = %d, want 40", first.RecordObj.Age)
+	}
+
+	page := decode[model.Response](t, api.do(auth.RoleNurse, "GET", "/records?patient_id=2", nil), http.StatusOK)
+	if page.Total != 1 {
+		t.Errorf("records of patient 2 total = %d, want 1", page.Total)
+	}
+
+	if w := api.do(auth.RoleNurse, "GET", "/records/999", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET of an unknown record: status = %d, want 404", w.Code)
+	}
+}
+
+func TestCatalogRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	disease := decode[model.Disease](t,
+		api.do(auth.RolePhysician, "POST", "/diseases", map[string]any{"code": "j18.9", "description": "Neumonía"}),
+		http.StatusCreated)
+	if disease.Code != "J18.9" {
+		t.Errorf("code = %q, want it normalized to J18.9", disease.Code)
+	}
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+	}{
+		{"malformed code", map[string]any{"code": "pneumonia", "description": "Neumonía"}, http.StatusBadRequest},
+		{"code in use", map[string]any{"code": "J18.9", "description": "Otra neumonía"}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if w := api.do(auth.RolePhysician, "POST", "/diseases", tt.body); w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+
+	found := searchItems[model.Disease](t, api.do("", "GET", "/diseases/search?q=neumo", nil))
+	if len(found) != 1 || found[0].ID != disease.ID || found[0].Chapter == nil {
+		t.Errorf("search neumo = %+v, want the new disease with its chapter", found)
+	}
+
+	symptom := decode[model.Symptom](t, api.do(auth.RolePhysician, "POST", "/symptoms", map[string]any{"description": "Mareo"}), http.StatusCreated)
+	if found := searchItems[model.Symptom](t, api.do("", "GET", "/symptoms/search?q=mareo", nil)); len(found) != 1 || found[0] != symptom {
+		t.Errorf("search mareo = %+v, want %+v", found, symptom)
+	}
+
+	page := decode[model.Response](t, api.do("", "GET", "/diseases?page_size=2", nil), http.StatusOK)
+	if page.Total != 6 || page.NextPage != 1 {
+		t.Errorf("diseases total, next page = %d, %d, want 6, 1", page.Total, page.NextPage)
+	}
+
+	exams := decode[[]model.Exam](t, api.do("", "GET", "/exams", nil), http.StatusOK)
+	if len(exams) != 4 {
+		t.Errorf("exams = %+v, want the 4 seeded", exams)
+	}
+}
+
+func TestMedicineRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	// Build the search index before adding to it.
+	if found := searchItems[model.Medicine](t, api.do("", "GET", "/medicines/search?q=loratadina", nil)); len(found) != 0 {
+		t.Fatalf("search loratadina = %+v", found)
+	}
+
+	medicine := decode[model.Medicine](t, api.do(auth.RolePhysician, "POST", "/medicines", map[string]any{
+		"formulation": map[string]any{"id": 1}, "name": "Loratadina", "dose": 10,
+	}), http.StatusCreated)
+
+	found := searchItems[model.Medicine](t, api.do("", "GET", "/medicines/search?q=lorat", nil))
+	if len(found) != 1 || found[0].ID != medicine.ID || found[0].FormulationObj.ShapeObj.Description != "Tableta" {
+		t.Errorf("search lorat = %+v, want the new medicine with its formulation", found)
+	}
+
+	if w := api.do(auth.RolePhysician, "POST", "/medicines", map[string]any{
+		"formulation": map[string]any{"id": 99}, "name": "Cetirizina", "dose": 10,
+	}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown formulation: status = %d, want 400", w.Code)
+	}
+
+	// Paracetamol and Ibuprofeno are the other tablets.
+	page := decode[model.Response](t, api.do("", "GET", "/medicines?formulation_id=1", nil), http.StatusOK)
+	if page.Total != 3 {
+		t.Errorf("tablets total = %d, want 3", page.Total)
+	}
+
+	formulations := decode[[]model.Formulation](t, api.do("", "GET", "/formulations", nil), http.StatusOK)
+	if len(formulations) != 3 {
+		t.Errorf("formulations = %+v, want the 3 seeded", formulations)
+	}
+}
+
+// TestImportRoutes searches imported rows, the indexes having been built
+// before the import.
+func TestImportRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	if found := searchItems[model.Symptom](t, api.do("", "GET", "/symptoms/search?q=mareo", nil)); len(found) != 0 {
+		t.Fatalf("search mareo = %+v", found)
+	}
+	if found := searchItems[model.Medicine](t, api.do("", "GET", "/medicines/search?q=loratadina", nil)); len(found) != 0 {
+		t.Fatalf("search loratadina = %+v", found)
+	}
+
+	if w := api.do(auth.RolePhysician, "POST", "/symptoms/import", `[{"description": "Mareo"}]`); w.Code != http.StatusCreated {
+		t.Fatalf("symptoms import status = %d: %s", w.Code, w.Body)
+	}
+	if w := api.do(auth.RolePhysician, "POST", "/medicines/import", `[{"name": "Loratadina", "formulation_id": 1, "dose": 10}]`); w.Code != http.StatusCreated {
+		t.Fatalf("medicines import status = %d: %s", w.Code, w.Body)
+	}
+
+	if found := searchItems[model.Symptom](t, api.do("", "GET", "/symptoms/search?q=mareo", nil)); len(found) != 1 || found[0].Description != "Mareo" {
+		t.Errorf("search mareo after the import = %+v", found)
+	}
+	found := searchItems[model.Medicine](t, api.do("", "GET", "/medicines/search?q=loratadina", nil))
+	if len(found) != 1 || found[0].FormulationObj.ShapeObj.Description != "Tableta" {
+		t.Errorf("search loratadina after the import = %+v, want it with its formulation", found)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+)
+
+// readyTimeout bounds the store check of /readyz, so a hung database
+// fails the probe instead of stalling it.
+const readyTimeout = 2 * time.Second
+
+// health is the body of the probe routes.
+type health struct {
+	Status string `json:"status"`
+}
+
+// getHealthz reports that the process serves requests. It does not check
+// the database: an outage should take the server out of rotation, not get
+// it restarted.
+func (s *server) getHealthz(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, health{Status: "ok"})
+}
+
+// getReadyz reports whether the server can answer requests, i.e. whether
+// the store's connection pool reaches the database.
+func (s *server) getReadyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	if err := s.store.Ping(ctx); err != nil {
+		apierr.Write(c, apierr.Unavailable("the database is unreachable", err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, health{Status: "ready"})
+}
+
+// getMetrics serves the Prometheus metrics of the server. It is public, like
+// the health checks, and left to the proxy to restrict; see the README.
+func (s *server) getMetrics(c *gin.Context) {
+	s.metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+const icd10Usage = `usage: api-server icd10 [flags] import <file>
+
+Imports the diseases of an ICD-10 codes file from the WHO systematic
+tables, such as icd102019syst_codes.txt. Codes that are not in the
+disease catalog yet are added, the others get the title of the file as
+description. Nothing is imported if the file has an error.
+
+flags:
+`
+
+// runICD10 implements the icd10 subcommand.
+func runICD10(args []string) {
+	flags := flag.NewFlagSet("icd10", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), icd10Usage)
+		flags.PrintDefaults()
+	}
+
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() != 2 || flags.Arg(0) != "import" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if cfg.Store == "memory" {
+		log.Fatal("diseases cannot be imported into the memory store")
+	}
+
+	file, err := os.Open(flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	entries, err := icd10.ReadCodes(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diseases := make([]model.Disease, len(entries))
+	for i, entry := range entries {
+		diseases[i] = model.Disease{Code: entry.Code, Description: entry.Title}
+	}
+
+	created, updated, err := openStore(cfg).ImportDiseases(context.Background(), diseases)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("read %d codes: %d diseases created, %d updated", len(entries), created, updated)
+}
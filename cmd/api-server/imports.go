@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/catalog"
+)
+
+// maxImportSize bounds the body of an import request.
+const maxImportSize = 8 << 20
+
+// importCatalog returns the handler importing rows of name in bulk. The
+// body is CSV when sent as text/csv, JSON otherwise, and dry_run=true
+// validates it without creating anything.
+func (s *server) importCatalog(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := listParams{c: c}
+		dryRun := p.bool("dry_run")
+		if err := p.err(); err != nil {
+			apierr.Write(c, err)
+			return
+		}
+
+		// Like the other routes, anything but CSV is read as JSON.
+		format := catalog.JSON
+		if c.ContentType() == "text/csv" {
+			format = catalog.CSV
+		}
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+		result, err := catalog.Import(c.Request.Context(), s.store, name, format, body, dryRun != nil && *dryRun)
+		if err != nil {
+			apierr.Write(c, importError(err))
+			return
+		}
+
+		if result.DryRun || result.Created == 0 {
+			c.IndentedJSON(http.StatusOK, result)
+			return
+		}
+
+		switch name {
+		case catalog.Diseases:
+			s.search.diseases.invalidate()
+		case catalog.Symptoms:
+			s.search.symptoms.invalidate()
+		case catalog.Medicines:
+			s.search.medicines.invalidate()
+		}
+
+		c.IndentedJSON(http.StatusCreated, result)
+	}
+}
+
+// importError maps the errors of catalog.Import to client errors, each
+// invalid row value being reported at its JSON path, e.g. "[3].dose".
+func importError(err error) error {
+	var invalid *catalog.Error
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &invalid):
+		fields := make([]apierr.FieldError, len(invalid.Rows))
+		for i, row := range invalid.Rows {
+			fields[i] = apierr.FieldError{
+				Field:   fmt.Sprintf("[%d].%s", row.Index, row.Field),
+				Code:    row.Code,
+				Message: row.Message,
+			}
+		}
+		return apierr.Validation("import has invalid rows, nothing was imported", fields...)
+	case errors.As(err, &tooLarge):
+		return apierr.Validation(fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+	case errors.Is(err, catalog.ErrFormat):
+		return apierr.Validation(err.Error())
+	}
+
+	return storeError(err, "catalog row")
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// listParams reads the sort and filter parameters of a listing,
+// collecting every invalid one so they are reported together.
+type listParams struct {
+	c      *gin.Context
+	fields []apierr.FieldError
+}
+
+func (p *listParams) reject(name, code, message string) {
+	p.fields = append(p.fields, apierr.FieldError{Field: name, Code: code, Message: message})
+}
+
+func (p *listParams) bool(name string) *bool {
+	v, ok := p.c.GetQuery(name)
+	if !ok {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.reject(name, "format", "must be true or false")
+		return nil
+	}
+
+	return &b
+}
+
+func (p *listParams) id(name string) int64 {
+	v, ok := p.c.GetQuery(name)
+	if !ok {
+		return 0
+	}
+
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		p.reject(name, "format", "must be a positive integer")
+		return 0
+	}
+
+	return id
+}
+
+func (p *listParams) date(name string) string {
+	v, ok := p.c.GetQuery(name)
+	if !ok {
+		return ""
+	}
+
+	if _, err := time.Parse(time.DateOnly, v); err != nil {
+		p.reject(name, "format", "must be a date formatted as YYYY-MM-DD")
+		return ""
+	}
+
+	return v
+}
+
+// oneOf returns the value of the parameter name, which must be one of
+// values, or fallback if it is absent.
+func (p *listParams) oneOf(name, fallback string, values ...string) string {
+	v, ok := p.c.GetQuery(name)
+	if !ok {
+		return fallback
+	}
+
+	if !slices.Contains(values, v) {
+		p.reject(name, "oneof", "must be one of: "+strings.Join(values, " "))
+		return fallback
+	}
+
+	return v
+}
+
+// sort parses sort=field,-field, a leading minus sorting in descending
+// order. Only the fields in allowed are accepted, and cursor pagination
+// always follows the default order.
+func (p *listParams) sort(q pageQuery, allowed []string) []store.SortField {
+	v, ok := p.c.GetQuery("sort")
+	if !ok {
+		return nil
+	}
+
+	if q.Keyset {
+		p.reject("sort", "unsupported", "cannot be combined with cursor pagination")
+		return nil
+	}
+
+	var sort []store.SortField
+
+	for _, name := range strings.Split(v, ",") {
+		field := store.SortField{Field: strings.TrimPrefix(name, "-"), Desc: strings.HasPrefix(name, "-")}
+
+		if !slices.Contains(allowed, field.Field) {
+			p.reject("sort", "oneof", "can only sort by: "+strings.Join(allowed, " "))
+			return nil
+		}
+
+		sort = append(sort, field)
+	}
+
+	return sort
+}
+
+func (p *listParams) err() error {
+	if len(p.fields) > 0 {
+		return apierr.Validation("invalid query parameters", p.fields...)
+	}
+
+	return nil
+}
+
+// patientListing reads the filters and sort of a patient listing.
+// Patients are filtered by gender=true|false.
+func patientListing(c *gin.Context, q pageQuery) (store.PatientFilter, []store.SortField, error) {
+	p := listParams{c: c}
+
+	filter := store.PatientFilter{Gender: p.bool("gender")}
+	sort := p.sort(q, store.PatientSortFields)
+
+	return filter, sort, p.err()
+}
+
+// recordListing reads the filters and sort of a record listing. Records
+// are filtered by category (primary unless given, or all), an inclusive
+// from/to date range, the patient's first_name, last_name or patient_id
+// and a diagnosed disease_id, all of which must match.
+func recordListing(c *gin.Context, q pageQuery, name string) (store.RecordFilter, []store.SortField, error) {
+	p := listParams{c: c}
+
+	filter := store.RecordFilter{
+		Category:    p.oneOf("category", "primary", "primary", "secondary", "all"),
+		From:        p.date("from"),
+		To:          p.date("to"),
+		PatientName: name,
+		FirstName:   c.Query("first_name"),
+		LastName:    c.Query("last_name"),
+		PatientID:   p.id("patient_id"),
+		DiseaseID:   p.id("disease_id"),
+	}
+	if filter.Category == "all" {
+		filter.Category = ""
+	}
+	if filter.From != "" && filter.To != "" && filter.To < filter.From {
+		p.reject("to", "order", "must not be before from")
+	}
+
+	sort := p.sort(q, store.RecordSortFields)
+
+	return filter, sort, p.err()
+}
+
+// medicineListing reads the filters and sort of a medicine listing.
+// Medicines are filtered by formulation_id and shape_id.
+func medicineListing(c *gin.Context, q pageQuery) (store.MedicineFilter, []store.SortField, error) {
+	p := listParams{c: c}
+
+	filter := store.MedicineFilter{
+		FormulationID: p.id("formulation_id"),
+		ShapeID:       p.id("shape_id"),
+	}
+	sort := p.sort(q, store.MedicineSortFields)
+
+	return filter, sort, p.err()
+}
+
+// diseaseListing reads the filters and sort of a disease listing.
+// Diseases are filtered by the start of their ICD-10 code, code_prefix,
+// and the number of their ICD-10 chapter.
+func diseaseListing(c *gin.Context, q pageQuery) (store.DiseaseFilter, []store.SortField, error) {
+	p := listParams{c: c}
+
+	var filter store.DiseaseFilter
+
+	if v, ok := c.GetQuery("code_prefix"); ok {
+		prefix, ok := icd10.Prefix(v)
+		if !ok {
+			p.reject("code_prefix", "format", "must be the start of an ICD-10 code, such as J0 or J02.")
+		}
+		filter.CodePrefix = prefix
+	}
+
+	if v, ok := c.GetQuery("chapter"); ok {
+		number, err := strconv.Atoi(v)
+		if _, ok := icd10.ChapterByNumber(number); err != nil || !ok {
+			p.reject("chapter", "range", "must be an ICD-10 chapter number between 1 and "+strconv.Itoa(len(icd10.Chapters)))
+		}
+		filter.Chapter = number
+	}
+
+	sort := p.sort(q, store.DiseaseSortFields)
+
+	return filter, sort, p.err()
+}
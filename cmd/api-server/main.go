@@ -1,1237 +1,372 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
+	"flag"
+	"io"
 	"log"
-	"math"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-sql-driver/mysql"
+	"github.com/jctorrestone/web-service-mr/internal/audit"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/catalog"
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"github.com/jctorrestone/web-service-mr/internal/logging"
+	"github.com/jctorrestone/web-service-mr/internal/metrics"
 	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+	"github.com/jctorrestone/web-service-mr/internal/tracing"
 )
 
-const N = 10
-
-var db *sql.DB
-
-func main() {
-	connect()
-	router := gin.Default()
-	//GET
-	router.GET("/diseases", getDiseases)
-	router.GET("/diseases/search", getDiseasesByDesc)
-	router.GET("/exams", getExams)
-	router.GET("/formulations", getFormulations)
-	router.GET("/medicines", getMedicines)
-	router.GET("/medicines/search", getMedicinesByDesc)
-	router.GET("/patients", getPatients)
-	router.GET("/patients/:id", getPatientById)
-	router.GET("/patients/search", getPatientsByName)
-	router.GET("/records", getRecords)
-	router.GET("/records/:id", getRecordsById)
-	router.GET("/records/search", getRecordsByPatient)
-	router.GET("/sec-records/:id", getSecRecordsById)
-	router.GET("/symptoms", getSymptoms)
-	router.GET("/symptoms/search", getSymptomsByDesc)
-	router.GET("/vital-signs", getVitalSigns)
-	//POST
-	router.POST("/diseases", postDiseases)
-	router.POST("/medicines", postMedicines)
-	router.POST("/patients", postPatients)
-	router.POST("/records", postRecords)
-	router.POST("/symptoms", postSymptoms)
-
-	router.Run("localhost:8080")
-}
-
-func connect() {
-	// Capture connection properties.
-	cfg := mysql.Config{
-		User:   os.Getenv("DBUSER"),
-		Passwd: os.Getenv("DBPASS"),
-		Net:    "tcp",
-		Addr:   "127.0.0.1:3306",
-		DBName: "medical_records",
-	}
-	// Get a database handle.
-	var err error
-	db, err = sql.Open("mysql", cfg.FormatDSN())
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	pingErr := db.Ping()
-	if pingErr != nil {
-		log.Fatal(pingErr)
-	}
-	log.Println("Connected!")
-}
-
-func getRecordsNum(sql_count string, args ...any) int64 {
-	var total int64
-
-	var row *sql.Row
-	if len(args) != 0 {
-		row = db.QueryRow(sql_count, args...)
-	} else {
-		row = db.QueryRow(sql_count)
-	}
-
-	if err := row.Scan(&total); err != nil {
-		return -1
-	}
-
-	return total
+// server holds the dependencies shared by every handler.
+type server struct {
+	store  store.Store
+	tokens *auth.Tokens
+	// cursors seals the cursors of keyset pagination.
+	cursors *cursorCodec
+	// pageSize is the default page size and maxPageSize the largest one
+	// clients may ask for.
+	pageSize    int
+	maxPageSize int
+	search      searchIndexes
+	metrics     *metrics.Metrics
 }
 
-func getPaginationResponse(sql_count string, page int, args ...any) model.Response {
-	var response model.Response
-
-	response.Page = page
-	response.PrevPage = -1
-	response.NextPage = -1
-	response.Total = getRecordsNum(sql_count, args...)
-	response.LastPage = int(math.Ceil(float64(response.Total)/N) - 1)
-
-	if response.Page < 0 {
-		response.Page = 0
-	} else if response.Page > response.LastPage {
-		response.Page = response.LastPage
-	}
-
-	if response.Page > 0 {
-		response.PrevPage = response.Page - 1
-	}
-
-	if response.Page < response.LastPage {
-		response.NextPage = response.Page + 1
-	}
-
-	return response
-}
-
-func getPatients(c *gin.Context) {
-	var patients []model.Patient
-	sql_count := "SELECT COUNT(id) AS total FROM patient"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page)
-
-	rows, err := db.Query("SELECT * FROM patient ORDER BY last_name ASC LIMIT ?, ?", response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var patient model.Patient
-
-		if err := rows.Scan(
-			&patient.ID, &patient.Name,
-			&patient.Lastname, &patient.Gender); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
 			return
-		}
-
-		patients = append(patients, patient)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	response.Data = patients
-	c.IndentedJSON(http.StatusOK, response)
-}
-
-func getPatientById(c *gin.Context) {
-	var patient model.Patient
-	id := c.Param("id")
-	row := db.QueryRow("SELECT * FROM patient WHERE id = ?", id)
-
-	if err := row.Scan(
-		&patient.ID, &patient.Name,
-		&patient.Lastname, &patient.Gender); err != nil {
-
-		if err == sql.ErrNoRows {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "no such patient"})
+		case "user":
+			runUser(os.Args[2:])
 			return
-		}
-
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	c.IndentedJSON(http.StatusOK, patient)
-}
-
-func getPatientsByName(c *gin.Context) {
-	var patients []model.Patient
-
-	sql_count := "SELECT COUNT(id) AS total FROM patient WHERE name LIKE ? OR last_name LIKE ?"
-	query := c.DefaultQuery("q", "")
-	query = "%" + query + "%"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page, query, query)
-
-	rows, err := db.Query(
-		`SELECT * FROM patient 
-		WHERE name LIKE ? OR last_name LIKE ? 
-		ORDER BY last_name ASC 
-		LIMIT ?, ?`, query, query, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var patient model.Patient
-
-		if err := rows.Scan(
-			&patient.ID, &patient.Name,
-			&patient.Lastname, &patient.Gender); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		case "icd10":
+			runICD10(os.Args[2:])
 			return
-		}
-
-		patients = append(patients, patient)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	response.Data = patients
-	c.IndentedJSON(http.StatusOK, response)
-}
-
-func postPatients(c *gin.Context) {
-	var patient model.Patient
-
-	if err := c.BindJSON(&patient); err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	result, err := db.Exec(
-		"INSERT INTO patient (name, last_name, gender) VALUES (?, ?, ?)",
-		patient.Name, patient.Lastname, patient.Gender)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	id, err := result.LastInsertId()
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	patient.ID = id
-	c.IndentedJSON(http.StatusCreated, patient)
-}
-
-func getExams(c *gin.Context) {
-	var exams []model.Exam
-	rows, err := db.Query("SELECT * FROM exam")
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var exam model.Exam
-
-		if err := rows.Scan(&exam.ID, &exam.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		case "catalog":
+			runCatalog(os.Args[2:])
 			return
-		}
-
-		exams = append(exams, exam)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	c.IndentedJSON(http.StatusOK, exams)
-}
-
-func getDiseases(c *gin.Context) {
-	var diseases []model.Disease
-
-	sql_count := "SELECT COUNT(id) AS total FROM disease"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page)
-
-	rows, err := db.Query("SELECT * FROM disease ORDER BY description ASC LIMIT ?, ?", response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var disease model.Disease
-
-		if err := rows.Scan(&disease.ID, &disease.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		case "openapi":
+			runOpenAPI(os.Args[2:])
 			return
 		}
-
-		diseases = append(diseases, disease)
 	}
 
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	response.Data = diseases
-	c.IndentedJSON(http.StatusOK, response)
-}
-
-func postDiseases(c *gin.Context) {
-	var disease model.Disease
-
-	if err := c.BindJSON(&disease); err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	result, err := db.Exec(
-		"INSERT INTO disease (description) VALUES (?)",
-		disease.Description)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	id, err := result.LastInsertId()
-
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	disease.ID = id
-	c.IndentedJSON(http.StatusCreated, disease)
-}
-
-func getDiseasesByDesc(c *gin.Context) {
-	var diseases []model.Disease
-
-	sql_count := "SELECT COUNT(id) AS total FROM disease WHERE description LIKE ?"
-	query := c.DefaultQuery("q", "")
-	query = "%" + query + "%"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page, query)
-
-	rows, err := db.Query("SELECT * FROM disease WHERE description LIKE ? ORDER BY description ASC LIMIT ?, ?", query, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var disease model.Disease
-
-		if err := rows.Scan(&disease.ID, &disease.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		diseases = append(diseases, disease)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+		log.Fatal(err)
 	}
 
-	response.Data = diseases
-	c.IndentedJSON(http.StatusOK, response)
-}
-
-func getSymptoms(c *gin.Context) {
-	var symptoms []model.Symptom
-
-	sql_count := "SELECT COUNT(id) AS total FROM symptom"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page)
-
-	rows, err := db.Query("SELECT * FROM symptom ORDER BY description ASC LIMIT ?, ?", response.Page*N, N)
-
+	logger, err := logging.New(os.Stderr, cfg.Log)
 	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var symptom model.Symptom
-
-		if err := rows.Scan(&symptom.ID, &symptom.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		symptoms = append(symptoms, symptom)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	response.Data = symptoms
-	c.IndentedJSON(http.StatusOK, response)
-}
-
-func postSymptoms(c *gin.Context) {
-	var symptom model.Symptom
-
-	if err := c.BindJSON(&symptom); err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+		log.Fatal(err)
 	}
-
-	result, err := db.Exec(
-		"INSERT INTO symptom (description) VALUES (?)",
-		symptom.Description)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+	// Lines written with the log package, e.g. by net/http, go through
+	// the same handler.
+	slog.SetDefault(logger)
+	if os.Getenv(gin.EnvGinMode) == "" {
+		// Gin's debug output is not structured.
+		gin.SetMode(gin.ReleaseMode)
 	}
 
-	id, err := result.LastInsertId()
-
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+		fatal("cannot set up tracing", "error", err)
+	}
+
+	secret := authSecret(cfg)
+	st := openStore(cfg)
+	srv := &server{
+		store:       st,
+		tokens:      auth.NewTokens(secret, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL),
+		cursors:     newCursorCodec(secret),
+		pageSize:    cfg.Server.PageSize,
+		maxPageSize: cfg.Server.MaxPageSize,
+		search:      newSearchIndexes(st),
+		metrics:     metrics.New(),
+	}
+	if pool, ok := st.(metrics.Pool); ok {
+		srv.metrics.WatchPool(pool)
+	}
+	registerValidations()
+	router := gin.New()
+	// The request span comes first so log lines carry its trace ID. The
+	// span, request log and metrics come before the recovery so panics
+	// count as 500s; recovered logs the panic itself.
+	router.Use(
+		tracing.Middleware(),
+		logging.Middleware(logger),
+		srv.metrics.Middleware(),
+		gin.CustomRecoveryWithWriter(io.Discard, recovered),
+	)
+	router.NoRoute(noRoute)
+	srv.routes(router)
+
+	serve(cfg.Server, router, st)
+
+	// Export the spans of the last requests.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("exporting the last spans", "error", err)
 	}
-
-	symptom.ID = id
-	c.IndentedJSON(http.StatusCreated, symptom)
 }
 
-func getSymptomsByDesc(c *gin.Context) {
-	var symptoms []model.Symptom
-
-	sql_count := "SELECT COUNT(id) AS total FROM symptom WHERE description LIKE ?"
-	query := c.DefaultQuery("q", "")
-	query = "%" + query + "%"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page, query)
-
-	rows, err := db.Query("SELECT * FROM symptom WHERE description LIKE ? ORDER BY description ASC LIMIT ?, ?", query, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+// serve answers requests with handler until SIGINT or SIGTERM, then stops
+// accepting connections, waits up to cfg.ShutdownTimeout for the requests
+// in progress, such as record transactions, and closes st.
+func serve(cfg config.Server, handler http.Handler, st store.Store) {
+	httpServer := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		var symptom model.Symptom
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		if err := rows.Scan(&symptom.ID, &symptom.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
+	failed := make(chan error, 1)
+	go func() {
+		var err error
+		if tls := cfg.TLS; tls.Enabled() {
+			err = httpServer.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+		} else {
+			err = httpServer.ListenAndServe()
 		}
+		failed <- err
+	}()
+	slog.Info("listening", "addr", cfg.Addr, "tls", cfg.TLS.Enabled())
 
-		symptoms = append(symptoms, symptom)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+	select {
+	case err := <-failed:
+		st.Close()
+		fatal("server failed", "error", err)
+	case <-ctx.Done():
 	}
+	// A second signal kills the server without waiting.
+	stop()
 
-	response.Data = symptoms
-	c.IndentedJSON(http.StatusOK, response)
-}
+	slog.Info("shutting down, waiting for requests in progress", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-func getVitalSigns(c *gin.Context) {
-	var vital_signs []model.VitalSign
-	rows, err := db.Query(
-		`SELECT vs.id, u.id, u.symbol, u.description, vs.description 
-		FROM vital_sign AS vs 
-		INNER JOIN unit AS u
-		ON unit_id = u.id 
-		ORDER BY vs.description ASC`)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("requests cut by the shutdown timeout", "error", err)
 	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var vital_sign model.VitalSign
-
-		if err := rows.Scan(
-			&vital_sign.ID, &vital_sign.UnitObj.ID,
-			&vital_sign.UnitObj.Symbol, &vital_sign.UnitObj.Description,
-			&vital_sign.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		vital_signs = append(vital_signs, vital_sign)
+	if err := <-failed; !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server failed", "error", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+	if err := st.Close(); err != nil {
+		slog.Error("closing the store", "error", err)
 	}
-
-	c.IndentedJSON(http.StatusOK, vital_signs)
+	slog.Info("stopped")
 }
 
-func getFormulations(c *gin.Context) {
-	var formulations []model.Formulation
-	rows, err := db.Query(
-		`SELECT f.id, s.id, s.description, u.id, u.symbol, u.description 
-		FROM formulation AS f
-		INNER JOIN shape AS s 
-		ON shape_id = s.id 
-		INNER JOIN unit AS u 
-		ON unit_id = u.id 
-		ORDER BY s.description ASC`)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var formulation model.Formulation
-
-		if err := rows.Scan(
-			&formulation.ID, &formulation.ShapeObj.ID,
-			&formulation.ShapeObj.Description,
-			&formulation.UnitObj.ID, &formulation.UnitObj.Symbol,
-			&formulation.UnitObj.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		formulations = append(formulations, formulation)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	c.IndentedJSON(http.StatusOK, formulations)
+// fatal logs msg at the error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
-func getMedicines(c *gin.Context) {
-	var medicines []model.Medicine
-
-	sql_count := "SELECT COUNT(id) AS total FROM medicine"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page)
-
-	rows, err := db.Query(
-		`SELECT m.id, f.id, s.id, s.description, u.id, u.symbol, u.description, m.name, m.dose 
-		FROM medicine AS m 
-		INNER JOIN formulation AS f 
-		ON formulation_id = f.id 
-		INNER JOIN shape AS s 
-		ON shape_id = s.id 
-		INNER JOIN unit AS u 
-		ON unit_id = u.id 
-		ORDER BY m.name ASC 
-		LIMIT ?, ?`, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var medicine model.Medicine
-
-		if err := rows.Scan(
-			&medicine.ID, &medicine.FormulationObj.ID,
-			&medicine.FormulationObj.ShapeObj.ID,
-			&medicine.FormulationObj.ShapeObj.Description,
-			&medicine.FormulationObj.UnitObj.ID,
-			&medicine.FormulationObj.UnitObj.Symbol,
-			&medicine.FormulationObj.UnitObj.Description,
-			&medicine.Name, &medicine.Dose); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		medicines = append(medicines, medicine)
-	}
+func (s *server) routes(router *gin.Engine) {
+	//AUTH
+	router.POST("/auth/login", s.postLogin)
+	router.POST("/auth/refresh", s.postRefresh)
 
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
+	// Patient, record and catalog write routes need an authenticated
+	// user whose role grants the route's permission. Every authenticated
+	// access to patient and record data, allowed or not, is audited.
+	protected := router.Group("/", auth.Middleware(s.tokens))
+	clinical := router.Group("/", auth.Middleware(s.tokens), audit.Middleware(s.store))
+	can := auth.Require
 
-	response.Data = medicines
-	c.IndentedJSON(http.StatusOK, response)
+	//GET
+	router.GET("/diseases", s.getDiseases)
+	router.GET("/diseases/search", s.getDiseasesByDesc)
+	router.GET("/exams", s.getExams)
+	router.GET("/formulations", s.getFormulations)
+	router.GET("/medicines", s.getMedicines)
+	router.GET("/medicines/search", s.getMedicinesByDesc)
+	clinical.GET("/patients", can(auth.PatientsRead), s.getPatients)
+	clinical.GET("/patients/:id", can(auth.PatientsRead), s.getPatientById)
+	clinical.GET("/patients/search", can(auth.PatientsRead), s.getPatientsByName)
+	clinical.GET("/records", can(auth.RecordsRead), s.getRecords)
+	clinical.GET("/records/:id", can(auth.RecordsRead), s.getRecordsById)
+	clinical.GET("/records/search", can(auth.RecordsRead), s.getRecordsByPatient)
+	clinical.GET("/records/:id/versions", can(auth.RecordsRead), s.getRecordVersions)
+	clinical.GET("/sec-records/:id", can(auth.RecordsRead), s.getSecRecordsById)
+	router.GET("/symptoms", s.getSymptoms)
+	router.GET("/symptoms/search", s.getSymptomsByDesc)
+	router.GET("/vital-signs", s.getVitalSigns)
+	//POST
+	protected.POST("/diseases", can(auth.CatalogsWrite), s.postDiseases)
+	protected.POST("/diseases/import", can(auth.CatalogsWrite), s.importCatalog(catalog.Diseases))
+	protected.POST("/exams/import", can(auth.CatalogsWrite), s.importCatalog(catalog.Exams))
+	protected.POST("/medicines", can(auth.CatalogsWrite), s.postMedicines)
+	protected.POST("/medicines/import", can(auth.CatalogsWrite), s.importCatalog(catalog.Medicines))
+	clinical.POST("/patients", can(auth.PatientsWrite), s.postPatients)
+	// postRecords checks the finer grained record permissions itself.
+	clinical.POST("/records", can(auth.VitalSignsWrite, auth.RecordsWrite, auth.Diagnose), s.postRecords)
+	protected.POST("/symptoms", can(auth.CatalogsWrite), s.postSymptoms)
+	protected.POST("/symptoms/import", can(auth.CatalogsWrite), s.importCatalog(catalog.Symptoms))
+	//PUT
+	clinical.PUT("/patients/:id", can(auth.PatientsWrite), s.putPatientById)
+	// putRecordById checks the permissions of the sections it changes.
+	clinical.PUT("/records/:id", can(auth.VitalSignsWrite, auth.RecordsWrite, auth.Diagnose), s.putRecordById)
+	protected.PUT("/vital-signs/:id/range", can(auth.CatalogsWrite), s.putVitalSignRange)
+	//PATCH
+	clinical.PATCH("/patients/:id", can(auth.PatientsWrite), s.patchPatientById)
+	//DELETE
+	clinical.DELETE("/patients/:id", can(auth.PatientsDelete), s.deletePatientById)
+	protected.DELETE("/vital-signs/:id/range", can(auth.CatalogsWrite), s.deleteVitalSignRange)
+	//AUDIT
+	protected.GET("/audit", can(auth.AuditRead), s.getAudit)
+	//HEALTH
+	router.GET("/healthz", s.getHealthz)
+	router.GET("/readyz", s.getReadyz)
+	router.GET("/metrics", s.getMetrics)
+	//DOCS
+	router.GET("/openapi.json", s.getOpenAPI)
+	router.GET("/docs", s.getDocs)
+	router.GET("/docs/redoc.standalone.js", s.getDocsScript)
 }
 
-func postMedicines(c *gin.Context) {
-	var medicine model.Medicine
-
-	if err := c.BindJSON(&medicine); err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+// openStore returns the Store selected in the configuration. The memory
+// backend is seeded with demo data so it can be used without a database.
+func openStore(cfg config.Config) store.Store {
+	switch cfg.Store {
+	case "mysql":
+		return store.NewMySQL(openDB(cfg))
+	case "sqlite":
+		return store.NewSQLite(openDB(cfg))
 	}
 
-	result, err := db.Exec(
-		"INSERT INTO medicine (formulation_id, name, dose) VALUES (?, ?, ?)",
-		medicine.FormulationObj.ID, medicine.Name, medicine.Dose)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+	memory := store.NewMemory()
+	if err := memory.Seed(); err != nil {
+		fatal("cannot seed the memory store", "error", err)
 	}
 
-	id, err := result.LastInsertId()
-
+	hash, err := auth.HashPassword("demo")
 	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+		fatal("cannot seed the memory store", "error", err)
 	}
-
-	medicine.ID = id
-	c.IndentedJSON(http.StatusCreated, medicine)
-}
-
-func getMedicinesByDesc(c *gin.Context) {
-	var medicines []model.Medicine
-
-	sql_count := "SELECT COUNT(id) AS total FROM medicine WHERE name LIKE ?"
-	query := c.DefaultQuery("q", "")
-	query = "%" + query + "%"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page, query)
-
-	rows, err := db.Query(
-		`SELECT m.id, f.id, s.id, s.description, u.id, u.symbol, u.description, m.name, m.dose 
-		FROM medicine AS m 
-		INNER JOIN formulation AS f 
-		ON formulation_id = f.id 
-		INNER JOIN shape AS s 
-		ON shape_id = s.id 
-		INNER JOIN unit AS u 
-		ON unit_id = u.id 
-		WHERE m.name LIKE ? 
-		ORDER BY m.name ASC 
-		LIMIT ?, ?`, query, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var medicine model.Medicine
-
-		if err := rows.Scan(
-			&medicine.ID, &medicine.FormulationObj.ID,
-			&medicine.FormulationObj.ShapeObj.ID,
-			&medicine.FormulationObj.ShapeObj.Description,
-			&medicine.FormulationObj.UnitObj.ID,
-			&medicine.FormulationObj.UnitObj.Symbol,
-			&medicine.FormulationObj.UnitObj.Description,
-			&medicine.Name, &medicine.Dose); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
+	// One demo account per role, named after it.
+	for _, role := range []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse, auth.RoleReception} {
+		user := model.User{Username: role, PasswordHash: hash, Role: role}
+		if err := memory.CreateUser(context.Background(), &user); err != nil {
+			fatal("cannot seed the memory store", "error", err)
 		}
-
-		medicines = append(medicines, medicine)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
 	}
+	slog.Info("using the in-memory store with demo data", "users", "admin, physician, nurse and reception", "password", "demo")
 
-	response.Data = medicines
-	c.IndentedJSON(http.StatusOK, response)
+	return memory
 }
 
-func getRecords(c *gin.Context) {
-	var records []model.Record
-
-	sql_count := "SELECT COUNT(id) AS total FROM record WHERE category='primary'"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page)
+// authSecret returns the secret signing tokens and sealing page cursors.
+// Only the memory store may run without a configured secret; it gets a
+// random one, so tokens and cursors do not survive a restart.
+func authSecret(cfg config.Config) []byte {
+	secret := []byte(cfg.Auth.Secret)
 
-	rows, err := db.Query(
-		`SELECT r.id, p.id, p.name, p.last_name, r.rdate, rd.duration 
-		FROM record AS r 
-		INNER JOIN record_description AS rd 
-		ON r.id = rd.record_id 
-		INNER JOIN patient AS p
-		ON rd.patient_id = p.id 
-		WHERE r.category='primary'
-		ORDER BY r.rdate DESC  
-		LIMIT ?, ?`, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var record model.Record
-
-		if err := rows.Scan(
-			&record.ID, &record.PatientObj.ID,
-			&record.PatientObj.Name, &record.PatientObj.Lastname,
-			&record.Date, &record.Duration); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
+	if len(secret) == 0 {
+		if cfg.Store != "memory" {
+			fatal("auth.secret (MR_AUTH_SECRET) is required")
 		}
 
-		records = append(records, record)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	response.Data = records
-	c.IndentedJSON(http.StatusOK, response)
-}
-
-func getRecordsByPatient(c *gin.Context) {
-	var records []model.Record
-
-	sql_count := `SELECT COUNT(r.id) AS total 
-		FROM record AS r 
-		INNER JOIN record_description AS rd 
-		ON r.id = rd.record_id 
-		INNER JOIN patient AS p 
-		ON rd.patient_id = p.id 
-		WHERE r.category = 'primary' AND p.name LIKE ? OR p.last_name LIKE ?`
-
-	query := c.DefaultQuery("q", "")
-	query = "%" + query + "%"
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
-
-	response := getPaginationResponse(sql_count, page, query, query)
-
-	rows, err := db.Query(
-		`SELECT r.id, p.id, p.name, p.last_name, r.rdate, rd.duration 
-		FROM record AS r 
-		INNER JOIN record_description AS rd 
-		ON r.id = rd.record_id 
-		INNER JOIN patient AS p 
-		ON rd.patient_id = p.id 
-		WHERE r.category = 'primary' AND p.name LIKE ? OR p.last_name LIKE ? 
-		ORDER BY r.rdate DESC 
-		LIMIT ?, ?`, query, query, response.Page*N, N)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var record model.Record
-
-		if err := rows.Scan(
-			&record.ID, &record.PatientObj.ID,
-			&record.PatientObj.Name, &record.PatientObj.Lastname,
-			&record.Date, &record.Duration); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			fatal("cannot generate a token secret", "error", err)
 		}
-
-		records = append(records, record)
 	}
 
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	response.Data = records
-	c.IndentedJSON(http.StatusOK, response)
+	return secret
 }
 
-// TODO COMPLETE ENDPOINT
-func getRecordsById(c *gin.Context) {
-	var record model.Record
-	var histories []model.DiseaseHistory
-	var symptoms []model.Symptom
-	var vitalSigns []model.RecordVitalSign
-	var idx []model.Disease
-	var exams []model.Exam
-	var treatments []model.Treatment
-
-	id := c.Param("id")
-	row := db.QueryRow(
-		`SELECT r.id, r.category, p.id, p.name, p.last_name, p.gender, r.rdate, rd.age, rd.weight, rd.height, rd.duration 
-		FROM record AS r 
-		INNER JOIN record_description AS rd
-		ON r.id = rd.record_id 
-		INNER JOIN patient AS p
-		ON rd.patient_id = p.id 
-		WHERE r.id = ?`, id)
-
-	if err := row.Scan(
-		&record.ID, &record.Category, &record.PatientObj.ID, &record.PatientObj.Name,
-		&record.PatientObj.Lastname, &record.PatientObj.Gender, &record.Date,
-		&record.Age, &record.Weight, &record.Height, &record.Duration); err != nil {
-
-		if err == sql.ErrNoRows {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "no such medical record"})
-			return
-		}
-
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-	//--------------------------------------
-	rows, _ := db.Query(
-		`SELECT dh.record_id, d.id, d.description, dh.description 
-		FROM disease_history AS dh 
-		INNER JOIN disease AS d 
-		ON dh.disease_id=d.id 
-		WHERE dh.record_id=?`, id)
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var history model.DiseaseHistory
-
-		if err := rows.Scan(
-			&history.RecordID, &history.DiseaseID, &history.DiseaseDesc, &history.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		histories = append(histories, history)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-	//--------------------------------------
-	rows, _ = db.Query(
-		`SELECT * FROM symptom 
-		WHERE id IN (
-			SELECT symptom_id 
-			FROM record_symptom 
-			WHERE record_id=?
-		)`, id)
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var symptom model.Symptom
-
-		if err := rows.Scan(
-			&symptom.ID, &symptom.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		symptoms = append(symptoms, symptom)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-	//--------------------------------------
-	rows, _ = db.Query(
-		`SELECT * FROM disease 
-		WHERE id IN (
-			SELECT disease_id FROM idx 
-			WHERE record_id=?
-		)`, id)
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var disease model.Disease
-
-		if err := rows.Scan(
-			&disease.ID, &disease.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		idx = append(idx, disease)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-	//--------------------------------------
-	rows, _ = db.Query(
-		`SELECT * FROM exam 
-		WHERE id IN (
-			SELECT exam_id FROM record_exam 
-			WHERE record_id=?
-		)`, id)
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var exam model.Exam
-
-		if err := rows.Scan(
-			&exam.ID, &exam.Description); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		exams = append(exams, exam)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-	//--------------------------------------
-	rows, _ = db.Query(
-		`SELECT rvs.record_id, vs.id, vs.description, u.id, u.symbol, rvs.value 
-		FROM record_vital_sign AS rvs
-		INNER JOIN vital_sign AS vs 
-		ON rvs.vital_sign_id=vs.id 
-		INNER JOIN unit AS u 
-		ON vs.unit_id=u.id 
-		WHERE rvs.record_id=?`, id)
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var vitalSign model.RecordVitalSign
-
-		if err := rows.Scan(
-			&vitalSign.RecordID, &vitalSign.VitalSignID,
-			&vitalSign.Description, &vitalSign.UnitID, &vitalSign.Symbol, &vitalSign.Value); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		vitalSigns = append(vitalSigns, vitalSign)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-	//--------------------------------------
-	rows, _ = db.Query(
-		`SELECT t.record_id, m.id, m.name, m.dose, f.id, s.id, s.description, u.id, u.symbol, t.quantity, t.dosage, t.frequency, t.instructions 
-		FROM treatment AS t
-		INNER JOIN medicine AS m
-		ON t.medicine_id=m.id
-		INNER JOIN formulation AS f
-		ON m.formulation_id=f.id
-		INNER JOIN shape AS s
-		ON f.shape_id=s.id
-		INNER JOIN unit AS u
-		ON f.unit_id=u.id
-		WHERE t.record_id=?`, id)
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var treatment model.Treatment
+// openDB opens the SQL database of the configured store.
+func openDB(cfg config.Config) *sql.DB {
+	var db *sql.DB
+	var err error
 
-		if err := rows.Scan(
-			&treatment.RecordID, &treatment.MedicineID, &treatment.Name,
-			&treatment.Dose, &treatment.FormulationID, &treatment.ShapeID, &treatment.Description,
-			&treatment.UnitID, &treatment.Symbol, &treatment.Quantity, &treatment.Dosage,
-			&treatment.Frequency, &treatment.Instructions); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
+	switch cfg.Store {
+	case "mysql":
+		db, err = connect(cfg.Database)
+	case "sqlite":
+		db, err = store.OpenSQLite(cfg.Database.Path)
+		if err == nil {
+			slog.Info("using SQLite", "path", cfg.Database.Path)
 		}
-
-		treatments = append(treatments, treatment)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
-
-	fullRecord := model.FullRecord{
-		RecordObj:       record,
-		DiseasesHistory: histories,
-		Symptoms:        symptoms,
-		VitalSigns:      vitalSigns,
-		Diseases:        idx,
-		Exams:           exams,
-		Treatments:      treatments,
+	default:
+		fatal("store has no database", "store", cfg.Store)
 	}
 
-	c.IndentedJSON(http.StatusOK, fullRecord)
-}
-
-// TODO COMPLETE ENDPOINT
-func postRecords(c *gin.Context) {
-	var fullRecord model.FullRecord
-
-	//TODO BIND EVERY OBJECT
-	if err := c.BindJSON(&fullRecord); err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	tx, err := db.Begin()
 	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
+		fatal("cannot open the database", "error", err)
 	}
-	defer tx.Rollback()
-
-	record := fullRecord.RecordObj
-
-	result, err := tx.Exec(
-		"INSERT INTO record (category, rdate) VALUES (?, ?)",
-		record.Category, record.Date)
 
-	if err != nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-		return
-	}
-
-	id, err := result.LastInsertId()
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+	if err := waitForDB(db, cfg.Database.ConnectTimeout); err != nil {
+		db.Close()
+		fatal("database unreachable", "timeout", cfg.Database.ConnectTimeout.String(), "error", err)
 	}
+	slog.Info("connected to the database", "store", cfg.Store)
 
-	record.ID = id
-
-	if record.Category == "primary" {
-		_, err = tx.Exec(
-			"INSERT INTO record_description (record_id, patient_id, age, weight, height, duration) VALUES (?, ?, ?, ?, ?, ?)",
-			record.ID, record.PatientObj.ID, record.Age, record.Weight, record.Height, record.Duration)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
-		}
-	} else if record.Category == "secondary" {
-		_, err = tx.Exec(
-			"INSERT INTO secondary_record (record_id, primary_record_id) VALUES (?, ?)",
-			record.ID, record.PrimaryID)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
-		}
-	}
-
-	for _, diseaseHistory := range fullRecord.DiseasesHistory {
-		_, err = tx.Exec(
-			"INSERT INTO disease_history (record_id, disease_id, description) VALUES (?, ?, ?)",
-			record.ID, diseaseHistory.DiseaseID, diseaseHistory.Description)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
-		}
-	}
-
-	for _, symptom := range fullRecord.Symptoms {
-		_, err = tx.Exec(
-			"INSERT INTO record_symptom (record_id, symptom_id) VALUES (?, ?)",
-			record.ID, symptom.ID)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
-		}
-	}
-
-	for _, vital_sign := range fullRecord.VitalSigns {
-		_, err = tx.Exec(
-			"INSERT INTO record_vital_sign (record_id, vital_sign_id, value) VALUES (?, ?, ?)",
-			record.ID, vital_sign.VitalSignID, vital_sign.Value)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
-		}
-	}
-
-	for _, disease := range fullRecord.Diseases {
-		_, err = tx.Exec(
-			"INSERT INTO idx (record_id, disease_id) VALUES (?, ?)",
-			record.ID, disease.ID)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
-		}
-	}
+	return db
+}
 
-	for _, exam := range fullRecord.Exams {
-		_, err = tx.Exec(
-			"INSERT INTO record_exam (record_id, exam_id) VALUES (?, ?)",
-			record.ID, exam.ID)
+// waitForDB pings db until it answers or timeout elapses, backing off
+// from half a second up to five seconds between attempts so the server
+// can start alongside its database.
+func waitForDB(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := 500 * time.Millisecond
 
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
 		}
-	}
-
-	for _, treatment := range fullRecord.Treatments {
-		_, err = tx.Exec(
-			"INSERT INTO treatment (record_id, medicine_id, quantity, dosage, frequency, instructions) VALUES (?, ?, ?, ?, ?, ?)",
-			record.ID, treatment.MedicineID, treatment.Quantity, treatment.Dosage, treatment.Frequency, treatment.Instructions)
 
-		if err != nil {
-			c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": err.Error()})
-			return
+		if time.Now().Add(delay).After(deadline) {
+			return err
 		}
-	}
+		slog.Warn("database not ready, retrying", "delay", delay.String(), "error", err)
 
-	// Commit the transaction.
-	if err = tx.Commit(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+		time.Sleep(delay)
+		delay = min(2*delay, 5*time.Second)
 	}
-
-	c.IndentedJSON(http.StatusCreated, record)
 }
 
-func getSecRecordsById(c *gin.Context) {
-	var fullSecRecords []model.FullRecord
-
-	primary_record_id := c.Param("id")
-
-	rows, err := db.Query(
-		`SELECT r.id, sr.primary_record_id, r.rdate 
-		FROM record AS r 
-		INNER JOIN secondary_record AS sr 
-		ON r.id = sr.record_id 
-		WHERE r.category='secondary' AND sr.primary_record_id=?`, primary_record_id)
-
-	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
+func connect(dbCfg config.Database) (*sql.DB, error) {
+	// Capture connection properties.
+	cfg := mysql.Config{
+		User:   dbCfg.User,
+		Passwd: dbCfg.Password,
+		Net:    "tcp",
+		Addr:   dbCfg.Addr,
+		DBName: dbCfg.Name,
 	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var fullSecRecord model.FullRecord
-
-		if err := rows.Scan(
-			&fullSecRecord.RecordObj.ID, &fullSecRecord.RecordObj.PrimaryID,
-			&fullSecRecord.RecordObj.Date); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		rows, err := db.Query(
-			`SELECT t.record_id, m.id, m.name, m.dose, f.id, s.id, s.description, u.id, u.symbol, t.quantity, t.dosage, t.frequency, t.instructions 
-			FROM treatment AS t
-			INNER JOIN medicine AS m
-			ON t.medicine_id=m.id
-			INNER JOIN formulation AS f
-			ON m.formulation_id=f.id
-			INNER JOIN shape AS s
-			ON f.shape_id=s.id
-			INNER JOIN unit AS u
-			ON f.unit_id=u.id
-			WHERE t.record_id=?`, fullSecRecord.RecordObj.ID)
-
-		if err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		defer rows.Close()
-
-		for rows.Next() {
-			var treatment model.Treatment
-
-			if err := rows.Scan(
-				&treatment.RecordID, &treatment.MedicineID, &treatment.Name,
-				&treatment.Dose, &treatment.FormulationID, &treatment.ShapeID, &treatment.Description,
-				&treatment.UnitID, &treatment.Symbol, &treatment.Quantity, &treatment.Dosage,
-				&treatment.Frequency, &treatment.Instructions); err != nil {
-				c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-				return
-			}
-
-			fullSecRecord.Treatments = append(fullSecRecord.Treatments, treatment)
-		}
-
-		if err := rows.Err(); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-
-		fullSecRecords = append(fullSecRecords, fullSecRecord)
+	// Get a database handle.
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		return
-	}
+	db.SetMaxOpenConns(dbCfg.MaxOpenConns)
+	db.SetMaxIdleConns(dbCfg.MaxIdleConns)
+	db.SetConnMaxLifetime(dbCfg.ConnMaxLifetime)
 
-	c.IndentedJSON(http.StatusOK, fullSecRecords)
+	return db, nil
 }
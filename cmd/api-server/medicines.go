@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func (s *server) getFormulations(c *gin.Context) {
+	formulations, err := s.store.ListFormulations(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, formulations)
+}
+
+func (s *server) getMedicines(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	q, err := s.pageQuery(c, true)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	filter, sort, err := medicineListing(c, q)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if q.Keyset {
+		writeKeysetPage(c, q,
+			func(after store.Cursor, limit int) ([]model.Medicine, error) {
+				return s.store.ListMedicinesAfter(ctx, filter, after, limit)
+			},
+			func(medicine model.Medicine) store.Cursor {
+				return store.Cursor{Key: medicine.Name, ID: medicine.ID}
+			},
+			func() (int64, error) { return s.store.CountMedicines(ctx, filter) })
+		return
+	}
+
+	writeOffsetPage(c, q,
+		func() (int64, error) { return s.store.CountMedicines(ctx, filter) },
+		func(offset, limit int) ([]model.Medicine, error) {
+			return s.store.ListMedicines(ctx, filter, sort, offset, limit)
+		})
+}
+
+func (s *server) postMedicines(c *gin.Context) {
+	var medicine model.Medicine
+
+	if !bindJSON(c, &medicine) {
+		return
+	}
+
+	if err := s.store.CreateMedicine(c.Request.Context(), &medicine); err != nil {
+		apierr.Write(c, storeError(err, "medicine"))
+		return
+	}
+
+	s.indexMedicine(c.Request.Context(), medicine)
+	c.IndentedJSON(http.StatusCreated, medicine)
+}
+
+// indexMedicine adds a new medicine to the search index. The index holds
+// medicines as listed, with their whole formulation, while the request
+// only names it; if it cannot be read, the index is rebuilt instead.
+func (s *server) indexMedicine(ctx context.Context, medicine model.Medicine) {
+	formulations, err := s.store.ListFormulations(ctx)
+	i := slices.IndexFunc(formulations, func(f model.Formulation) bool { return f.ID == medicine.FormulationObj.ID })
+	if err != nil || i < 0 {
+		s.search.medicines.invalidate()
+		return
+	}
+
+	medicine.FormulationObj = formulations[i]
+	s.search.medicines.put(medicine)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"github.com/jctorrestone/web-service-mr/internal/migrate"
+)
+
+const migrateUsage = `usage: api-server migrate [flags] up|down|status
+
+  up      apply every pending migration
+  down    revert the most recently applied migration
+  status  list migrations and whether they are applied
+
+flags:
+`
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() != 1 || !slices.Contains([]string{"up", "down", "status"}, flags.Arg(0)) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	migrator, err := migrate.New(db, cfg.Store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	switch flags.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			log.Println("database is up to date")
+		}
+	case "down":
+		m, ok, err := migrator.Down(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			log.Println("no migration to revert")
+			return
+		}
+		log.Printf("reverted %04d_%s", m.Version, m.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	}
+}
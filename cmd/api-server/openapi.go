@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/config"
+)
+
+const openapiUsage = `usage: api-server openapi [flags] [check]
+
+Prints the OpenAPI document of the API served at /openapi.json. With
+check, lists the routes the document misses or describes in vain instead,
+and exits with status 1 if there are any.
+
+flags:
+`
+
+// runOpenAPI implements the openapi subcommand.
+func runOpenAPI(args []string) {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), openapiUsage)
+		flags.PrintDefaults()
+	}
+
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() > 1 || flags.NArg() == 1 && flags.Arg(0) != "check" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	doc := apiSpec(cfg.Server.MaxPageSize)
+
+	if flags.NArg() == 0 {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(doc); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Registering the routes does not touch the server's dependencies.
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	(&server{}).routes(router)
+
+	missing, stale := undocumentedRoutes(router, doc)
+	for _, route := range missing {
+		log.Printf("undocumented route: %s", route)
+	}
+	for _, route := range stale {
+		log.Printf("documented route not served: %s", route)
+	}
+
+	if len(missing) > 0 || len(stale) > 0 {
+		os.Exit(1)
+	}
+
+	log.Printf("all %d routes are documented", len(router.Routes()))
+}
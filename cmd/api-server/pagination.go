@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// pageQuery is the pagination requested in the query string of a list
+// endpoint.
+type pageQuery struct {
+	Page int
+	Size int
+	// Keyset is set when the client sent a cursor parameter, even an
+	// empty one asking for the first page. After is the decoded cursor.
+	Keyset bool
+	After  store.Cursor
+	// cursors seals the cursor of the next page.
+	cursors *cursorCodec
+	// Total is cleared by total=false in keyset mode.
+	Total bool
+}
+
+// pageQuery reads the page, page_size, cursor and total parameters.
+// keyset tells whether the endpoint supports cursor pagination.
+func (s *server) pageQuery(c *gin.Context, keyset bool) (pageQuery, error) {
+	var fields []apierr.FieldError
+
+	q := pageQuery{Size: s.pageSize, Total: true, cursors: s.cursors}
+
+	if v, ok := c.GetQuery("page"); ok {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 0 {
+			fields = append(fields, apierr.FieldError{Field: "page", Code: "range", Message: "must be a non-negative integer"})
+		}
+		q.Page = page
+	}
+
+	if v, ok := c.GetQuery("page_size"); ok {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 || size > s.maxPageSize {
+			fields = append(fields, apierr.FieldError{
+				Field:   "page_size",
+				Code:    "range",
+				Message: "must be an integer between 1 and " + strconv.Itoa(s.maxPageSize),
+			})
+		}
+		q.Size = size
+	}
+
+	if v, ok := c.GetQuery("cursor"); ok {
+		after, err := s.cursors.decode(v)
+		switch {
+		case !keyset:
+			fields = append(fields, apierr.FieldError{Field: "cursor", Code: "unsupported", Message: "is not supported by this listing"})
+		case err != nil:
+			fields = append(fields, apierr.FieldError{Field: "cursor", Code: "format", Message: "is not a cursor returned by this listing"})
+		}
+		q.Keyset = true
+		q.After = after
+
+		// Cursor pages are not numbered.
+		if _, ok := c.GetQuery("page"); ok && keyset {
+			fields = append(fields, apierr.FieldError{Field: "page", Code: "conflict", Message: "cannot be combined with cursor"})
+		}
+	}
+
+	if v, ok := c.GetQuery("total"); ok {
+		total, err := strconv.ParseBool(v)
+		switch {
+		case err != nil:
+			fields = append(fields, apierr.FieldError{Field: "total", Code: "format", Message: "must be true or false"})
+		case !total && !q.Keyset:
+			fields = append(fields, apierr.FieldError{Field: "total", Code: "unsupported", Message: "can only be skipped with cursor pagination"})
+		}
+		q.Total = total
+	}
+
+	if len(fields) > 0 {
+		return q, apierr.Validation("invalid query parameters", fields...)
+	}
+
+	return q, nil
+}
+
+// cursorJSON is the content of a cursor, before it is sealed.
+type cursorJSON struct {
+	Key string `json:"k"`
+	ID  int64  `json:"i"`
+}
+
+// cursorCodec seals cursors so clients can neither read the sort key they
+// carry, e.g. a last name, nor forge one.
+type cursorCodec struct {
+	aead cipher.AEAD
+}
+
+// newCursorCodec returns a codec encrypting cursors with AES-GCM, under a
+// key derived from secret so it differs from the token signing key.
+func newCursorCodec(secret []byte) *cursorCodec {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("page cursor"))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return &cursorCodec{aead: aead}
+}
+
+// encode seals cursor behind a random nonce and encodes it base64url.
+func (cc *cursorCodec) encode(cursor store.Cursor) string {
+	content, _ := json.Marshal(cursorJSON{Key: cursor.Key, ID: cursor.ID})
+
+	nonce := make([]byte, cc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cc.aead.Seal(nonce, nonce, content, nil))
+}
+
+// decode opens a cursor made by encode. An empty cursor is the start of
+// the listing.
+func (cc *cursorCodec) decode(v string) (store.Cursor, error) {
+	if v == "" {
+		return store.Cursor{}, nil
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return store.Cursor{}, err
+	}
+
+	if len(sealed) < cc.aead.NonceSize() {
+		return store.Cursor{}, errors.New("cursor too short")
+	}
+
+	nonce, sealed := sealed[:cc.aead.NonceSize()], sealed[cc.aead.NonceSize():]
+	content, err := cc.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return store.Cursor{}, err
+	}
+
+	var cursor cursorJSON
+	if err := json.Unmarshal(content, &cursor); err != nil {
+		return store.Cursor{}, err
+	}
+
+	if cursor.ID <= 0 {
+		return store.Cursor{}, errors.New("cursor has no id")
+	}
+
+	return store.Cursor{Key: cursor.Key, ID: cursor.ID}, nil
+}
+
+// writeKeysetPage responds with the q.Size items following q.After. One
+// more item is fetched to tell whether another page follows, and count
+// is only called if the client wants the total.
+func writeKeysetPage[T any](
+	c *gin.Context,
+	q pageQuery,
+	list func(after store.Cursor, limit int) ([]T, error),
+	position func(T) store.Cursor,
+	count func() (int64, error),
+) {
+	var response model.CursorResponse
+
+	if q.Total {
+		total, err := count()
+		if err != nil {
+			apierr.Write(c, apierr.Internal(err))
+			return
+		}
+		response.Total = &total
+	}
+
+	items, err := list(q.After, q.Size+1)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	links := []link{{"first", pageURI(c, "cursor", "")}}
+	if len(items) > q.Size {
+		items = items[:q.Size]
+		response.NextCursor = q.cursors.encode(position(items[len(items)-1]))
+		links = append(links, link{"next", pageURI(c, "cursor", response.NextCursor)})
+	}
+	setLinks(c, links)
+
+	response.Data = nonNil(items)
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// writeOffsetPage responds with page q.Page of a listing counted by count
+// and fetched by list. Pages past the last one are empty rather than an
+// error, and list is not called for them.
+func writeOffsetPage[T any](
+	c *gin.Context,
+	q pageQuery,
+	count func() (int64, error),
+	list func(offset, limit int) ([]T, error),
+) {
+	total, err := count()
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	response := paginate(total, q.Page, q.Size)
+	items := []T{}
+
+	if q.Page <= response.LastPage && total > 0 {
+		items, err = list(q.Page*q.Size, q.Size)
+		if err != nil {
+			apierr.Write(c, apierr.Internal(err))
+			return
+		}
+	}
+
+	links := []link{{"first", pageURI(c, "page", "0")}}
+	if response.PrevPage >= 0 {
+		links = append(links, link{"prev", pageURI(c, "page", strconv.Itoa(response.PrevPage))})
+	}
+	if response.NextPage >= 0 {
+		links = append(links, link{"next", pageURI(c, "page", strconv.Itoa(response.NextPage))})
+	}
+	links = append(links, link{"last", pageURI(c, "page", strconv.Itoa(response.LastPage))})
+	setLinks(c, links)
+
+	response.Data = nonNil(items)
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// paginate computes the page numbers of a listing of total items split in
+// pages of size. Page numbers start at 0 and -1 stands for no page:
+//
+//   - an empty listing has a single, empty page 0;
+//   - a page past the last one is empty, its previous page being the last;
+//   - page is never negative, pageQuery rejects that.
+func paginate(total int64, page, size int) model.Response {
+	response := model.Response{
+		Page:     page,
+		PrevPage: -1,
+		NextPage: -1,
+		Total:    total,
+	}
+
+	if total > 0 {
+		response.LastPage = int((total - 1) / int64(size))
+	}
+
+	if page > 0 {
+		response.PrevPage = min(page-1, response.LastPage)
+	}
+
+	if page < response.LastPage {
+		response.NextPage = page + 1
+	}
+
+	return response
+}
+
+// link is a target of an RFC 8288 Link header.
+type link struct {
+	rel string
+	uri string
+}
+
+func setLinks(c *gin.Context, links []link) {
+	values := make([]string, len(links))
+	for i, l := range links {
+		values[i] = fmt.Sprintf("<%s>; rel=%q", l.uri, l.rel)
+	}
+
+	c.Header("Link", strings.Join(values, ", "))
+}
+
+// pageURI returns the URI of the current request with the query parameter
+// param set to value.
+func pageURI(c *gin.Context, param, value string) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set(param, value)
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
+
+// nonNil makes empty pages encode as [] rather than null.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+
+	return items
+}
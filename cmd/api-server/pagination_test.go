@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func testServer() *server {
+	return &server{
+		pageSize:    20,
+		maxPageSize: 100,
+		cursors:     newCursorCodec([]byte("0123456789abcdef0123456789abcdef")),
+	}
+}
+
+// testContext returns a context for a GET of target and its recorder.
+func testContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+
+	return c, w
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name             string
+		total            int64
+		page, size       int
+		prev, next, last int
+	}{
+		{name: "empty", total: 0, page: 0, size: 10, prev: -1, next: -1, last: 0},
+		{name: "empty past the end", total: 0, page: 3, size: 10, prev: 0, next: -1, last: 0},
+		{name: "single partial page", total: 5, page: 0, size: 10, prev: -1, next: -1, last: 0},
+		{name: "exact single page", total: 10, page: 0, size: 10, prev: -1, next: -1, last: 0},
+		{name: "first of exact pages", total: 20, page: 0, size: 10, prev: -1, next: 1, last: 1},
+		{name: "exact last page", total: 20, page: 1, size: 10, prev: 0, next: -1, last: 1},
+		{name: "middle page", total: 25, page: 1, size: 10, prev: 0, next: 2, last: 2},
+		{name: "partial last page", total: 25, page: 2, size: 10, prev: 1, next: -1, last: 2},
+		{name: "past the last page", total: 20, page: 5, size: 10, prev: 1, next: -1, last: 1},
+		{name: "pages of one", total: 3, page: 1, size: 1, prev: 0, next: 2, last: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := paginate(tt.total, tt.page, tt.size)
+
+			if got.Page != tt.page || got.Total != tt.total {
+				t.Errorf("page, total = %d, %d, want %d, %d", got.Page, got.Total, tt.page, tt.total)
+			}
+			if got.PrevPage != tt.prev || got.NextPage != tt.next || got.LastPage != tt.last {
+				t.Errorf("prev, next, last = %d, %d, %d, want %d, %d, %d",
+					got.PrevPage, got.NextPage, got.LastPage, tt.prev, tt.next, tt.last)
+			}
+		})
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	s := testServer()
+	cursor := s.cursors.encode(store.Cursor{Key: "Pérez", ID: 7})
+
+	tests := []struct {
+		name   string
+		query  string
+		keyset bool
+		want   pageQuery
+		// fields are the parameters reported invalid, if any.
+		fields []string
+	}{
+		{name: "defaults", query: "", want: pageQuery{Size: 20, Total: true}},
+		{name: "page", query: "page=3", want: pageQuery{Page: 3, Size: 20, Total: true}},
+		{name: "smallest page size", query: "page_size=1", want: pageQuery{Size: 1, Total: true}},
+		{name: "largest page size", query: "page_size=100", want: pageQuery{Size: 100, Total: true}},
+		{name: "page size zero", query: "page_size=0", fields: []string{"page_size"}},
+		{name: "page size too large", query: "page_size=101", fields: []string{"page_size"}},
+		{name: "page size not a number", query: "page_size=ten", fields: []string{"page_size"}},
+		{name: "negative page", query: "page=-1", fields: []string{"page"}},
+		{name: "both invalid", query: "page=x&page_size=0", fields: []string{"page", "page_size"}},
+		{name: "empty cursor", query: "cursor=", keyset: true, want: pageQuery{Size: 20, Keyset: true, Total: true}},
+		{
+			name: "cursor", query: "cursor=" + cursor + "&total=false", keyset: true,
+			want: pageQuery{Size: 20, Keyset: true, After: store.Cursor{Key: "Pérez", ID: 7}},
+		},
+		{name: "cursor unsupported", query: "cursor=", fields: []string{"cursor"}},
+		{name: "page and empty cursor", query: "page=0&cursor=", keyset: true, fields: []string{"page"}},
+		{name: "page and cursor", query: "page=2&cursor=" + cursor, keyset: true, fields: []string{"page"}},
+		{name: "forged cursor", query: "cursor=eyJrIjoiQSIsImkiOjF9", keyset: true, fields: []string{"cursor"}},
+		{name: "total without cursor", query: "total=false", keyset: true, fields: []string{"total"}},
+		{name: "total not a boolean", query: "cursor=&total=maybe", keyset: true, fields: []string{"total"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testContext("/items?" + tt.query)
+
+			got, err := s.pageQuery(c, tt.keyset)
+
+			if tt.fields != nil {
+				var apiErr *apierr.Error
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("error = %v, want a validation error", err)
+				}
+
+				var fields []string
+				for _, field := range apiErr.Fields {
+					fields = append(fields, field.Field)
+				}
+				if !slices.Equal(fields, tt.fields) {
+					t.Errorf("invalid fields = %q, want %q", fields, tt.fields)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			got.cursors = nil
+			if got != tt.want {
+				t.Errorf("pageQuery = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteOffsetPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		total  int64
+		// listed is whether the items should be fetched.
+		listed bool
+		links  []string
+	}{
+		{
+			name: "empty", target: "/items?page_size=10", total: 0,
+			links: []string{
+				`</items?page=0&page_size=10>; rel="first"`,
+				`</items?page=0&page_size=10>; rel="last"`,
+			},
+		},
+		{
+			name: "first page", target: "/items?page_size=10", total: 25, listed: true,
+			links: []string{
+				`</items?page=0&page_size=10>; rel="first"`,
+				`</items?page=1&page_size=10>; rel="next"`,
+				`</items?page=2&page_size=10>; rel="last"`,
+			},
+		},
+		{
+			name: "exact last page", target: "/items?page=1&page_size=10&sort=name", total: 20, listed: true,
+			links: []string{
+				`</items?page=0&page_size=10&sort=name>; rel="first"`,
+				`</items?page=0&page_size=10&sort=name>; rel="prev"`,
+				`</items?page=1&page_size=10&sort=name>; rel="last"`,
+			},
+		},
+		{
+			name: "past the last page", target: "/items?page=4&page_size=10", total: 20,
+			links: []string{
+				`</items?page=0&page_size=10>; rel="first"`,
+				`</items?page=1&page_size=10>; rel="prev"`,
+				`</items?page=1&page_size=10>; rel="last"`,
+			},
+		},
+	}
+
+	s := testServer()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testContext(tt.target)
+			q, err := s.pageQuery(c, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listed := false
+			writeOffsetPage(c, q,
+				func() (int64, error) { return tt.total, nil },
+				func(offset, limit int) ([]int, error) {
+					listed = true
+					if offset != q.Page*q.Size || limit != q.Size {
+						t.Errorf("list(%d, %d), want list(%d, %d)", offset, limit, q.Page*q.Size, q.Size)
+					}
+					return []int{offset + 1}, nil
+				})
+
+			if listed != tt.listed {
+				t.Errorf("listed = %t, want %t", listed, tt.listed)
+			}
+
+			if links := strings.Split(w.Header().Get("Link"), ", "); !slices.Equal(links, tt.links) {
+				t.Errorf("Link = %q, want %q", links, tt.links)
+			}
+
+			var response struct {
+				Data  []int `json:"data"`
+				Total int64 `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Data == nil {
+				t.Error("data is null, want a list")
+			}
+			if response.Total != tt.total {
+				t.Errorf("total = %d, want %d", response.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestWriteKeysetPage(t *testing.T) {
+	patients := []model.Patient{
+		{ID: 1, Lastname: "Alva"},
+		{ID: 2, Lastname: "Bravo"},
+		{ID: 3, Lastname: "Campos"},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		available int
+		// next is the position the next cursor must decode to, if any.
+		next    *store.Cursor
+		counted bool
+	}{
+		{name: "more pages", query: "cursor=&page_size=2", available: 3, next: &store.Cursor{Key: "Bravo", ID: 2}, counted: true},
+		{name: "exact last page", query: "cursor=&page_size=3", available: 3, counted: true},
+		{name: "empty", query: "cursor=&page_size=2", available: 0, counted: true},
+		{name: "without total", query: "cursor=&page_size=2&total=false", available: 3, next: &store.Cursor{Key: "Bravo", ID: 2}},
+	}
+
+	s := testServer()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testContext("/patients?" + tt.query)
+			q, err := s.pageQuery(c, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counted := false
+			writeKeysetPage(c, q,
+				func(after store.Cursor, limit int) ([]model.Patient, error) {
+					if limit != q.Size+1 {
+						t.Errorf("limit = %d, want %d", limit, q.Size+1)
+					}
+					return patients[:min(limit, tt.available)], nil
+				},
+				func(p model.Patient) store.Cursor { return store.Cursor{Key: p.Lastname, ID: p.ID} },
+				func() (int64, error) {
+					counted = true
+					return int64(tt.available), nil
+				})
+
+			if counted != tt.counted {
+				t.Errorf("counted = %t, want %t", counted, tt.counted)
+			}
+
+			var response struct {
+				Data       []model.Patient `json:"data"`
+				NextCursor string          `json:"next_cursor"`
+				Total      *int64          `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+
+			if want := min(q.Size, tt.available); len(response.Data) != want || response.Data == nil {
+				t.Errorf("data = %v, want %d patients", response.Data, want)
+			}
+			if (response.Total != nil) != tt.counted {
+				t.Errorf("total = %v, want it only when counted", response.Total)
+			}
+
+			links := strings.Split(w.Header().Get("Link"), ", ")
+			first := `</patients?cursor=&page_size=` + c.Query("page_size")
+			if c.Query("total") != "" {
+				first += "&total=" + c.Query("total")
+			}
+			if links[0] != first+`>; rel="first"` {
+				t.Errorf("first link = %q", links[0])
+			}
+
+			if tt.next == nil {
+				if response.NextCursor != "" || len(links) != 1 {
+					t.Errorf("next cursor %q and links %q on the last page", response.NextCursor, links)
+				}
+				return
+			}
+
+			if strings.Contains(response.NextCursor, tt.next.Key) {
+				t.Errorf("cursor %q shows its sort key", response.NextCursor)
+			}
+
+			got, err := s.cursors.decode(response.NextCursor)
+			if err != nil || got != *tt.next {
+				t.Errorf("next cursor decodes to %+v, %v, want %+v", got, err, *tt.next)
+			}
+
+			if len(links) != 2 || !strings.Contains(links[1], "cursor="+response.NextCursor) || !strings.HasSuffix(links[1], `rel="next"`) {
+				t.Errorf("links = %q, want a next link with the next cursor", links)
+			}
+		})
+	}
+}
+
+func TestCursorCodec(t *testing.T) {
+	cursors := newCursorCodec([]byte("0123456789abcdef0123456789abcdef"))
+	other := newCursorCodec([]byte("fedcba9876543210fedcba9876543210"))
+	sealed := cursors.encode(store.Cursor{Key: "Pérez", ID: 42})
+
+	if got, err := cursors.decode(sealed); err != nil || got != (store.Cursor{Key: "Pérez", ID: 42}) {
+		t.Errorf("decode = %+v, %v", got, err)
+	}
+
+	if got, err := cursors.decode(""); err != nil || got != (store.Cursor{}) {
+		t.Errorf("empty cursor decodes to %+v, %v, want the start", got, err)
+	}
+
+	for name, v := range map[string]string{
+		"other secret":  sealed,
+		"tampered":      sealed[:len(sealed)-2] + "AA",
+		"too short":     "AAAA",
+		"not base64":    "not a cursor!",
+		"plain content": "eyJrIjoiQSIsImkiOjF9",
+	} {
+		codec := cursors
+		if name == "other secret" {
+			codec = other
+		}
+		if _, err := codec.decode(v); err == nil {
+			t.Errorf("%s: decode succeeded", name)
+		}
+	}
+}
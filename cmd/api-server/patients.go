@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/audit"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func (s *server) getPatients(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	q, err := s.pageQuery(c, true)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	filter, sort, err := patientListing(c, q)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if q.Keyset {
+		writeKeysetPage(c, q,
+			func(after store.Cursor, limit int) ([]model.Patient, error) {
+				return s.store.ListPatientsAfter(ctx, filter, after, limit)
+			},
+			func(patient model.Patient) store.Cursor {
+				return store.Cursor{Key: patient.Lastname, ID: patient.ID}
+			},
+			func() (int64, error) { return s.store.CountPatients(ctx, filter) })
+		return
+	}
+
+	writeOffsetPage(c, q,
+		func() (int64, error) { return s.store.CountPatients(ctx, filter) },
+		func(offset, limit int) ([]model.Patient, error) {
+			return s.store.ListPatients(ctx, filter, sort, offset, limit)
+		})
+}
+
+func (s *server) getPatientById(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such patient"))
+		return
+	}
+
+	patient, err := s.store.GetPatient(c.Request.Context(), id)
+	if err != nil {
+		apierr.Write(c, storeError(err, "patient"))
+		return
+	}
+
+	audit.SetPatientID(c, patient.ID)
+	c.IndentedJSON(http.StatusOK, patient)
+}
+
+func (s *server) postPatients(c *gin.Context) {
+	var request patientRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	patient := model.Patient{Name: request.Name, Lastname: request.Lastname, Gender: *request.Gender}
+	if !trimPatient(c, &patient) {
+		return
+	}
+
+	if err := s.store.CreatePatient(c.Request.Context(), &patient); err != nil {
+		apierr.Write(c, storeError(err, "patient"))
+		return
+	}
+
+	audit.SetResourceID(c, patient.ID)
+	audit.SetPatientID(c, patient.ID)
+	s.search.patients.put(patient)
+	s.metrics.PatientRegistered()
+	c.IndentedJSON(http.StatusCreated, patient)
+}
+
+// patientRequest is the body of POST /patients and PUT /patients/:id.
+type patientRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Lastname string `json:"last_name" binding:"required,max=100"`
+	Gender   *bool  `json:"gender" binding:"required"`
+}
+
+// patientPatch is the body of PATCH /patients/:id; absent fields are left
+// unchanged.
+type patientPatch struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Lastname *string `json:"last_name" binding:"omitempty,min=1,max=100"`
+	Gender   *bool   `json:"gender"`
+}
+
+// patientFromParam loads the patient named by the :id parameter, writing
+// the error response if it cannot.
+func (s *server) patientFromParam(c *gin.Context) (model.Patient, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such patient"))
+		return model.Patient{}, false
+	}
+
+	patient, err := s.store.GetPatient(c.Request.Context(), id)
+	if err != nil {
+		apierr.Write(c, storeError(err, "patient"))
+		return patient, false
+	}
+
+	return patient, true
+}
+
+// trimPatient trims the names of patient, responding with 400 if either
+// is left blank.
+func trimPatient(c *gin.Context, patient *model.Patient) bool {
+	patient.Name = strings.TrimSpace(patient.Name)
+	patient.Lastname = strings.TrimSpace(patient.Lastname)
+
+	var blank []apierr.FieldError
+
+	if patient.Name == "" {
+		blank = append(blank, apierr.FieldError{Field: "name", Code: "blank", Message: "must not be blank"})
+	}
+
+	if patient.Lastname == "" {
+		blank = append(blank, apierr.FieldError{Field: "last_name", Code: "blank", Message: "must not be blank"})
+	}
+
+	if len(blank) > 0 {
+		apierr.Write(c, apierr.Validation("request has invalid fields", blank...))
+		return false
+	}
+
+	return true
+}
+
+func (s *server) updatePatient(c *gin.Context, patient model.Patient) {
+	if !trimPatient(c, &patient) {
+		return
+	}
+
+	if err := s.store.UpdatePatient(c.Request.Context(), patient); err != nil {
+		apierr.Write(c, storeError(err, "patient"))
+		return
+	}
+
+	audit.SetPatientID(c, patient.ID)
+	s.search.patients.put(patient)
+	c.IndentedJSON(http.StatusOK, patient)
+}
+
+func (s *server) putPatientById(c *gin.Context) {
+	var request patientRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	patient, ok := s.patientFromParam(c)
+	if !ok {
+		return
+	}
+
+	patient.Name = request.Name
+	patient.Lastname = request.Lastname
+	patient.Gender = *request.Gender
+
+	s.updatePatient(c, patient)
+}
+
+func (s *server) patchPatientById(c *gin.Context) {
+	var patch patientPatch
+
+	if !bindJSON(c, &patch) {
+		return
+	}
+
+	patient, ok := s.patientFromParam(c)
+	if !ok {
+		return
+	}
+
+	if patch.Name != nil {
+		patient.Name = *patch.Name
+	}
+
+	if patch.Lastname != nil {
+		patient.Lastname = *patch.Lastname
+	}
+
+	if patch.Gender != nil {
+		patient.Gender = *patch.Gender
+	}
+
+	s.updatePatient(c, patient)
+}
+
+func (s *server) deletePatientById(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such patient"))
+		return
+	}
+
+	if err := s.store.DeletePatient(c.Request.Context(), id); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			apierr.Write(c, apierr.Conflict("patient has medical records and cannot be deleted"))
+			return
+		}
+
+		apierr.Write(c, storeError(err, "patient"))
+		return
+	}
+
+	s.search.patients.delete(id)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+var roles = []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse, auth.RoleReception}
+
+// TestRoutePermissions sends each protected route as every role, and
+// without a token. The ids are unknown so allowed requests change
+// nothing.
+func TestRoutePermissions(t *testing.T) {
+	api := newTestAPI(t)
+
+	var (
+		all       = roles
+		staff     = []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse}
+		writers   = []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleReception}
+		catalogs  = []string{auth.RoleAdmin, auth.RolePhysician}
+		adminOnly = []string{auth.RoleAdmin}
+	)
+
+	tests := []struct {
+		method, path string
+		allowed      []string
+	}{
+		{"GET", "/patients", all},
+		{"GET", "/patients/999", all},
+		{"GET", "/patients/search?q=ana", all},
+		{"POST", "/patients", writers},
+		{"PUT", "/patients/999", writers},
+		{"PATCH", "/patients/999", writers},
+		{"DELETE", "/patients/999", adminOnly},
+		{"GET", "/records", staff},
+		{"GET", "/records/999", staff},
+		{"GET", "/records/search?q=ana", staff},
+		{"GET", "/records/999/versions", staff},
+		{"GET", "/sec-records/999", staff},
+		{"POST", "/records", staff},
+		{"PUT", "/records/999", staff},
+		{"POST", "/diseases", catalogs},
+		{"POST", "/diseases/import", catalogs},
+		{"POST", "/exams/import", catalogs},
+		{"POST", "/medicines", catalogs},
+		{"POST", "/medicines/import", catalogs},
+		{"POST", "/symptoms", catalogs},
+		{"POST", "/symptoms/import", catalogs},
+		{"PUT", "/vital-signs/999/range", catalogs},
+		{"DELETE", "/vital-signs/999/range", catalogs},
+		{"GET", "/audit", adminOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var body any
+			if tt.method != "GET" && tt.method != "DELETE" {
+				body = "{}"
+			}
+
+			if w := api.do("", tt.method, tt.path, body); w.Code != http.StatusUnauthorized {
+				t.Errorf("without a token: status = %d, want 401", w.Code)
+			}
+
+			for _, role := range roles {
+				w := api.do(role, tt.method, tt.path, body)
+
+				switch allowed := slices.Contains(tt.allowed, role); {
+				case allowed && (w.Code == http.StatusForbidden || w.Code == http.StatusUnauthorized):
+					t.Errorf("%s: status = %d, want the request allowed: %s", role, w.Code, w.Body)
+				case !allowed && w.Code != http.StatusForbidden:
+					t.Errorf("%s: status = %d, want 403", role, w.Code)
+				}
+			}
+		})
+	}
+}
+
+// TestRecordSectionPermissions checks the permission each record section
+// needs, on creation and on amendment.
+func TestRecordSectionPermissions(t *testing.T) {
+	api := newTestAPI(t)
+
+	newRecord := func(section string, rows []any) map[string]any {
+		body := map[string]any{
+			"record": map[string]any{"category": "primary", "patient": map[string]any{"id": 2}, "rdate": "2024-06-01"},
+		}
+		if section != "" {
+			body[section] = rows
+		}
+		return body
+	}
+
+	creations := []struct {
+		section string
+		rows    []any
+		allowed []string
+	}{
+		{"vital_signs", []any{map[string]any{"vital_sign_id": 1, "value": 36.5}}, []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse}},
+		{"", nil, []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse}},
+		{"diseases_history", []any{map[string]any{"disease_id": 1}}, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"symptoms", []any{map[string]any{"id": 1}}, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"exams", []any{map[string]any{"id": 1}}, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"idx", []any{map[string]any{"id": 1}}, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"treatments", []any{map[string]any{"medicine_id": 1, "quantity": 1, "dosage": 1, "frequency": 8}}, []string{auth.RoleAdmin, auth.RolePhysician}},
+	}
+
+	for _, tt := range creations {
+		for _, role := range roles {
+			want := http.StatusForbidden
+			if slices.Contains(tt.allowed, role) {
+				want = http.StatusCreated
+			}
+
+			if w := api.do(role, "POST", "/records", newRecord(tt.section, tt.rows)); w.Code != want {
+				t.Errorf("POST with %q as %s: status = %d, want %d: %s", tt.section, role, w.Code, want, w.Body)
+			}
+		}
+	}
+
+	// Amendments of the first demo record, each changing one section.
+	amendments := []struct {
+		name    string
+		change  func(*model.FullRecord)
+		allowed []string
+	}{
+		{"vital signs", func(r *model.FullRecord) { r.VitalSigns[0].Value = 37.1 }, []string{auth.RoleAdmin, auth.RolePhysician, auth.RoleNurse}},
+		{"measurements", func(r *model.FullRecord) { r.RecordObj.Weight++ }, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"symptoms", func(r *model.FullRecord) { r.Symptoms = r.Symptoms[1:] }, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"diagnosis", func(r *model.FullRecord) { r.Diseases = []model.Disease{{ID: 1}} }, []string{auth.RoleAdmin, auth.RolePhysician}},
+		{"treatments", func(r *model.FullRecord) { r.Treatments = r.Treatments[:1] }, []string{auth.RoleAdmin, auth.RolePhysician}},
+	}
+
+	for _, tt := range amendments {
+		for _, role := range []string{auth.RoleNurse, auth.RoleReception, auth.RolePhysician} {
+			current := decode[model.FullRecord](t, api.do(auth.RoleAdmin, "GET", "/records/1", nil), http.StatusOK)
+			tt.change(&current)
+
+			want := http.StatusForbidden
+			if slices.Contains(tt.allowed, role) {
+				want = http.StatusOK
+			}
+
+			if w := api.do(role, "PUT", "/records/1", current); w.Code != want {
+				t.Errorf("PUT changing the %s as %s: status = %d, want %d: %s", tt.name, role, w.Code, want, w.Body)
+			}
+		}
+	}
+}
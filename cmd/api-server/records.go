@@ -0,0 +1,406 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/audit"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func (s *server) getRecords(c *gin.Context) {
+	s.listRecords(c, "")
+}
+
+// getRecordsByPatient searches the records by patient name, along with
+// the filters every record listing has.
+func (s *server) getRecordsByPatient(c *gin.Context) {
+	s.listRecords(c, c.DefaultQuery("q", ""))
+}
+
+func (s *server) listRecords(c *gin.Context, name string) {
+	ctx := c.Request.Context()
+
+	q, err := s.pageQuery(c, true)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	filter, sort, err := recordListing(c, q, name)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if q.Keyset {
+		writeKeysetPage(c, q,
+			func(after store.Cursor, limit int) ([]model.Record, error) {
+				return s.store.ListRecordsAfter(ctx, filter, after, limit)
+			},
+			func(record model.Record) store.Cursor {
+				return store.Cursor{Key: record.Date, ID: record.ID}
+			},
+			func() (int64, error) { return s.store.CountRecords(ctx, filter) })
+		return
+	}
+
+	writeOffsetPage(c, q,
+		func() (int64, error) { return s.store.CountRecords(ctx, filter) },
+		func(offset, limit int) ([]model.Record, error) {
+			return s.store.ListRecords(ctx, filter, sort, offset, limit)
+		})
+}
+
+func (s *server) getRecordsById(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such medical record"))
+		return
+	}
+
+	var fullRecord model.FullRecord
+
+	if query, ok := c.GetQuery("version"); ok {
+		version, convErr := strconv.Atoi(query)
+		if convErr != nil || version < 1 {
+			apierr.Write(c, apierr.Validation("invalid query parameters", apierr.FieldError{
+				Field: "version", Code: "min", Message: "must be a positive integer",
+			}))
+			return
+		}
+
+		fullRecord, err = s.store.GetRecordVersion(c.Request.Context(), id, version)
+	} else {
+		fullRecord, err = s.store.GetRecord(c.Request.Context(), id)
+	}
+
+	if err != nil {
+		apierr.Write(c, storeError(err, "medical record"))
+		return
+	}
+
+	audit.SetPatientID(c, fullRecord.RecordObj.PatientObj.ID)
+	s.respondRecord(c, fullRecord)
+}
+
+func (s *server) postRecords(c *gin.Context) {
+	var fullRecord model.FullRecord
+
+	fields, ok := decodeRecord(c, &fullRecord)
+	if !ok {
+		return
+	}
+
+	if !allowedSections(c, recordSections(fullRecord)) {
+		return
+	}
+
+	if !s.checkRecord(c, fullRecord, fields) {
+		return
+	}
+
+	// A secondary record belongs to the patient of its primary record,
+	// whatever patient the body names.
+	if fullRecord.RecordObj.Category == "secondary" {
+		primary, err := s.store.GetRecord(c.Request.Context(), fullRecord.RecordObj.PrimaryID)
+		if err != nil {
+			apierr.Write(c, storeError(err, "primary medical record"))
+			return
+		}
+
+		fullRecord.RecordObj.PatientObj = primary.RecordObj.PatientObj
+	}
+
+	if err := s.store.CreateRecord(c.Request.Context(), &fullRecord); err != nil {
+		apierr.Write(c, storeError(err, "medical record"))
+		return
+	}
+
+	audit.SetResourceID(c, fullRecord.RecordObj.ID)
+	audit.SetPatientID(c, fullRecord.RecordObj.PatientObj.ID)
+	s.metrics.RecordCreated(fullRecord.RecordObj.Category)
+	c.IndentedJSON(http.StatusCreated, fullRecord.RecordObj)
+}
+
+func (s *server) putRecordById(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such medical record"))
+		return
+	}
+
+	current, err := s.store.GetRecord(ctx, id)
+	if err != nil {
+		apierr.Write(c, storeError(err, "medical record"))
+		return
+	}
+
+	audit.SetPatientID(c, current.RecordObj.PatientObj.ID)
+
+	// The category, patient and primary record of a record never change.
+	// The body may leave them out, they are validated as stored, and
+	// naming other ones is an error rather than silently ignored.
+	var fullRecord model.FullRecord
+	fullRecord.RecordObj.Category = current.RecordObj.Category
+	fullRecord.RecordObj.PatientObj = current.RecordObj.PatientObj
+	fullRecord.RecordObj.PrimaryID = current.RecordObj.PrimaryID
+
+	fields, ok := decodeRecord(c, &fullRecord)
+	if !ok {
+		return
+	}
+
+	if immutable := immutableChanges(current.RecordObj, fullRecord.RecordObj); len(immutable) > 0 {
+		apierr.Write(c, apierr.Validation("medical record is invalid", append(fields, immutable...)...))
+		return
+	}
+
+	// Secondary records have no measurements of their own.
+	if current.RecordObj.Category == "secondary" {
+		fullRecord.RecordObj.Age = 0
+		fullRecord.RecordObj.Weight = 0
+		fullRecord.RecordObj.Height = 0
+		fullRecord.RecordObj.Duration = 0
+	}
+
+	// Only the sections that actually change need the matching
+	// permission, so a nurse can amend vital signs without touching the
+	// diagnosis.
+	changes := recordChanges(current, fullRecord)
+	if !allowedSections(c, changes) {
+		return
+	}
+
+	if !s.checkRecord(c, fullRecord, fields) {
+		return
+	}
+
+	if len(changes) == 0 {
+		s.respondRecord(c, current)
+		return
+	}
+
+	claims, _ := auth.CurrentClaims(c)
+	fullRecord.RecordObj.ID = id
+
+	if err := s.store.UpdateRecord(ctx, &fullRecord, claims.UserID()); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			apierr.Write(c, apierr.Conflict("medical record was amended concurrently, reload it and try again"))
+			return
+		}
+
+		apierr.Write(c, storeError(err, "medical record"))
+		return
+	}
+
+	updated, err := s.store.GetRecord(ctx, id)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	s.respondRecord(c, updated)
+}
+
+// respondRecord writes fullRecord with its abnormal vital signs flagged.
+func (s *server) respondRecord(c *gin.Context, fullRecord model.FullRecord) {
+	if err := s.flagVitalSigns(c.Request.Context(), &fullRecord); err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, fullRecord)
+}
+
+func (s *server) getRecordVersions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such medical record"))
+		return
+	}
+
+	versions, err := s.store.ListRecordVersions(c.Request.Context(), id)
+	if err != nil {
+		apierr.Write(c, storeError(err, "medical record"))
+		return
+	}
+
+	for i := 1; i < len(versions); i++ {
+		versions[i].Changes = recordChanges(versions[i-1].Record, versions[i].Record)
+	}
+
+	audit.SetPatientID(c, versions[len(versions)-1].Record.RecordObj.PatientObj.ID)
+	c.IndentedJSON(http.StatusOK, versions)
+}
+
+// Sections of a medical record, as named in its JSON representation.
+const (
+	sectionRecord          = "record"
+	sectionDiseasesHistory = "diseases_history"
+	sectionSymptoms        = "symptoms"
+	sectionVitalSigns      = "vital_signs"
+	sectionIdx             = "idx"
+	sectionExams           = "exams"
+	sectionTreatments      = "treatments"
+)
+
+// sectionPermissions maps each record section to the permission needed
+// to write it: nurses may only submit vital signs, while diagnoses and
+// treatments are reserved to physicians.
+var sectionPermissions = map[string]auth.Permission{
+	sectionRecord:          auth.RecordsWrite,
+	sectionDiseasesHistory: auth.RecordsWrite,
+	sectionSymptoms:        auth.RecordsWrite,
+	sectionVitalSigns:      auth.VitalSignsWrite,
+	sectionIdx:             auth.Diagnose,
+	sectionExams:           auth.RecordsWrite,
+	sectionTreatments:      auth.Diagnose,
+}
+
+// allowedSections reports whether the current user may write every one of
+// sections, responding with 403 if not.
+func allowedSections(c *gin.Context, sections []string) bool {
+	for _, section := range sections {
+		permission := sectionPermissions[section]
+
+		if !auth.Allowed(c, permission) {
+			apierr.Write(c, apierr.Forbidden("insufficient permissions: "+string(permission)+" required"))
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordSections returns the child collections fullRecord fills in. The
+// record itself is not listed since every new record has one.
+func recordSections(fullRecord model.FullRecord) []string {
+	var sections []string
+
+	add := func(section string, n int) {
+		if n > 0 {
+			sections = append(sections, section)
+		}
+	}
+
+	add(sectionDiseasesHistory, len(fullRecord.DiseasesHistory))
+	add(sectionSymptoms, len(fullRecord.Symptoms))
+	add(sectionVitalSigns, len(fullRecord.VitalSigns))
+	add(sectionIdx, len(fullRecord.Diseases))
+	add(sectionExams, len(fullRecord.Exams))
+	add(sectionTreatments, len(fullRecord.Treatments))
+
+	return sections
+}
+
+// immutableChanges reports the fields of a record that an amendment
+// cannot change but updated names differently from current.
+func immutableChanges(current, updated model.Record) []apierr.FieldError {
+	var fields []apierr.FieldError
+
+	add := func(field string, changed bool) {
+		if changed {
+			fields = append(fields, apierr.FieldError{
+				Field: field, Code: "immutable", Message: "cannot be changed",
+			})
+		}
+	}
+
+	add("record.category", updated.Category != current.Category)
+	add("record.patient.id", updated.PatientObj.ID != current.PatientObj.ID)
+	add("record.primary_record_id", updated.PrimaryID != current.PrimaryID)
+
+	return fields
+}
+
+// recordChanges returns the sections that differ between two versions of
+// a record. Collections are compared by the columns stored for them, so
+// descriptive fields joined in from the catalogs are ignored.
+func recordChanges(old, new model.FullRecord) []string {
+	var changes []string
+
+	add := func(section string, changed bool) {
+		if changed {
+			changes = append(changes, section)
+		}
+	}
+
+	recordKey := func(r model.Record) string {
+		return fmt.Sprint(r.Date, r.Age, r.Weight, r.Height, r.Duration)
+	}
+
+	add(sectionRecord, recordKey(old.RecordObj) != recordKey(new.RecordObj))
+	add(sectionDiseasesHistory, !sameRows(old.DiseasesHistory, new.DiseasesHistory, func(h model.DiseaseHistory) string {
+		return fmt.Sprintf("%d %q", h.DiseaseID, h.Description)
+	}))
+	add(sectionSymptoms, !sameRows(old.Symptoms, new.Symptoms, func(s model.Symptom) string {
+		return fmt.Sprint(s.ID)
+	}))
+	add(sectionVitalSigns, !sameRows(old.VitalSigns, new.VitalSigns, func(v model.RecordVitalSign) string {
+		return fmt.Sprint(v.VitalSignID, v.Value)
+	}))
+	add(sectionIdx, !sameRows(old.Diseases, new.Diseases, func(d model.Disease) string {
+		return fmt.Sprint(d.ID)
+	}))
+	add(sectionExams, !sameRows(old.Exams, new.Exams, func(e model.Exam) string {
+		return fmt.Sprint(e.ID)
+	}))
+	add(sectionTreatments, !sameRows(old.Treatments, new.Treatments, func(t model.Treatment) string {
+		return fmt.Sprintf("%d %d %v %d %q", t.MedicineID, t.Quantity, t.Dosage, t.Frequency, t.Instructions)
+	}))
+
+	return changes
+}
+
+// sameRows reports whether a and b hold the same rows in any order.
+func sameRows[T any](a, b []T, key func(T) string) bool {
+	keys := func(items []T) []string {
+		k := make([]string, len(items))
+		for i, item := range items {
+			k[i] = key(item)
+		}
+		slices.Sort(k)
+		return k
+	}
+
+	return slices.Equal(keys(a), keys(b))
+}
+
+func (s *server) getSecRecordsById(c *gin.Context) {
+	primaryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such medical record"))
+		return
+	}
+
+	// The secondary records belong to the patient of the primary one,
+	// which the access log names.
+	primary, err := s.store.GetRecord(c.Request.Context(), primaryID)
+	if err == nil && primary.RecordObj.Category != "primary" {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		apierr.Write(c, storeError(err, "primary medical record"))
+		return
+	}
+
+	audit.SetPatientID(c, primary.RecordObj.PatientObj.ID)
+
+	fullSecRecords, err := s.store.ListSecondaryRecords(c.Request.Context(), primaryID)
+	if err != nil {
+		apierr.Write(c, storeError(err, "medical record"))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, nonNil(fullSecRecords))
+}
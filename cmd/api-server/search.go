@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/logging"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/search"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// searchMaxAge bounds how stale a search index may get. Writes made
+// through this server update it right away, writes made by other
+// processes show up once it has been rebuilt after expiring.
+const searchMaxAge = time.Minute
+
+// searchRebuildTimeout bounds the loading of the rows of an index rebuilt
+// in the background.
+const searchRebuildTimeout = time.Minute
+
+// searchIndex is the search index of one kind of row, built from the
+// store on first use. Once built, searches never wait for a rebuild: an
+// expired index keeps serving while the new one is loaded.
+type searchIndex[T any] struct {
+	load   func(ctx context.Context) ([]T, error)
+	key    func(T) int64
+	fields func(T) []search.Field
+
+	// building serializes the loads of the index.
+	building sync.Mutex
+
+	mu    sync.Mutex
+	index *search.Index[T]
+	built time.Time
+	// rebuilding is set while the index is loaded. The writes made in
+	// the meantime may be missing from the rows loaded, so they are kept
+	// in pending and replayed on the new index.
+	rebuilding bool
+	pending    []func(*search.Index[T])
+	// invalidations counts the calls to invalidate, so a rebuild that
+	// loaded its rows before one of them starts over.
+	invalidations int
+}
+
+// get returns the index, building it on first use and starting its
+// rebuild in the background once it has expired.
+func (si *searchIndex[T]) get(ctx context.Context) (*search.Index[T], error) {
+	si.mu.Lock()
+	index := si.index
+	expired := index != nil && !si.rebuilding && time.Since(si.built) >= searchMaxAge
+	if expired {
+		si.rebuilding = true
+	}
+	si.mu.Unlock()
+
+	if index == nil {
+		return si.rebuild(ctx, true)
+	}
+
+	if expired {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), searchRebuildTimeout)
+			defer cancel()
+
+			if _, err := si.rebuild(ctx, false); err != nil {
+				logging.FromContext(ctx).Warn("cannot rebuild a search index", "error", err)
+			}
+		}()
+	}
+
+	return index, nil
+}
+
+// rebuild loads the rows and swaps in a new index holding them. With
+// once, an index built meanwhile by another caller is returned instead.
+func (si *searchIndex[T]) rebuild(ctx context.Context, once bool) (*search.Index[T], error) {
+	si.building.Lock()
+	defer si.building.Unlock()
+
+	for {
+		si.mu.Lock()
+		if once && si.index != nil {
+			index := si.index
+			si.mu.Unlock()
+			return index, nil
+		}
+		si.rebuilding = true
+		si.pending = nil
+		invalidations := si.invalidations
+		si.mu.Unlock()
+
+		items, err := si.load(ctx)
+
+		si.mu.Lock()
+		pending := si.pending
+		si.rebuilding = false
+		si.pending = nil
+
+		switch {
+		case err != nil:
+			si.mu.Unlock()
+			return nil, err
+		case si.invalidations != invalidations:
+			// The rows may predate the invalidating write.
+			si.mu.Unlock()
+			continue
+		}
+
+		index := search.New(items, si.key, si.fields)
+		for _, write := range pending {
+			write(index)
+		}
+		si.index, si.built = index, time.Now()
+		si.mu.Unlock()
+
+		return index, nil
+	}
+}
+
+// write applies a write made through this server to the index, and to
+// the one being rebuilt if any.
+func (si *searchIndex[T]) write(apply func(*search.Index[T])) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	if si.rebuilding {
+		si.pending = append(si.pending, apply)
+	}
+	if si.index != nil {
+		apply(si.index)
+	}
+}
+
+// put indexes a row created or updated through this server.
+func (si *searchIndex[T]) put(item T) {
+	si.write(func(index *search.Index[T]) { index.Put(item) })
+}
+
+// delete removes a row deleted through this server.
+func (si *searchIndex[T]) delete(id int64) {
+	si.write(func(index *search.Index[T]) { index.Delete(id) })
+}
+
+// invalidate drops the index, for writes the server cannot apply row by
+// row, such as bulk imports. The next search waits for the index to be
+// rebuilt, and a rebuild in progress starts over, so it sees the write.
+func (si *searchIndex[T]) invalidate() {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.index = nil
+	si.invalidations++
+}
+
+// searchIndexes holds the index behind each /search route.
+type searchIndexes struct {
+	diseases  *searchIndex[model.Disease]
+	symptoms  *searchIndex[model.Symptom]
+	medicines *searchIndex[model.Medicine]
+	patients  *searchIndex[model.Patient]
+}
+
+func newSearchIndexes(st store.Store) searchIndexes {
+	return searchIndexes{
+		diseases: &searchIndex[model.Disease]{
+			load: func(ctx context.Context) ([]model.Disease, error) {
+				return store.LoadAll(
+					func() (int64, error) { return st.CountDiseases(ctx, store.DiseaseFilter{}) },
+					func(offset, limit int) ([]model.Disease, error) {
+						return st.ListDiseases(ctx, store.DiseaseFilter{}, nil, offset, limit)
+					})
+			},
+			key: func(disease model.Disease) int64 { return disease.ID },
+			fields: func(disease model.Disease) []search.Field {
+				return []search.Field{{Name: "description", Text: disease.Description}}
+			},
+		},
+		symptoms: &searchIndex[model.Symptom]{
+			load: func(ctx context.Context) ([]model.Symptom, error) {
+				return store.LoadAll(
+					func() (int64, error) { return st.CountSymptoms(ctx, "") },
+					func(offset, limit int) ([]model.Symptom, error) {
+						return st.ListSymptoms(ctx, "", offset, limit)
+					})
+			},
+			key: func(symptom model.Symptom) int64 { return symptom.ID },
+			fields: func(symptom model.Symptom) []search.Field {
+				return []search.Field{{Name: "description", Text: symptom.Description}}
+			},
+		},
+		medicines: &searchIndex[model.Medicine]{
+			load: func(ctx context.Context) ([]model.Medicine, error) {
+				return store.LoadAll(
+					func() (int64, error) { return st.CountMedicines(ctx, store.MedicineFilter{}) },
+					func(offset, limit int) ([]model.Medicine, error) {
+						return st.ListMedicines(ctx, store.MedicineFilter{}, nil, offset, limit)
+					})
+			},
+			key: func(medicine model.Medicine) int64 { return medicine.ID },
+			fields: func(medicine model.Medicine) []search.Field {
+				return []search.Field{{Name: "name", Text: medicine.Name}}
+			},
+		},
+		patients: &searchIndex[model.Patient]{
+			load: func(ctx context.Context) ([]model.Patient, error) {
+				return store.LoadAll(
+					func() (int64, error) { return st.CountPatients(ctx, store.PatientFilter{}) },
+					func(offset, limit int) ([]model.Patient, error) {
+						return st.ListPatients(ctx, store.PatientFilter{}, nil, offset, limit)
+					})
+			},
+			key: func(patient model.Patient) int64 { return patient.ID },
+			fields: func(patient model.Patient) []search.Field {
+				return []search.Field{
+					{Name: "name", Text: patient.Name},
+					{Name: "last_name", Text: patient.Lastname},
+				}
+			},
+		},
+	}
+}
+
+// searchQuery reads the q and limit parameters of a /search route. limit
+// defaults to the page size and is capped like page_size.
+func (s *server) searchQuery(p *listParams) (string, int) {
+	query := p.c.Query("q")
+	if query == "" {
+		p.reject("q", "required", "is required")
+	}
+
+	limit := s.pageSize
+	if v, ok := p.c.GetQuery("limit"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > s.maxPageSize {
+			p.reject("limit", "range", "must be an integer between 1 and "+strconv.Itoa(s.maxPageSize))
+		}
+		limit = n
+	}
+
+	return query, limit
+}
+
+// writeSearch responds with the limit best matches of query in si kept
+// by keep, which may be nil.
+func writeSearch[T any](c *gin.Context, si *searchIndex[T], query string, limit int, keep func(T) bool) {
+	index, err := si.get(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	hits, total := index.Search(query, limit, keep)
+
+	c.IndentedJSON(http.StatusOK, model.SearchResponse{Data: hits, Total: total})
+}
+
+// getDiseasesByDesc searches diseases by description or, when q looks
+// like the start of an ICD-10 code, by code.
+func (s *server) getDiseasesByDesc(c *gin.Context) {
+	p := listParams{c: c}
+	query, limit := s.searchQuery(&p)
+	if err := p.err(); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if prefix, ok := icd10.Prefix(query); ok {
+		s.searchDiseaseCodes(c, prefix, limit)
+		return
+	}
+
+	writeSearch(c, s.search.diseases, query, limit, nil)
+}
+
+// searchDiseaseCodes responds with the first limit diseases in code order
+// whose code starts with prefix. They are scored like the matches of the
+// text index: an exact match above a prefix match.
+func (s *server) searchDiseaseCodes(c *gin.Context, prefix string, limit int) {
+	ctx := c.Request.Context()
+	filter := store.DiseaseFilter{CodePrefix: prefix}
+
+	total, err := s.store.CountDiseases(ctx, filter)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	diseases, err := s.store.ListDiseases(ctx, filter, []store.SortField{{Field: "code"}}, 0, limit)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	hits := []search.Hit[model.Disease]{}
+	for _, disease := range diseases {
+		score := 2.0
+		if disease.Code == prefix {
+			score = 3
+		}
+
+		hits = append(hits, search.Hit[model.Disease]{
+			Item:       disease,
+			Score:      score,
+			Highlights: map[string]string{"code": "<mark>" + prefix + "</mark>" + disease.Code[len(prefix):]},
+		})
+	}
+
+	c.IndentedJSON(http.StatusOK, model.SearchResponse{Data: hits, Total: int(total)})
+}
+
+func (s *server) getSymptomsByDesc(c *gin.Context) {
+	p := listParams{c: c}
+	query, limit := s.searchQuery(&p)
+	if err := p.err(); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	writeSearch(c, s.search.symptoms, query, limit, nil)
+}
+
+// getMedicinesByDesc searches medicines by name. The formulation_id and
+// shape_id filters of the listing apply.
+func (s *server) getMedicinesByDesc(c *gin.Context) {
+	p := listParams{c: c}
+	query, limit := s.searchQuery(&p)
+	formulationID := p.id("formulation_id")
+	shapeID := p.id("shape_id")
+	if err := p.err(); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	writeSearch(c, s.search.medicines, query, limit, func(medicine model.Medicine) bool {
+		return (formulationID == 0 || medicine.FormulationObj.ID == formulationID) &&
+			(shapeID == 0 || medicine.FormulationObj.ShapeObj.ID == shapeID)
+	})
+}
+
+// getPatientsByName searches patients by first and last name. The gender
+// filter of the listing applies.
+func (s *server) getPatientsByName(c *gin.Context) {
+	p := listParams{c: c}
+	query, limit := s.searchQuery(&p)
+	gender := p.bool("gender")
+	if err := p.err(); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	writeSearch(c, s.search.patients, query, limit, func(patient model.Patient) bool {
+		return gender == nil || patient.Gender == *gender
+	})
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/catalog"
+	"github.com/jctorrestone/web-service-mr/internal/logging"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/openapi"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// specBuilder adds the operations of the API to an OpenAPI document.
+type specBuilder struct {
+	doc         *openapi.Document
+	maxPageSize int
+}
+
+// opBuilder describes one operation.
+type opBuilder struct {
+	s  *specBuilder
+	op *openapi.Operation
+}
+
+func (s *specBuilder) add(method, path, id, tag, summary string) *opBuilder {
+	op := &openapi.Operation{
+		Tags:        []string{tag},
+		Summary:     summary,
+		OperationID: id,
+		Responses: map[string]*openapi.Response{
+			"default": {
+				Description: "The request failed, see the problem details.",
+				Headers: map[string]*openapi.Header{
+					logging.Header: {
+						Description: "The ID of the request, also given as `request_id` in the problem.",
+						Schema:      &openapi.Schema{Type: "string"},
+					},
+				},
+				Content: map[string]*openapi.MediaType{apierr.ContentType: {Schema: openapi.Ref("Problem")}},
+			},
+		},
+	}
+	s.doc.Add(method, path, op)
+
+	b := &opBuilder{s: s, op: op}
+	if strings.Contains(path, "{id}") {
+		b.param("path", "id", &openapi.Schema{Type: "integer", Format: "int64"}, true, "")
+	}
+
+	return b
+}
+
+func (b *opBuilder) describe(description string) *opBuilder {
+	b.op.Description = description
+	return b
+}
+
+// auth requires a bearer token whose role grants one of permissions.
+func (b *opBuilder) auth(permissions ...auth.Permission) *opBuilder {
+	b.op.Security = []map[string][]string{{"bearer": {}}}
+
+	names := make([]string, len(permissions))
+	for i, permission := range permissions {
+		names[i] = "`" + string(permission) + "`"
+	}
+
+	note := "Requires the " + strings.Join(names, " or ") + " permission."
+	if b.op.Description != "" {
+		note = b.op.Description + "\n\n" + note
+	}
+	b.op.Description = note
+
+	return b
+}
+
+func (b *opBuilder) param(in, name string, schema *openapi.Schema, required bool, description string) *opBuilder {
+	b.op.Parameters = append(b.op.Parameters, &openapi.Parameter{
+		Name:        name,
+		In:          in,
+		Description: description,
+		Required:    required,
+		Schema:      schema,
+	})
+	return b
+}
+
+func (b *opBuilder) query(name string, schema *openapi.Schema, description string) *opBuilder {
+	return b.param("query", name, schema, false, description)
+}
+
+func (b *opBuilder) body(v any) *opBuilder {
+	b.op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(b.s.doc.SchemaOf(v))}
+	return b
+}
+
+// respond describes the successful response, whose body is v unless it is
+// nil. A *openapi.Schema is used as is.
+func (b *opBuilder) respond(status int, description string, v any) *opBuilder {
+	response := &openapi.Response{Description: description}
+
+	switch v := v.(type) {
+	case nil:
+	case *openapi.Schema:
+		response.Content = openapi.JSON(v)
+	default:
+		response.Content = openapi.JSON(b.s.doc.SchemaOf(v))
+	}
+
+	b.op.Responses[strconv.Itoa(status)] = response
+	return b
+}
+
+// paginated adds the pagination parameters of a listing of item and
+// describes its pages. keyset tells whether it supports cursors.
+func (b *opBuilder) paginated(item any, keyset bool) *opBuilder {
+	integer := &openapi.Schema{Type: "integer"}
+
+	b.query("page", integer, "Page number, from 0.")
+	b.query("page_size", integer, fmt.Sprintf("Rows per page, at most %d.", b.s.maxPageSize))
+
+	offsetPage := &openapi.Schema{AllOf: []*openapi.Schema{
+		openapi.Ref("Response"),
+		{Type: "object", Properties: map[string]*openapi.Schema{"data": openapi.ArrayOf(b.s.doc.SchemaOf(item))}},
+	}}
+	page := offsetPage
+
+	if keyset {
+		b.query("cursor", &openapi.Schema{Type: "string"},
+			"Switches to cursor pagination: empty for the first page, then the next_cursor of the previous one. Cannot be combined with page.")
+		b.query("total", &openapi.Schema{Type: "boolean"}, "With a cursor, false skips counting the rows.")
+
+		page = &openapi.Schema{OneOf: []*openapi.Schema{
+			offsetPage,
+			{AllOf: []*openapi.Schema{
+				openapi.Ref("CursorResponse"),
+				{Type: "object", Properties: map[string]*openapi.Schema{"data": openapi.ArrayOf(b.s.doc.SchemaOf(item))}},
+			}},
+		}}
+	}
+
+	b.respond(http.StatusOK, "A page of the listing. The Link header holds the first, prev, next and last pages.", page)
+	b.op.Responses["200"].Headers = map[string]*openapi.Header{
+		"Link": {Description: "RFC 8288 links to the neighboring pages.", Schema: &openapi.Schema{Type: "string"}},
+	}
+
+	return b
+}
+
+// sorted adds the sort parameter, one of fields or several of them.
+func (b *opBuilder) sorted(fields []string, fallback string) *opBuilder {
+	return b.query("sort", &openapi.Schema{Type: "string"}, fmt.Sprintf(
+		"Comma separated fields among %s, a leading - sorting in descending order. Defaults to %s. Not available with cursor pagination.",
+		strings.Join(fields, ", "), fallback))
+}
+
+// searched adds the q and limit parameters and describes the hits, whose
+// item is item.
+func (b *opBuilder) searched(item any) *opBuilder {
+	b.param("query", "q", &openapi.Schema{Type: "string"}, true, "Words to look for, ignoring case and accents.")
+	b.query("limit", &openapi.Schema{Type: "integer"}, fmt.Sprintf("Number of hits, at most %d.", b.s.maxPageSize))
+
+	hit := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+		"item":  b.s.doc.SchemaOf(item),
+		"score": {Type: "number"},
+		"highlights": {
+			Type:                 "object",
+			Description:          "The matching fields, HTML escaped, with the matching words in <mark> tags.",
+			AdditionalProperties: &openapi.Schema{Type: "string"},
+		},
+	}}
+
+	return b.respond(http.StatusOK, "The best hits and the number of matches.", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"data":  openapi.ArrayOf(hit),
+			"total": {Type: "integer"},
+		},
+	})
+}
+
+// imports describes the import route of a catalog whose rows have the
+// fields of row.
+func (b *opBuilder) imports(row any) *opBuilder {
+	b.query("dry_run", &openapi.Schema{Type: "boolean"}, "Validates the rows without importing them.")
+	b.op.RequestBody = &openapi.RequestBody{
+		Required: true,
+		Content: map[string]*openapi.MediaType{
+			"application/json": {Schema: openapi.ArrayOf(b.s.doc.SchemaOf(row))},
+			"text/csv":         {Schema: &openapi.Schema{Type: "string", Description: "A header naming the columns, then one row per line."}},
+		},
+	}
+	b.respond(http.StatusOK, "Dry run, or nothing to create.", model.ImportResult{})
+	return b.respond(http.StatusCreated, "The rows were imported.", model.ImportResult{})
+}
+
+func idQuery(description string) *openapi.Schema {
+	return &openapi.Schema{Type: "integer", Format: "int64", Description: description}
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// apiSpec describes every route registered by routes. `api-server
+// openapi check` fails when they disagree.
+func apiSpec(maxPageSize int) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "Medical records API",
+		Version: "1.0",
+		Description: "Patients, their medical records and the catalogs records refer to. " +
+			"Errors are RFC 7807 problem details. Every response carries an X-Request-ID header, " +
+			"copied from the request when it has a valid one.",
+	})
+	doc.Components.SecuritySchemes["bearer"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	doc.Tags = []openapi.Tag{
+		{Name: "auth", Description: "Access and refresh tokens."},
+		{Name: "patients"},
+		{Name: "records", Description: "Medical records. A primary record opens a case; secondary records follow it up."},
+		{Name: "catalogs", Description: "Diseases, symptoms, exams and vital signs records refer to."},
+		{Name: "medicines"},
+		{Name: "audit"},
+		{Name: "health", Description: "Probes and metrics for operators."},
+		{Name: "docs"},
+	}
+
+	// Every model is documented, including the ones no route returns
+	// directly.
+	for _, v := range []any{
+		apierr.Problem{}, model.Response{}, model.CursorResponse{}, model.SearchResponse{},
+		model.FullRecord{}, model.RecordVersion{}, model.RecordExam{}, model.RecordSymptom{}, model.Idx{},
+		model.Formulation{}, model.User{}, model.AuditEntry{},
+	} {
+		doc.SchemaOf(v)
+	}
+	doc.Components.Schemas["FullRecord"].Description = "A medical record with all its sections. " +
+		"`idx` holds the diagnoses, as diseases."
+
+	s := &specBuilder{doc: doc, maxPageSize: maxPageSize}
+	integer := &openapi.Schema{Type: "integer"}
+	date := &openapi.Schema{Type: "string", Format: "date"}
+
+	//AUTH
+	s.add("POST", "/auth/login", "login", "auth", "Log in").
+		body(loginRequest{}).
+		respond(http.StatusOK, "A token pair.", auth.TokenPair{})
+	s.add("POST", "/auth/refresh", "refresh", "auth", "Refresh an access token").
+		body(refreshRequest{}).
+		respond(http.StatusOK, "A new token pair.", auth.TokenPair{})
+
+	//PATIENTS
+	s.add("GET", "/patients", "listPatients", "patients", "List patients").
+		auth(auth.PatientsRead).
+		sorted(store.PatientSortFields, "last_name").
+		query("gender", &openapi.Schema{Type: "boolean"}, "").
+		paginated(model.Patient{}, true)
+	s.add("GET", "/patients/{id}", "getPatient", "patients", "Get a patient").
+		auth(auth.PatientsRead).
+		respond(http.StatusOK, "The patient.", model.Patient{})
+	s.add("GET", "/patients/search", "searchPatients", "patients", "Search patients by name").
+		auth(auth.PatientsRead).
+		query("gender", &openapi.Schema{Type: "boolean"}, "").
+		searched(model.Patient{})
+	s.add("POST", "/patients", "createPatient", "patients", "Register a patient").
+		auth(auth.PatientsWrite).
+		body(patientRequest{}).
+		respond(http.StatusCreated, "The new patient.", model.Patient{})
+	s.add("PUT", "/patients/{id}", "replacePatient", "patients", "Correct a patient").
+		auth(auth.PatientsWrite).
+		body(patientRequest{}).
+		respond(http.StatusOK, "The corrected patient.", model.Patient{})
+	s.add("PATCH", "/patients/{id}", "updatePatient", "patients", "Correct some fields of a patient").
+		auth(auth.PatientsWrite).
+		body(patientPatch{}).
+		respond(http.StatusOK, "The corrected patient.", model.Patient{})
+	s.add("DELETE", "/patients/{id}", "deletePatient", "patients", "Delete a patient").
+		describe("Hides the patient from the API. Refused with a 409 while medical records refer to them.").
+		auth(auth.PatientsDelete).
+		respond(http.StatusNoContent, "The patient was deleted.", nil)
+
+	//RECORDS
+	records := func(b *opBuilder) *opBuilder {
+		return b.auth(auth.RecordsRead).
+			sorted(store.RecordSortFields, "-rdate").
+			query("category", &openapi.Schema{Type: "string", Enum: []string{"primary", "secondary", "all"}},
+				"Defaults to primary. Secondary records show the patient of their primary record.").
+			query("from", date, "First day, inclusive.").
+			query("to", date, "Last day, inclusive.").
+			query("first_name", &openapi.Schema{Type: "string"}, "Part of the patient's first name.").
+			query("last_name", &openapi.Schema{Type: "string"}, "Part of the patient's last name.").
+			query("patient_id", idQuery(""), "").
+			query("disease_id", idQuery(""), "Records diagnosing the disease.").
+			paginated(model.Record{}, true)
+	}
+	records(s.add("GET", "/records", "listRecords", "records", "List medical records"))
+	records(s.add("GET", "/records/search", "searchRecords", "records", "Search medical records by patient").
+		query("q", &openapi.Schema{Type: "string"}, "Part of the patient's first or last name."))
+	s.add("GET", "/records/{id}", "getRecord", "records", "Get a medical record").
+		describe("Vital signs outside their normal range are flagged low or high.").
+		auth(auth.RecordsRead).
+		query("version", integer, "An earlier version of the record, from 1.").
+		respond(http.StatusOK, "The record.", model.FullRecord{})
+	s.add("GET", "/records/{id}/versions", "listRecordVersions", "records", "List the versions of a medical record").
+		auth(auth.RecordsRead).
+		respond(http.StatusOK, "Every version, oldest first, with the sections each one changed.", []model.RecordVersion{})
+	s.add("GET", "/sec-records/{id}", "listSecondaryRecords", "records", "List the secondary records of a primary record").
+		auth(auth.RecordsRead).
+		respond(http.StatusOK, "The secondary records.", []model.FullRecord{})
+	s.add("POST", "/records", "createRecord", "records", "Create a medical record").
+		describe("Vital signs need `records:vital-signs`, disease history, symptoms and exams `records:write`, "+
+			"diagnoses and treatments `records:diagnose`.").
+		auth(auth.VitalSignsWrite, auth.RecordsWrite, auth.Diagnose).
+		body(model.FullRecord{}).
+		respond(http.StatusCreated, "The new record.", model.Record{})
+	s.add("PUT", "/records/{id}", "amendRecord", "records", "Amend a medical record").
+		describe("Stores the current record as a version and replaces it. Each changed section needs its "+
+			"permission, as when creating a record. The category, patient and primary record may be left out "+
+			"and cannot change. A version other than the current one is refused with a 409.").
+		auth(auth.VitalSignsWrite, auth.RecordsWrite, auth.Diagnose).
+		body(model.FullRecord{}).
+		respond(http.StatusOK, "The amended record.", model.FullRecord{})
+
+	//CATALOGS
+	s.add("GET", "/diseases", "listDiseases", "catalogs", "List diseases").
+		sorted(store.DiseaseSortFields, "description").
+		query("code_prefix", &openapi.Schema{Type: "string"}, "Start of the ICD-10 code, such as J0 or J02.").
+		query("chapter", integer, "ICD-10 chapter number.").
+		paginated(model.Disease{}, false)
+	s.add("GET", "/diseases/search", "searchDiseases", "catalogs", "Search diseases").
+		describe("A q looking like the start of an ICD-10 code lists the diseases whose code starts with it.").
+		searched(model.Disease{})
+	s.add("POST", "/diseases", "createDisease", "catalogs", "Add a disease").
+		auth(auth.CatalogsWrite).
+		body(model.Disease{}).
+		respond(http.StatusCreated, "The new disease.", model.Disease{})
+	s.add("GET", "/symptoms", "listSymptoms", "catalogs", "List symptoms").
+		paginated(model.Symptom{}, false)
+	s.add("GET", "/symptoms/search", "searchSymptoms", "catalogs", "Search symptoms").
+		searched(model.Symptom{})
+	s.add("POST", "/symptoms", "createSymptom", "catalogs", "Add a symptom").
+		auth(auth.CatalogsWrite).
+		body(model.Symptom{}).
+		respond(http.StatusCreated, "The new symptom.", model.Symptom{})
+	s.add("GET", "/exams", "listExams", "catalogs", "List exams").
+		respond(http.StatusOK, "Every exam.", []model.Exam{})
+	s.add("GET", "/vital-signs", "listVitalSigns", "catalogs", "List vital signs").
+		respond(http.StatusOK, "Every vital sign with its reference range.", []model.VitalSign{})
+	s.add("PUT", "/vital-signs/{id}/range", "setVitalSignRange", "catalogs", "Set the reference range of a vital sign").
+		auth(auth.CatalogsWrite).
+		body(model.VitalSignRange{}).
+		respond(http.StatusOK, "The vital sign.", model.VitalSign{})
+	s.add("DELETE", "/vital-signs/{id}/range", "deleteVitalSignRange", "catalogs", "Remove the reference range of a vital sign").
+		auth(auth.CatalogsWrite).
+		respond(http.StatusNoContent, "The range was removed.", nil)
+
+	importRows := map[string]any{
+		catalog.Diseases: struct {
+			Description string `json:"description" binding:"required,max=255"`
+			Code        string `json:"code"`
+		}{},
+		catalog.Symptoms: struct {
+			Description string `json:"description" binding:"required,max=255"`
+		}{},
+		catalog.Exams: struct {
+			Description string `json:"description" binding:"required,max=255"`
+		}{},
+		catalog.Medicines: struct {
+			Name          string `json:"name" binding:"required,max=255"`
+			FormulationID int64  `json:"formulation_id" binding:"required,gt=0"`
+			Dose          int64  `json:"dose" binding:"required,gt=0"`
+		}{},
+	}
+	for _, name := range []string{catalog.Diseases, catalog.Symptoms, catalog.Exams, catalog.Medicines} {
+		tag := "catalogs"
+		if name == catalog.Medicines {
+			tag = "medicines"
+		}
+
+		s.add("POST", "/"+name+"/import", "import"+strings.ToUpper(name[:1])+name[1:], tag, "Import "+name+" in bulk").
+			describe("Rows duplicating an existing row or an earlier one are skipped. If another row is invalid, " +
+				"nothing is imported and the problem lists every invalid value, e.g. `[3].dose`.").
+			auth(auth.CatalogsWrite).
+			imports(importRows[name])
+	}
+
+	//MEDICINES
+	s.add("GET", "/formulations", "listFormulations", "medicines", "List formulations").
+		respond(http.StatusOK, "Every formulation.", []model.Formulation{})
+	s.add("GET", "/medicines", "listMedicines", "medicines", "List medicines").
+		sorted(store.MedicineSortFields, "name").
+		query("formulation_id", idQuery(""), "").
+		query("shape_id", idQuery(""), "").
+		paginated(model.Medicine{}, true)
+	s.add("GET", "/medicines/search", "searchMedicines", "medicines", "Search medicines by name").
+		query("formulation_id", idQuery(""), "").
+		query("shape_id", idQuery(""), "").
+		searched(model.Medicine{})
+	s.add("POST", "/medicines", "createMedicine", "medicines", "Add a medicine").
+		auth(auth.CatalogsWrite).
+		body(model.Medicine{}).
+		respond(http.StatusCreated, "The new medicine.", model.Medicine{})
+
+	//AUDIT
+	s.add("GET", "/audit", "listAudit", "audit", "Read the audit log").
+		describe("Every access to patient and record data, allowed or not, newest first.").
+		auth(auth.AuditRead).
+		query("patient_id", idQuery(""), "").
+		query("user_id", idQuery(""), "").
+		query("from", &openapi.Schema{Type: "string"}, "A date (YYYY-MM-DD) or an RFC 3339 timestamp, inclusive.").
+		query("to", &openapi.Schema{Type: "string"}, "A date (YYYY-MM-DD) or an RFC 3339 timestamp, inclusive.").
+		paginated(model.AuditEntry{}, false)
+
+	//HEALTH
+	s.add("GET", "/healthz", "getHealthz", "health", "Liveness probe").
+		describe("Succeeds while the process serves requests, whatever the state of the database.").
+		respond(http.StatusOK, "The server is up.", health{})
+	s.add("GET", "/readyz", "getReadyz", "health", "Readiness probe").
+		describe("Fails with 503 `unavailable` while the database cannot be reached.").
+		respond(http.StatusOK, "The server can answer requests.", health{})
+	s.add("GET", "/metrics", "getMetrics", "health", "Prometheus metrics").
+		describe("Request counts and latencies by route and status, database pool statistics and "+
+			"domain counters, in the Prometheus text format. The route is public; the counters reveal "+
+			"clinical activity, so deployments restrict it to the scraper.").
+		respond(http.StatusOK, "The metrics.", nil)
+
+	//DOCS
+	s.add("GET", "/openapi.json", "getOpenAPI", "docs", "This document").
+		respond(http.StatusOK, "The OpenAPI document.", &openapi.Schema{Type: "object"})
+	s.add("GET", "/docs", "getDocs", "docs", "Browse this document").
+		respond(http.StatusOK, "An HTML page rendering the OpenAPI document.", nil)
+	s.add("GET", "/docs/redoc.standalone.js", "getDocsScript", "docs", "The Redoc bundle of the docs page").
+		respond(http.StatusOK, "The Redoc standalone JavaScript bundle.", nil)
+
+	return doc
+}
+
+func (s *server) getOpenAPI(c *gin.Context) {
+	specOnce.Do(func() {
+		specJSON, _ = json.MarshalIndent(apiSpec(s.maxPageSize), "", "  ")
+	})
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", specJSON)
+}
+
+// docsPage renders /openapi.json with the Redoc bundle served by the
+// api-server itself, so the page needs no access to a CDN.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Medical records API</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+<redoc spec-url="/openapi.json"></redoc>
+<script src="/docs/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+func (s *server) getDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+// redocScript is the Redoc 2.0.0-rc.59 standalone bundle, MIT licensed,
+// vendored from https://cdn.jsdelivr.net/npm/redoc@2.0.0-rc.59/bundles/redoc.standalone.js.
+//
+//go:embed assets/redoc.standalone.js
+var redocScript []byte
+
+func (s *server) getDocsScript(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "text/javascript; charset=utf-8", redocScript)
+}
+
+// undocumentedRoutes compares the routes registered on router with the
+// operations of doc. It returns the routes missing from doc and the
+// operations no route serves.
+func undocumentedRoutes(router *gin.Engine, doc *openapi.Document) (missing, stale []string) {
+	var registered []string
+	for _, route := range router.Routes() {
+		registered = append(registered, openapi.Route{Method: route.Method, Path: openapi.PathOf(route.Path)}.String())
+	}
+
+	var documented []string
+	for _, route := range doc.Routes() {
+		documented = append(documented, route.String())
+	}
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			missing = append(missing, route)
+		}
+	}
+
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			stale = append(stale, route)
+		}
+	}
+
+	slices.Sort(missing)
+
+	return missing, stale
+}
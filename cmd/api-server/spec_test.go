@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"github.com/jctorrestone/web-service-mr/internal/openapi"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// TestSpecCoversRoutes fails when a route is registered but not in the
+// OpenAPI document, or documented but no longer registered.
+func TestSpecCoversRoutes(t *testing.T) {
+	router := gin.New()
+	(&server{}).routes(router)
+
+	missing, stale := undocumentedRoutes(router, apiSpec(config.Default().Server.MaxPageSize))
+
+	for _, route := range missing {
+		t.Errorf("route not documented in apiSpec: %s", route)
+	}
+	for _, route := range stale {
+		t.Errorf("documented route not registered: %s", route)
+	}
+}
+
+func TestUndocumentedRoutes(t *testing.T) {
+	router := gin.New()
+	(&server{}).routes(router)
+	router.GET("/undocumented/:id", func(*gin.Context) {})
+
+	doc := apiSpec(config.Default().Server.MaxPageSize)
+	doc.Add("DELETE", "/gone/{id}", &openapi.Operation{OperationID: "deleteGone"})
+
+	missing, stale := undocumentedRoutes(router, doc)
+
+	if want := []string{"GET /undocumented/{id}"}; !slices.Equal(missing, want) {
+		t.Errorf("missing = %q, want %q", missing, want)
+	}
+	if want := []string{"DELETE /gone/{id}"}; !slices.Equal(stale, want) {
+		t.Errorf("stale = %q, want %q", stale, want)
+	}
+}
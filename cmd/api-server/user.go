@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+const userUsage = `usage: api-server user [flags] add <username>
+
+Creates an account. The password is read from MR_USER_PASSWORD or, if
+unset, from the first line of standard input.
+
+flags:
+`
+
+// runUser implements the user subcommand.
+func runUser(args []string) {
+	flags := flag.NewFlagSet("user", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), userUsage)
+		flags.PrintDefaults()
+	}
+
+	role := flags.String("role", auth.RoleReception, "role of the new user: admin, physician, nurse or reception")
+
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() != 2 || flags.Arg(0) != "add" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if !auth.ValidRole(*role) {
+		log.Fatalf("unknown role %q", *role)
+	}
+
+	if cfg.Store == "memory" {
+		log.Fatal("accounts cannot be created in the memory store")
+	}
+
+	password, ok := os.LookupEnv("MR_USER_PASSWORD")
+	if !ok {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatal(err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < 8 {
+		log.Fatal("password must be at least 8 characters long")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user := model.User{Username: flags.Arg(1), PasswordHash: hash, Role: *role}
+
+	if err := openStore(cfg).CreateUser(context.Background(), &user); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			log.Fatalf("user %q already exists", user.Username)
+		}
+		log.Fatal(err)
+	}
+
+	log.Printf("created %s %q with id %d", user.Role, user.Username, user.ID)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// registerValidations sets up the validator behind gin's binding tags:
+// violations are reported under the JSON name of the field, and rules
+// spanning several fields of a model are registered as struct
+// validations.
+func registerValidations() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+
+		if name == "" {
+			return field.Name
+		}
+
+		return name
+	})
+
+	v.RegisterStructValidation(validateRecord, model.Record{})
+}
+
+// validateRecord requires primary records to name their patient.
+func validateRecord(sl validator.StructLevel) {
+	record := sl.Current().Interface().(model.Record)
+
+	if record.Category == "primary" && record.PatientObj.ID <= 0 {
+		sl.ReportError(record.PatientObj.ID, "patient.id", "PatientObj.ID", "required", "")
+	}
+}
+
+// decodeRecord binds a medical record from the request body and returns
+// the binding tag violations. It reports malformed bodies itself and
+// returns false.
+func decodeRecord(c *gin.Context, fullRecord *model.FullRecord) ([]apierr.FieldError, bool) {
+	err := c.ShouldBindJSON(fullRecord)
+	if err == nil {
+		return nil, true
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		apierr.Write(c, apierr.Bind(err))
+		return nil, false
+	}
+
+	return apierr.Bind(err).Fields, true
+}
+
+// checkRecord adds the rows fullRecord refers to but do not exist, and
+// the vital signs beyond their hard limits, to fields and reports them
+// all at once, returning false, if there are any.
+func (s *server) checkRecord(c *gin.Context, fullRecord model.FullRecord, fields []apierr.FieldError) bool {
+	invalid, err := s.store.InvalidReferences(c.Request.Context(), fullRecord)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return false
+	}
+
+	ranged, err := s.vitalSignRanges(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return false
+	}
+
+	reported := map[string]bool{}
+	for _, field := range fields {
+		reported[field.Field] = true
+	}
+
+	for _, ref := range invalid {
+		// A missing id has already been reported as required.
+		if reported[ref.Field] {
+			continue
+		}
+
+		fields = append(fields, apierr.FieldError{
+			Field:   ref.Field,
+			Code:    "exists",
+			Message: fmt.Sprintf("no such %s: %d", ref.What, ref.ID),
+		})
+	}
+
+	fields = append(fields, implausibleVitalSigns(fullRecord.VitalSigns, ranged)...)
+
+	if len(fields) > 0 {
+		apierr.Write(c, apierr.Validation("medical record is invalid", fields...))
+		return false
+	}
+
+	return true
+}
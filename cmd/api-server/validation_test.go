@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// TestRecordReferences checks that every invalid field of a record is
+// reported in one problem response, references included.
+func TestRecordReferences(t *testing.T) {
+	api := newTestAPI(t)
+
+	before := decode[model.Response](t, api.do(auth.RoleNurse, "GET", "/records?category=all", nil), http.StatusOK)
+
+	w := api.do(auth.RolePhysician, "POST", "/records", map[string]any{
+		"record":           map[string]any{"category": "primary", "patient": map[string]any{"id": 99}, "rdate": "2024-06-01"},
+		"diseases_history": []any{map[string]any{"disease_id": 1}, map[string]any{"disease_id": 98}},
+		"symptoms":         []any{map[string]any{"id": 97}},
+		"vital_signs":      []any{map[string]any{"vital_sign_id": 0, "value": 1}, map[string]any{"vital_sign_id": 96, "value": 1}},
+		"idx":              []any{map[string]any{"id": 95}},
+		"exams":            []any{map[string]any{"id": 1}, map[string]any{"id": 94}},
+		"treatments":       []any{map[string]any{"medicine_id": 93, "quantity": 1, "dosage": 1, "frequency": 8}},
+	})
+
+	got := decode[problem](t, w, http.StatusBadRequest)
+	want := []string{
+		"vital_signs[0].vital_sign_id:required",
+		"record.patient.id:exists",
+		"diseases_history[1].disease_id:exists",
+		"symptoms[0].id:exists",
+		"vital_signs[1].vital_sign_id:exists",
+		"idx[0].id:exists",
+		"exams[1].id:exists",
+		"treatments[0].medicine_id:exists",
+	}
+	if fields := got.fields(); !slices.Equal(fields, want) {
+		t.Errorf("errors = %q, want %q", fields, want)
+	}
+
+	after := decode[model.Response](t, api.do(auth.RoleNurse, "GET", "/records?category=all", nil), http.StatusOK)
+	if after.Total != before.Total {
+		t.Errorf("records = %d, want the %d there were", after.Total, before.Total)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// Flags set on vital sign values outside their normal range.
+const (
+	flagLow  = "low"
+	flagHigh = "high"
+)
+
+func (s *server) putVitalSignRange(c *gin.Context) {
+	var vitalSignRange model.VitalSignRange
+
+	if !bindJSON(c, &vitalSignRange) {
+		return
+	}
+
+	if fields := rangeViolations(vitalSignRange); len(fields) > 0 {
+		apierr.Write(c, apierr.Validation("reference range is inconsistent", fields...))
+		return
+	}
+
+	s.setVitalSignRange(c, &vitalSignRange)
+}
+
+func (s *server) deleteVitalSignRange(c *gin.Context) {
+	s.setVitalSignRange(c, nil)
+}
+
+func (s *server) setVitalSignRange(c *gin.Context, vitalSignRange *model.VitalSignRange) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Write(c, apierr.NotFound("no such vital sign"))
+		return
+	}
+
+	if err := s.store.SetVitalSignRange(ctx, id, vitalSignRange); err != nil {
+		apierr.Write(c, storeError(err, "vital sign"))
+		return
+	}
+
+	if vitalSignRange == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	vitalSigns, err := s.vitalSignRanges(ctx)
+	if err != nil {
+		apierr.Write(c, apierr.Internal(err))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, vitalSigns[id])
+}
+
+// rangeViolations checks that the bounds of a reference range are in
+// order: Min <= NormalLow <= NormalHigh <= Max, with Min < Max.
+func rangeViolations(r model.VitalSignRange) []apierr.FieldError {
+	var fields []apierr.FieldError
+
+	bounds := []struct {
+		field string
+		value *float64
+	}{
+		{"min", r.Min},
+		{"normal_low", r.NormalLow},
+		{"normal_high", r.NormalHigh},
+		{"max", r.Max},
+	}
+
+	for i, upper := range bounds {
+		for _, lower := range bounds[:i] {
+			if lower.value == nil || upper.value == nil || *lower.value <= *upper.value {
+				continue
+			}
+
+			fields = append(fields, apierr.FieldError{
+				Field:   upper.field,
+				Code:    "order",
+				Message: "must not be less than " + lower.field,
+			})
+		}
+	}
+
+	if r.Min != nil && r.Max != nil && *r.Min == *r.Max {
+		fields = append(fields, apierr.FieldError{Field: "max", Code: "order", Message: "must be greater than min"})
+	}
+
+	return fields
+}
+
+// vitalSignRanges returns the vital signs that have a reference range,
+// by id.
+func (s *server) vitalSignRanges(ctx context.Context) (map[int64]model.VitalSign, error) {
+	vitalSigns, err := s.store.ListVitalSigns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ranged := map[int64]model.VitalSign{}
+
+	for _, vitalSign := range vitalSigns {
+		if vitalSign.Range != nil {
+			ranged[vitalSign.ID] = vitalSign
+		}
+	}
+
+	return ranged, nil
+}
+
+// implausibleVitalSigns reports the values that fall outside the hard
+// limits of their vital sign.
+func implausibleVitalSigns(vitalSigns []model.RecordVitalSign, ranged map[int64]model.VitalSign) []apierr.FieldError {
+	var fields []apierr.FieldError
+
+	for i, value := range vitalSigns {
+		vitalSign, ok := ranged[value.VitalSignID]
+		if !ok {
+			continue
+		}
+
+		r := vitalSign.Range
+		if (r.Min == nil || value.Value >= *r.Min) && (r.Max == nil || value.Value <= *r.Max) {
+			continue
+		}
+
+		var message string
+		switch {
+		case r.Min == nil:
+			message = fmt.Sprintf("must be at most %g %s", *r.Max, vitalSign.UnitObj.Symbol)
+		case r.Max == nil:
+			message = fmt.Sprintf("must be at least %g %s", *r.Min, vitalSign.UnitObj.Symbol)
+		default:
+			message = fmt.Sprintf("must be between %g and %g %s", *r.Min, *r.Max, vitalSign.UnitObj.Symbol)
+		}
+
+		fields = append(fields, apierr.FieldError{
+			Field:   fmt.Sprintf("vital_signs[%d].value", i),
+			Code:    "range",
+			Message: message,
+		})
+	}
+
+	return fields
+}
+
+// flagVitalSigns marks the vital signs of fullRecord that fall outside
+// their normal range.
+func (s *server) flagVitalSigns(ctx context.Context, fullRecord *model.FullRecord) error {
+	if len(fullRecord.VitalSigns) == 0 {
+		return nil
+	}
+
+	ranged, err := s.vitalSignRanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range fullRecord.VitalSigns {
+		value := &fullRecord.VitalSigns[i]
+		value.Flag = ""
+
+		vitalSign, ok := ranged[value.VitalSignID]
+		if !ok {
+			continue
+		}
+
+		switch r := vitalSign.Range; {
+		case r.NormalLow != nil && value.Value < *r.NormalLow:
+			value.Flag = flagLow
+		case r.NormalHigh != nil && value.Value > *r.NormalHigh:
+			value.Flag = flagHigh
+		}
+	}
+
+	return nil
+}
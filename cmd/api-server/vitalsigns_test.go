@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+func TestImplausibleVitalSigns(t *testing.T) {
+	celsius := model.Unit{Symbol: "°C"}
+	ranged := map[int64]model.VitalSign{
+		1: {ID: 1, UnitObj: celsius, Range: &model.VitalSignRange{Min: bound(25), Max: bound(45)}},
+		2: {ID: 2, UnitObj: celsius, Range: &model.VitalSignRange{Min: bound(0)}},
+		3: {ID: 3, UnitObj: celsius, Range: &model.VitalSignRange{Max: bound(100)}},
+		4: {ID: 4, UnitObj: celsius, Range: &model.VitalSignRange{NormalLow: bound(10), NormalHigh: bound(20)}},
+	}
+
+	tests := []struct {
+		id    int64
+		value float64
+		// want is the error message, empty if the value is plausible.
+		want string
+	}{
+		{1, 24.9, "must be between 25 and 45 °C"},
+		{1, 25, ""},
+		{1, 45, ""},
+		{1, 45.1, "must be between 25 and 45 °C"},
+		{2, -0.5, "must be at least 0 °C"},
+		{2, 1e6, ""},
+		{3, 100, ""},
+		{3, 100.5, "must be at most 100 °C"},
+		// Normal bounds only flag values.
+		{4, 1000, ""},
+		// Vital signs without a range accept anything.
+		{5, -1000, ""},
+	}
+
+	for _, tt := range tests {
+		fields := implausibleVitalSigns([]model.RecordVitalSign{{VitalSignID: tt.id, Value: tt.value}}, ranged)
+
+		var got string
+		if len(fields) > 0 {
+			got = fields[0].Message
+			if fields[0].Field != "vital_signs[0].value" || fields[0].Code != "range" {
+				t.Errorf("vital sign %d at %g: error %+v, want a range error on vital_signs[0].value", tt.id, tt.value, fields[0])
+			}
+		}
+		if got != tt.want || len(fields) > 1 {
+			t.Errorf("vital sign %d at %g: errors %+v, want %q", tt.id, tt.value, fields, tt.want)
+		}
+	}
+}
+
+func TestRangeViolations(t *testing.T) {
+	tests := []struct {
+		name string
+		r    model.VitalSignRange
+		want []string
+	}{
+		{"in order", model.VitalSignRange{Min: bound(25), NormalLow: bound(36), NormalHigh: bound(37.5), Max: bound(45)}, nil},
+		{"normal bounds equal", model.VitalSignRange{NormalLow: bound(36), NormalHigh: bound(36)}, nil},
+		{"normal bounds on the limits", model.VitalSignRange{Min: bound(25), NormalLow: bound(25), NormalHigh: bound(45), Max: bound(45)}, nil},
+		{"no bounds", model.VitalSignRange{}, nil},
+		{"min equal to max", model.VitalSignRange{Min: bound(30), Max: bound(30)}, []string{"max"}},
+		{"normal low under min", model.VitalSignRange{Min: bound(25), NormalLow: bound(20)}, []string{"normal_low"}},
+		{"normal bounds swapped", model.VitalSignRange{NormalLow: bound(37.5), NormalHigh: bound(36)}, []string{"normal_high"}},
+		{"max under everything", model.VitalSignRange{Min: bound(25), NormalLow: bound(36), Max: bound(20)}, []string{"max", "max"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, field := range rangeViolations(tt.r) {
+			got = append(got, field.Field)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: errors on %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFlagVitalSigns(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	// Vital sign 1 is the temperature, normal between 36 and 37.5.
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{35.9, flagLow},
+		{36, ""},
+		{37.5, ""},
+		{37.6, flagHigh},
+	}
+	for _, tt := range tests {
+		fullRecord := model.FullRecord{VitalSigns: []model.RecordVitalSign{{VitalSignID: 1, Value: tt.value, Flag: "stale"}}}
+		if err := api.server.flagVitalSigns(ctx, &fullRecord); err != nil {
+			t.Fatal(err)
+		}
+		if got := fullRecord.VitalSigns[0].Flag; got != tt.want {
+			t.Errorf("temperature %g flagged %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestVitalSignRangeRoutes changes and then removes the range of the
+// temperature, checking how records are validated and flagged.
+func TestVitalSignRangeRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	postTemperature := func(value float64) *model.FullRecord {
+		t.Helper()
+
+		w := api.do(auth.RoleNurse, "POST", "/records", map[string]any{
+			"record":      map[string]any{"category": "primary", "patient": map[string]any{"id": 2}, "rdate": "2024-06-01"},
+			"vital_signs": []any{map[string]any{"vital_sign_id": 1, "value": value}},
+		})
+		if w.Code == http.StatusBadRequest {
+			return nil
+		}
+
+		created := decode[model.Record](t, w, http.StatusCreated)
+		fullRecord := decode[model.FullRecord](t, api.do(auth.RoleNurse, "GET", fmt.Sprintf("/records/%d", created.ID), nil), http.StatusOK)
+		return &fullRecord
+	}
+
+	if postTemperature(50) != nil {
+		t.Error("a temperature of 50 °C was accepted under the seeded range")
+	}
+
+	updated := decode[model.VitalSign](t, api.do(auth.RolePhysician, "PUT", "/vital-signs/1/range",
+		map[string]any{"min": 20, "normal_low": 35, "normal_high": 38, "max": 50}), http.StatusOK)
+	if r := updated.Range; r == nil || *r.Min != 20 || *r.Max != 50 {
+		t.Errorf("PUT range = %+v", updated.Range)
+	}
+	if r := postTemperature(50); r == nil || r.VitalSigns[0].Flag != flagHigh {
+		t.Errorf("50 °C under the new range = %+v, want it accepted and flagged high", r)
+	}
+	if r := postTemperature(37.8); r == nil || r.VitalSigns[0].Flag != "" {
+		t.Errorf("37.8 °C under the new range = %+v, want it normal", r)
+	}
+
+	inconsistent := decode[problem](t, api.do(auth.RolePhysician, "PUT", "/vital-signs/1/range",
+		map[string]any{"min": 40, "max": 30}), http.StatusBadRequest)
+	if fields := inconsistent.fields(); !slices.Equal(fields, []string{"max:order"}) {
+		t.Errorf("inconsistent range errors = %q", fields)
+	}
+
+	// Removing the range, as SetVitalSignRange does with nil, lifts both
+	// the limits and the flags.
+	if w := api.do(auth.RolePhysician, "DELETE", "/vital-signs/1/range", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d: %s", w.Code, w.Body)
+	}
+	if r := postTemperature(60); r == nil || r.VitalSigns[0].Flag != "" {
+		t.Errorf("60 °C without a range = %+v, want it accepted and not flagged", r)
+	}
+
+	vitalSigns := decode[[]model.VitalSign](t, api.do("", "GET", "/vital-signs", nil), http.StatusOK)
+	if i := slices.IndexFunc(vitalSigns, func(v model.VitalSign) bool { return v.ID == 1 }); i < 0 || vitalSigns[i].Range != nil {
+		t.Errorf("vital signs = %+v, want the temperature without a range", vitalSigns)
+	}
+
+	// Removing it again is harmless; unknown vital signs are not found.
+	if w := api.do(auth.RolePhysician, "DELETE", "/vital-signs/1/range", nil); w.Code != http.StatusNoContent {
+		t.Errorf("second DELETE status = %d, want 204", w.Code)
+	}
+	for _, method := range []string{"PUT", "DELETE"} {
+		var body any
+		if method == "PUT" {
+			body = map[string]any{"min": 1}
+		}
+		if w := api.do(auth.RolePhysician, method, "/vital-signs/99/range", body); w.Code != http.StatusNotFound {
+			t.Errorf("%s of an unknown vital sign: status = %d, want 404", method, w.Code)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package apierr defines the errors the api-server reports to clients and
+// writes them as RFC 7807 problem details.
+//
+// Handlers return one of the typed constructors below; anything else is
+// treated as an internal error, logged, and hidden from the client.
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jctorrestone/web-service-mr/internal/logging"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// Code is a stable, machine readable error identifier.
+type Code string
+
+const (
+	CodeValidation   Code = "validation_failed"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeInternal     Code = "internal_error"
+	CodeUnavailable  Code = "unavailable"
+)
+
+// FieldError describes why a single request field was rejected. Field is
+// the dotted JSON path of the value, e.g. "record.patient.id".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error meant to be shown to the client.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields []FieldError
+	// Err is the underlying cause. It is logged but never sent.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Validation reports a request that is malformed or breaks a business
+// rule.
+func Validation(detail string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Detail: detail, Fields: fields}
+}
+
+// NotFound reports a missing resource.
+func NotFound(detail string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Detail: detail}
+}
+
+// Conflict reports a request that clashes with the current state of a
+// resource.
+func Conflict(detail string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: detail}
+}
+
+// Unauthorized reports missing or invalid credentials.
+func Unauthorized(detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Detail: detail}
+}
+
+// Forbidden reports an authenticated user lacking a permission.
+func Forbidden(detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Detail: detail}
+}
+
+// Internal wraps an unexpected failure. Its cause is not disclosed.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: "internal server error", Err: err}
+}
+
+// Unavailable reports a dependency the server cannot reach, such as the
+// database. Like Internal, its cause is logged but not disclosed.
+func Unavailable(detail string, err error) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Detail: detail, Err: err}
+}
+
+// Problem is an RFC 7807 problem details body, extended with the error
+// code and the rejected fields.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// RequestID is the X-Request-ID of the request, to find its log
+	// lines.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Write responds with err as a problem and aborts the handler chain.
+// Errors that are not an *Error are reported as internal errors. Server
+// errors are logged with their cause, client errors with their code.
+func Write(c *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal(err)
+	}
+
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx)
+	if e.Status >= http.StatusInternalServerError {
+		logger.ErrorContext(ctx, "request failed",
+			"status", e.Status, "code", e.Code, "error", e)
+	} else {
+		args := []any{"status", e.Status, "code", e.Code, "detail", e.Detail}
+		if len(e.Fields) > 0 {
+			args = append(args, "fields", fieldNames(e.Fields))
+		}
+		logger.InfoContext(ctx, "request rejected", args...)
+	}
+
+	c.Header("Content-Type", ContentType)
+	c.IndentedJSON(e.Status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  c.Request.URL.Path,
+		Code:      e.Code,
+		Errors:    e.Fields,
+		RequestID: logging.RequestID(ctx),
+	})
+	c.Abort()
+}
+
+// fieldNames returns the paths of the rejected fields; their messages
+// may quote the values.
+func fieldNames(fields []FieldError) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Field
+	}
+
+	return names
+}
+
+// Bind translates an error returned by gin's binding into a validation
+// error listing every rejected field.
+func Bind(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, len(validationErrs))
+		for i, fe := range validationErrs {
+			fields[i] = FieldError{Field: fieldPath(fe), Code: fe.Tag(), Message: fieldMessage(fe)}
+		}
+
+		return Validation("request has invalid fields", fields...)
+	case errors.As(err, &typeErr):
+		return Validation("request has invalid fields", FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("must be a JSON %s", jsonType(typeErr.Type.Kind().String())),
+		})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Validation("request body is not valid JSON")
+	}
+
+	return Validation(err.Error())
+}
+
+// fieldPath drops the name of the top level struct from the namespace of
+// fe, leaving the path of the field within the request body.
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+
+	return path
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "unique":
+		return "must not contain duplicates"
+	case "datetime":
+		if fe.Param() == time.DateOnly {
+			return "must be a date formatted as YYYY-MM-DD"
+		}
+		return "must be formatted as " + fe.Param()
+	}
+
+	return "failed the " + fe.Tag() + " check"
+}
+
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice", kind == "array":
+		return "array"
+	case kind == "struct", kind == "map":
+		return "object"
+	}
+
+	return kind
+}
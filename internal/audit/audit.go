@@ -0,0 +1,146 @@
+// Package audit records who accessed or changed patient and medical
+// record data.
+package audit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/auth"
+	"github.com/jctorrestone/web-service-mr/internal/logging"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionList   = "list"
+	ActionSearch = "search"
+	ActionRead   = "read"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Outcomes recorded in the audit log.
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+const (
+	resourceIDKey = "audit.resource_id"
+	patientIDKey  = "audit.patient_id"
+)
+
+// Appender stores audit entries. Implementations must never update or
+// delete existing entries.
+type Appender interface {
+	AppendAudit(ctx context.Context, entry *model.AuditEntry) error
+}
+
+// SetResourceID records the id of the resource a request acted on when it
+// is not the :id route parameter, e.g. for newly created rows.
+func SetResourceID(c *gin.Context, id int64) {
+	c.Set(resourceIDKey, id)
+}
+
+// SetPatientID records the patient whose data a request accessed.
+// Listings and searches may return the data of many patients and record
+// none, so filtering the log by patient only finds reads and writes.
+func SetPatientID(c *gin.Context, id int64) {
+	c.Set(patientIDKey, id)
+}
+
+// Middleware appends an entry to the audit log for every authenticated
+// request it wraps, including the ones rejected by authorization. It must
+// run after the auth middleware: requests without a valid token name no
+// user, and logging them would let anyone fill the log.
+func Middleware(appender Appender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		claims, ok := auth.CurrentClaims(c)
+		if !ok {
+			return
+		}
+
+		entry := model.AuditEntry{
+			UserID:       claims.UserID(),
+			Username:     claims.Username,
+			Action:       action(c),
+			ResourceType: resourceType(c.FullPath()),
+			ResourceID:   c.GetInt64(resourceIDKey),
+			PatientID:    c.GetInt64(patientIDKey),
+			Time:         time.Now().UTC(),
+			ClientIP:     c.ClientIP(),
+			Status:       c.Writer.Status(),
+			Outcome:      outcome(c.Writer.Status()),
+		}
+
+		if entry.ResourceID == 0 {
+			entry.ResourceID, _ = strconv.ParseInt(c.Param("id"), 10, 64)
+		}
+
+		if entry.PatientID == 0 && entry.ResourceType == "patient" {
+			entry.PatientID = entry.ResourceID
+		}
+
+		// The response is already written, so a failure can only be
+		// logged; it must not be lost because the client went away.
+		if err := appender.AppendAudit(context.WithoutCancel(c.Request.Context()), &entry); err != nil {
+			logging.FromContext(c.Request.Context()).Error("audit entry not saved", "error", err)
+		}
+	}
+}
+
+func action(c *gin.Context) string {
+	switch c.Request.Method {
+	case http.MethodPost:
+		return ActionCreate
+	case http.MethodPut, http.MethodPatch:
+		return ActionUpdate
+	case http.MethodDelete:
+		return ActionDelete
+	}
+
+	if strings.HasSuffix(c.FullPath(), "/search") {
+		return ActionSearch
+	}
+
+	if c.Param("id") != "" {
+		return ActionRead
+	}
+
+	return ActionList
+}
+
+// resourceType derives the audited resource from the route, e.g.
+// "/sec-records/:id" is a record.
+func resourceType(route string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+
+	switch segment {
+	case "patients":
+		return "patient"
+	case "records", "sec-records":
+		return "record"
+	}
+
+	return segment
+}
+
+func outcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status < 400:
+		return OutcomeSuccess
+	}
+
+	return OutcomeFailure
+}
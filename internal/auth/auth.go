@@ -1 +1,32 @@
+// Package auth authenticates api-server users with signed JWT access and
+// refresh tokens.
 package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned when a username or password does not
+// match.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// HashPassword returns the bcrypt hash stored for password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// DummyHash is a bcrypt hash, at the cost HashPassword uses, that no
+// password submitted at login matches.
+const DummyHash = "$2a$10$TW0TInucnZ.6O7lZiR.ag.vZSfbZwcLI4pV0/P9auyETNgiEoMsiG"
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswords(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("CheckPassword rejects the hashed password")
+	}
+	if CheckPassword(hash, "correct horse ") {
+		t.Error("CheckPassword accepts another password")
+	}
+	if CheckPassword("not a hash", "correct horse") {
+		t.Error("CheckPassword accepts a malformed hash")
+	}
+}
+
+// TestDummyHash checks that rejecting an unknown username costs as much
+// as rejecting a wrong password.
+func TestDummyHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(DummyHash))
+	if err != nil {
+		t.Fatalf("DummyHash is not a bcrypt hash: %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("DummyHash cost = %d, want the %d of HashPassword", cost, bcrypt.DefaultCost)
+	}
+
+	for _, password := range []string{"", "demo", "password"} {
+		if CheckPassword(DummyHash, password) {
+			t.Errorf("DummyHash matches %q", password)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+)
+
+const claimsKey = "auth.claims"
+
+// Middleware rejects requests without a valid bearer access token and
+// makes the token claims available through CurrentClaims.
+func Middleware(tokens *Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			c.Header("WWW-Authenticate", `Bearer realm="web-service-mr"`)
+			apierr.Write(c, apierr.Unauthorized("missing bearer token"))
+			return
+		}
+
+		claims, err := tokens.Verify(token, AccessToken)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="web-service-mr", error="invalid_token"`)
+			apierr.Write(c, apierr.Unauthorized(err.Error()))
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// CurrentClaims returns the claims of the authenticated request, if any.
+func CurrentClaims(c *gin.Context) (Claims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return Claims{}, false
+	}
+
+	claims, ok := value.(Claims)
+	return claims, ok
+}
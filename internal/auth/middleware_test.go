@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestMiddleware(t *testing.T) {
+	tokens := NewTokens(testSecret, 15*time.Minute, time.Hour)
+
+	pair, err := tokens.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := NewTokens(testSecret, -time.Minute, -time.Minute).Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/", Middleware(tokens), func(c *gin.Context) {
+		claims, ok := CurrentClaims(c)
+		if !ok {
+			t.Error("CurrentClaims found no claims after the middleware")
+		}
+		c.String(http.StatusOK, claims.Username)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		// challenge is the start of the WWW-Authenticate header.
+		challenge string
+	}{
+		{"valid", "Bearer " + pair.AccessToken, http.StatusOK, ""},
+		{"scheme in lower case", "bearer " + pair.AccessToken, http.StatusOK, ""},
+		{"no header", "", http.StatusUnauthorized, `Bearer realm="web-service-mr"`},
+		{"basic credentials", "Basic bnVyc2U6ZGVtbw==", http.StatusUnauthorized, `Bearer realm="web-service-mr"`},
+		{"bearer without token", "Bearer", http.StatusUnauthorized, `Bearer realm="web-service-mr"`},
+		{"malformed token", "Bearer garbage", http.StatusUnauthorized, `Bearer realm="web-service-mr", error="invalid_token"`},
+		{"refresh token", "Bearer " + pair.RefreshToken, http.StatusUnauthorized, `Bearer realm="web-service-mr", error="invalid_token"`},
+		{"expired token", "Bearer " + expired.AccessToken, http.StatusUnauthorized, `Bearer realm="web-service-mr", error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != testUser.Username {
+				t.Errorf("body = %q, want the username", w.Body)
+			}
+			if tt.status == http.StatusUnauthorized && !strings.Contains(w.Body.String(), `"unauthorized"`) {
+				t.Errorf("body = %s, want an unauthorized problem", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/apierr"
+)
+
+// Roles a user can hold.
+const (
+	RoleAdmin     = "admin"
+	RolePhysician = "physician"
+	RoleNurse     = "nurse"
+	RoleReception = "reception"
+)
+
+// Permission names an action on clinical data.
+type Permission string
+
+const (
+	// PatientsRead allows listing, searching and reading patients.
+	PatientsRead Permission = "patients:read"
+	// PatientsWrite allows registering and correcting patients.
+	PatientsWrite Permission = "patients:write"
+	// PatientsDelete allows deleting patients without medical records.
+	PatientsDelete Permission = "patients:delete"
+	// RecordsRead allows reading full medical records.
+	RecordsRead Permission = "records:read"
+	// VitalSignsWrite allows creating records holding vital signs.
+	VitalSignsWrite Permission = "records:vital-signs"
+	// RecordsWrite allows adding disease history, symptoms and exams to
+	// a record.
+	RecordsWrite Permission = "records:write"
+	// Diagnose allows adding diagnoses (idx) and treatments to a record.
+	Diagnose Permission = "records:diagnose"
+	// CatalogsWrite allows adding diseases, symptoms, exams and medicines.
+	CatalogsWrite Permission = "catalogs:write"
+	// AuditRead allows reading the audit log.
+	AuditRead Permission = "audit:read"
+)
+
+// Policy lists the permissions granted to each role.
+var Policy = map[string][]Permission{
+	RoleAdmin: {
+		PatientsRead, PatientsWrite, PatientsDelete, RecordsRead, VitalSignsWrite,
+		RecordsWrite, Diagnose, CatalogsWrite, AuditRead,
+	},
+	RolePhysician: {
+		PatientsRead, PatientsWrite, RecordsRead, VitalSignsWrite,
+		RecordsWrite, Diagnose, CatalogsWrite,
+	},
+	RoleNurse: {
+		PatientsRead, RecordsRead, VitalSignsWrite,
+	},
+	RoleReception: {
+		PatientsRead, PatientsWrite,
+	},
+}
+
+// ValidRole reports whether role is defined in Policy.
+func ValidRole(role string) bool {
+	_, ok := Policy[role]
+	return ok
+}
+
+// Can reports whether role has been granted permission.
+func Can(role string, permission Permission) bool {
+	return slices.Contains(Policy[role], permission)
+}
+
+// Allowed reports whether the authenticated user of c has permission.
+func Allowed(c *gin.Context, permission Permission) bool {
+	claims, ok := CurrentClaims(c)
+	return ok && Can(claims.Role, permission)
+}
+
+// Require rejects with 403 requests whose user holds none of
+// permissions. It must run after Middleware.
+func Require(permissions ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if Allowed(c, permission) {
+				c.Next()
+				return
+			}
+		}
+
+		apierr.Write(c, apierr.Forbidden("insufficient permissions"))
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func TestRequire(t *testing.T) {
+	tokens := NewTokens(testSecret, 15*time.Minute, time.Hour)
+
+	tests := []struct {
+		name        string
+		role        string
+		permissions []Permission
+		status      int
+	}{
+		{"granted", RoleNurse, []Permission{VitalSignsWrite}, http.StatusOK},
+		{"one of several granted", RoleNurse, []Permission{Diagnose, RecordsWrite, VitalSignsWrite}, http.StatusOK},
+		{"not granted", RoleNurse, []Permission{Diagnose}, http.StatusForbidden},
+		{"none of several granted", RoleReception, []Permission{RecordsRead, AuditRead}, http.StatusForbidden},
+		{"unknown role", "janitor", []Permission{PatientsRead}, http.StatusForbidden},
+		{"no permission listed", RoleAdmin, nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := tokens.Issue(model.User{ID: 1, Username: "user", Role: tt.role})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router := gin.New()
+			router.GET("/", Middleware(tokens), Require(tt.permissions...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+// TestRequireWithoutMiddleware checks that Require denies requests no
+// token was checked for rather than letting them through.
+func TestRequireWithoutMiddleware(t *testing.T) {
+	router := gin.New()
+	router.GET("/", Require(PatientsRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	for role, permissions := range Policy {
+		if !ValidRole(role) {
+			t.Errorf("ValidRole(%q) = false", role)
+		}
+		for _, permission := range permissions {
+			if !Can(role, permission) {
+				t.Errorf("Can(%q, %q) = false", role, permission)
+			}
+		}
+	}
+
+	if ValidRole("janitor") || Can("janitor", PatientsRead) {
+		t.Error("an unknown role is valid or granted permissions")
+	}
+	// Only admins delete patients and read the audit log.
+	for _, role := range []string{RolePhysician, RoleNurse, RoleReception} {
+		if Can(role, PatientsDelete) || Can(role, AuditRead) {
+			t.Errorf("%s may delete patients or read the audit log", role)
+		}
+	}
+}
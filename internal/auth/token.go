@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+const (
+	issuer = "web-service-mr"
+
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired,
+// badly signed or of the wrong type.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the JWT claims carried by access and refresh tokens.
+type Claims struct {
+	jwt.RegisteredClaims
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Type     string `json:"typ"`
+}
+
+// UserID returns the id of the authenticated user.
+func (c Claims) UserID() int64 {
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+// TokenPair is returned on login and refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Tokens issues and verifies HMAC-SHA256 signed tokens.
+type Tokens struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewTokens returns a Tokens signing with secret.
+func NewTokens(secret []byte, accessTTL, refreshTTL time.Duration) *Tokens {
+	return &Tokens{secret: secret, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+func (t *Tokens) sign(user model.User, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Username: user.Username,
+		Role:     user.Role,
+		Type:     tokenType,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
+
+// Issue returns a new access and refresh token for user.
+func (t *Tokens) Issue(user model.User) (TokenPair, error) {
+	access, err := t.sign(user, AccessToken, t.accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refresh, err := t.sign(user, RefreshToken, t.refreshTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(t.accessTTL.Seconds()),
+	}, nil
+}
+
+// Verify parses token and checks its signature, expiry and type.
+func (t *Tokens) Verify(token, tokenType string) (Claims, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(token, &claims,
+		func(*jwt.Token) (any, error) { return t.secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired())
+
+	if err != nil || claims.Type != tokenType {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
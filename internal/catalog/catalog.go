@@ -0,0 +1,397 @@
+// Package catalog imports catalog rows in bulk, from CSV or JSON files,
+// for the api-server's import routes and its catalog subcommand.
+//
+// A file holds rows of one catalog. A row whose description (a medicine's
+// name, formulation and dose) matches a row of the catalog or an earlier
+// row of the file, ignoring case and accents, is a duplicate and skipped.
+// The other rows are validated and, unless one of them is invalid, all
+// created in one transaction.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/search"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+// Catalogs that can be imported.
+const (
+	Diseases  = "diseases"
+	Symptoms  = "symptoms"
+	Exams     = "exams"
+	Medicines = "medicines"
+)
+
+// Statuses of an imported row.
+const (
+	Created   = "created"
+	Duplicate = "duplicate"
+)
+
+// MaxRows is the largest number of rows a file may hold.
+const MaxRows = 10000
+
+// maxTextLength is the longest description or name the catalogs hold.
+const maxTextLength = 255
+
+// columns lists the columns of the rows of each catalog, required ones
+// first.
+var columns = map[string][]string{
+	Diseases:  {"description", "code"},
+	Symptoms:  {"description"},
+	Exams:     {"description"},
+	Medicines: {"name", "formulation_id", "dose"},
+}
+
+// required is the number of required columns of each catalog.
+var required = map[string]int{
+	Diseases:  1,
+	Symptoms:  1,
+	Exams:     1,
+	Medicines: 3,
+}
+
+// Valid reports whether catalog can be imported.
+func Valid(catalog string) bool {
+	_, ok := columns[catalog]
+	return ok
+}
+
+// ErrFormat is wrapped by the errors of files that cannot be read.
+var ErrFormat = errors.New("malformed import file")
+
+// RowError is an invalid value in the row at Index, counted from 0.
+type RowError struct {
+	Index   int
+	Field   string
+	Code    string
+	Message string
+}
+
+// Error lists the invalid rows of a file. Nothing is imported when Import
+// returns one.
+type Error struct {
+	Rows []RowError
+}
+
+func (e *Error) Error() string {
+	first := e.Rows[0]
+	msg := fmt.Sprintf("catalog: row %d: %s %s", first.Index, first.Field, first.Message)
+
+	if len(e.Rows) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(e.Rows)-1)
+	}
+
+	return msg
+}
+
+// Import reads the rows of catalog from r, a file in format, and creates
+// those that are not duplicates unless dryRun is set.
+func Import(ctx context.Context, st store.Store, catalog, format string, r io.Reader, dryRun bool) (model.ImportResult, error) {
+	result := model.ImportResult{Catalog: catalog, DryRun: dryRun, Rows: []model.ImportRow{}}
+
+	if !Valid(catalog) {
+		return result, fmt.Errorf("catalog: unknown catalog %q", catalog)
+	}
+
+	rows, err := read(format, r, columns[catalog], required[catalog])
+	if err != nil {
+		return result, err
+	}
+
+	im, err := load(ctx, st, catalog)
+	if err != nil {
+		return result, err
+	}
+
+	var batch store.CatalogBatch
+	var invalid []RowError
+	// created holds the position in result.Rows of the rows in batch and
+	// sameAs that of the row each in-file duplicate repeats.
+	var created []int
+	sameAs := map[int]int{}
+	seen := map[string]int{}
+
+	for i, row := range rows {
+		p := rowParser{index: i, row: row}
+		key, add := im.parse(&p)
+
+		if id, ok := im.existing[key]; ok && p.errors == nil {
+			result.Rows = append(result.Rows, model.ImportRow{Index: i, Status: Duplicate, ID: id})
+			result.Duplicates++
+			continue
+		}
+
+		if earlier, ok := seen[key]; ok && p.errors == nil {
+			sameAs[len(result.Rows)] = earlier
+			result.Rows = append(result.Rows, model.ImportRow{Index: i, Status: Duplicate})
+			result.Duplicates++
+			continue
+		}
+
+		im.claim(&p)
+
+		if p.errors != nil {
+			invalid = append(invalid, p.errors...)
+			continue
+		}
+
+		seen[key] = len(result.Rows)
+		created = append(created, len(result.Rows))
+		result.Rows = append(result.Rows, model.ImportRow{Index: i, Status: Created})
+		result.Created++
+		add(&batch)
+	}
+
+	if invalid != nil {
+		return result, &Error{Rows: invalid}
+	}
+
+	if dryRun || result.Created == 0 {
+		return result, nil
+	}
+
+	if err := st.CreateCatalogBatch(ctx, &batch); err != nil {
+		return result, err
+	}
+
+	for i, id := range ids(catalog, &batch) {
+		result.Rows[created[i]].ID = id
+	}
+
+	for row, earlier := range sameAs {
+		result.Rows[row].ID = result.Rows[earlier].ID
+	}
+
+	return result, nil
+}
+
+// importer holds what rows are checked against: the duplicate key and id
+// of every row of the catalog, the disease codes in use and the existing
+// formulations.
+type importer struct {
+	catalog      string
+	existing     map[string]int64
+	codes        map[string]bool
+	formulations map[int64]bool
+}
+
+func load(ctx context.Context, st store.Store, catalog string) (*importer, error) {
+	im := &importer{
+		catalog:      catalog,
+		existing:     map[string]int64{},
+		codes:        map[string]bool{},
+		formulations: map[int64]bool{},
+	}
+
+	switch catalog {
+	case Diseases:
+		diseases, err := store.LoadAll(
+			func() (int64, error) { return st.CountDiseases(ctx, store.DiseaseFilter{}) },
+			func(offset, limit int) ([]model.Disease, error) {
+				return st.ListDiseases(ctx, store.DiseaseFilter{}, nil, offset, limit)
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, disease := range diseases {
+			im.existing[textKey(disease.Description)] = disease.ID
+			im.codes[disease.Code] = disease.Code != ""
+		}
+	case Symptoms:
+		symptoms, err := store.LoadAll(
+			func() (int64, error) { return st.CountSymptoms(ctx, "") },
+			func(offset, limit int) ([]model.Symptom, error) { return st.ListSymptoms(ctx, "", offset, limit) })
+		if err != nil {
+			return nil, err
+		}
+
+		for _, symptom := range symptoms {
+			im.existing[textKey(symptom.Description)] = symptom.ID
+		}
+	case Exams:
+		exams, err := st.ListExams(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, exam := range exams {
+			im.existing[textKey(exam.Description)] = exam.ID
+		}
+	case Medicines:
+		medicines, err := store.LoadAll(
+			func() (int64, error) { return st.CountMedicines(ctx, store.MedicineFilter{}) },
+			func(offset, limit int) ([]model.Medicine, error) {
+				return st.ListMedicines(ctx, store.MedicineFilter{}, nil, offset, limit)
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, medicine := range medicines {
+			im.existing[medicineKey(medicine)] = medicine.ID
+		}
+
+		formulations, err := st.ListFormulations(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, formulation := range formulations {
+			im.formulations[formulation.ID] = true
+		}
+	}
+
+	return im, nil
+}
+
+// parse validates a row and returns its duplicate key along with the
+// function adding it to a batch.
+func (im *importer) parse(p *rowParser) (string, func(*store.CatalogBatch)) {
+	switch im.catalog {
+	case Diseases:
+		disease := model.Disease{Description: p.text("description"), Code: p.code("code")}
+		return textKey(disease.Description), func(batch *store.CatalogBatch) {
+			batch.Diseases = append(batch.Diseases, disease)
+		}
+	case Symptoms:
+		symptom := model.Symptom{Description: p.text("description")}
+		return textKey(symptom.Description), func(batch *store.CatalogBatch) {
+			batch.Symptoms = append(batch.Symptoms, symptom)
+		}
+	case Exams:
+		exam := model.Exam{Description: p.text("description")}
+		return textKey(exam.Description), func(batch *store.CatalogBatch) {
+			batch.Exams = append(batch.Exams, exam)
+		}
+	}
+
+	medicine := model.Medicine{
+		Name:           p.text("name"),
+		FormulationObj: model.Formulation{ID: p.positive("formulation_id")},
+		Dose:           p.positive("dose"),
+	}
+	if medicine.FormulationObj.ID != 0 && !im.formulations[medicine.FormulationObj.ID] {
+		p.reject("formulation_id", "reference", "does not exist")
+	}
+
+	return medicineKey(medicine), func(batch *store.CatalogBatch) {
+		batch.Medicines = append(batch.Medicines, medicine)
+	}
+}
+
+// claim rejects a row whose disease code is in use, and otherwise
+// reserves the code for it.
+func (im *importer) claim(p *rowParser) {
+	if im.catalog != Diseases || p.errors != nil {
+		return
+	}
+
+	code, _ := icd10.Normalize(p.row["code"])
+	if code == "" {
+		return
+	}
+
+	if im.codes[code] {
+		p.reject("code", "conflict", "is the code of another disease")
+		return
+	}
+
+	im.codes[code] = true
+}
+
+// ids returns the ids of the rows of catalog in batch.
+func ids(catalog string, batch *store.CatalogBatch) []int64 {
+	var ids []int64
+
+	switch catalog {
+	case Diseases:
+		for _, disease := range batch.Diseases {
+			ids = append(ids, disease.ID)
+		}
+	case Symptoms:
+		for _, symptom := range batch.Symptoms {
+			ids = append(ids, symptom.ID)
+		}
+	case Exams:
+		for _, exam := range batch.Exams {
+			ids = append(ids, exam.ID)
+		}
+	case Medicines:
+		for _, medicine := range batch.Medicines {
+			ids = append(ids, medicine.ID)
+		}
+	}
+
+	return ids
+}
+
+// textKey is the duplicate key of a description.
+func textKey(description string) string {
+	return strings.Join(strings.Fields(search.Fold(description)), " ")
+}
+
+// medicineKey is the duplicate key of a medicine.
+func medicineKey(medicine model.Medicine) string {
+	return fmt.Sprintf("%s\x00%d\x00%d", textKey(medicine.Name), medicine.FormulationObj.ID, medicine.Dose)
+}
+
+// rowParser reads the values of a row, collecting the invalid ones.
+type rowParser struct {
+	index  int
+	row    map[string]string
+	errors []RowError
+}
+
+func (p *rowParser) reject(field, code, message string) {
+	p.errors = append(p.errors, RowError{Index: p.index, Field: field, Code: code, Message: message})
+}
+
+// text returns a required text value.
+func (p *rowParser) text(field string) string {
+	v := strings.TrimSpace(p.row[field])
+
+	switch {
+	case v == "":
+		p.reject(field, "required", "is required")
+	case utf8.RuneCountInString(v) > maxTextLength:
+		p.reject(field, "max", "must be at most "+strconv.Itoa(maxTextLength)+" characters long")
+	}
+
+	return v
+}
+
+// code returns an optional ICD-10 code.
+func (p *rowParser) code(field string) string {
+	if strings.TrimSpace(p.row[field]) == "" {
+		return ""
+	}
+
+	code, ok := icd10.Normalize(p.row[field])
+	if !ok {
+		p.reject(field, "format", "must be an ICD-10 code, such as J02 or J02.9")
+	}
+
+	return code
+}
+
+// positive returns a required positive integer.
+func (p *rowParser) positive(field string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(p.row[field]), 10, 64)
+	if err != nil || n <= 0 {
+		p.reject(field, "format", "must be a positive integer")
+		return 0
+	}
+
+	return n
+}
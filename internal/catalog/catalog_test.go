@@ -0,0 +1,220 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+	"github.com/jctorrestone/web-service-mr/internal/store"
+)
+
+func newStore(t *testing.T) *store.Memory {
+	t.Helper()
+
+	st := store.NewMemory()
+	if err := st.Seed(); err != nil {
+		t.Fatal(err)
+	}
+
+	return st
+}
+
+// symptoms is an import of two new symptoms, one of them repeated, and of
+// a seeded one in other case and accents.
+const symptoms = `[
+	{"description": "Mareo"},
+	{"description": "  FIÉBRE "},
+	{"description": "mareo"},
+	{"description": "Náusea"}
+]`
+
+func TestImport(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	result, err := Import(ctx, st, Symptoms, JSON, strings.NewReader(symptoms), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The seed holds symptoms 1 to 6, Fiebre being the first.
+	want := []model.ImportRow{
+		{Index: 0, Status: Created, ID: 7},
+		{Index: 1, Status: Duplicate, ID: 1},
+		{Index: 2, Status: Duplicate, ID: 7},
+		{Index: 3, Status: Created, ID: 8},
+	}
+	if result.Created != 2 || result.Duplicates != 2 || result.DryRun || !slices.Equal(result.Rows, want) {
+		t.Errorf("Import = %+v, want rows %+v", result, want)
+	}
+
+	if n, err := st.CountSymptoms(ctx, ""); err != nil || n != 8 {
+		t.Errorf("CountSymptoms = %d, %v, want 8", n, err)
+	}
+
+	// Importing the file again creates nothing.
+	again, err := Import(ctx, st, Symptoms, JSON, strings.NewReader(symptoms), false)
+	if err != nil || again.Created != 0 || again.Duplicates != 4 {
+		t.Errorf("second Import = %+v, %v, want 4 duplicates", again, err)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	result, err := Import(ctx, st, Symptoms, JSON, strings.NewReader(symptoms), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows to be created have no id yet, nor do their repetitions.
+	want := []model.ImportRow{
+		{Index: 0, Status: Created},
+		{Index: 1, Status: Duplicate, ID: 1},
+		{Index: 2, Status: Duplicate},
+		{Index: 3, Status: Created},
+	}
+	if result.Created != 2 || result.Duplicates != 2 || !result.DryRun || !slices.Equal(result.Rows, want) {
+		t.Errorf("Import = %+v, want rows %+v", result, want)
+	}
+
+	if n, err := st.CountSymptoms(ctx, ""); err != nil || n != 6 {
+		t.Errorf("CountSymptoms = %d, %v, want the 6 seeded", n, err)
+	}
+}
+
+func TestImportRowErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog string
+		format  string
+		file    string
+		want    []RowError
+	}{
+		{
+			"diseases", Diseases, CSV,
+			"description,code\n" +
+				"Neumonía,J18.9\n" +
+				",J20\n" +
+				"Bronquitis,bronchitis\n" +
+				"Otra hipertensión,i10\n" +
+				"Neumonía bacteriana,J18.9\n",
+			[]RowError{
+				{1, "description", "required", "is required"},
+				{2, "code", "format", "must be an ICD-10 code, such as J02 or J02.9"},
+				{3, "code", "conflict", "is the code of another disease"},
+				{4, "code", "conflict", "is the code of another disease"},
+			},
+		},
+		{
+			"medicines", Medicines, JSON,
+			`[
+				{"name": "Loratadina", "formulation_id": 99, "dose": 10},
+				{"name": "Cetirizina", "formulation_id": 1, "dose": 0},
+				{"name": "` + strings.Repeat("x", maxTextLength+1) + `", "formulation_id": "uno", "dose": 10}
+			]`,
+			[]RowError{
+				{0, "formulation_id", "reference", "does not exist"},
+				{1, "dose", "format", "must be a positive integer"},
+				{2, "name", "max", "must be at most 255 characters long"},
+				{2, "formulation_id", "format", "must be a positive integer"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newStore(t)
+			ctx := context.Background()
+
+			// A dry run reports the same errors.
+			for _, dryRun := range []bool{true, false} {
+				_, err := Import(ctx, st, tt.catalog, tt.format, strings.NewReader(tt.file), dryRun)
+
+				var invalid *Error
+				if !errors.As(err, &invalid) {
+					t.Fatalf("dry run %v: Import error = %v, want an *Error", dryRun, err)
+				}
+				if !slices.Equal(invalid.Rows, tt.want) {
+					t.Errorf("dry run %v: row errors = %+v, want %+v", dryRun, invalid.Rows, tt.want)
+				}
+			}
+
+			// The valid rows are not created either.
+			diseases, err := st.CountDiseases(ctx, store.DiseaseFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			medicines, err := st.CountMedicines(ctx, store.MedicineFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diseases != 5 || medicines != 5 {
+				t.Errorf("%d diseases and %d medicines, want the 5 seeded of each", diseases, medicines)
+			}
+		})
+	}
+}
+
+// phantomFormulation is a store listing a formulation it does not hold,
+// so that imports pass validation and then fail in the store.
+type phantomFormulation struct {
+	store.Store
+}
+
+func (s phantomFormulation) ListFormulations(ctx context.Context) ([]model.Formulation, error) {
+	formulations, err := s.Store.ListFormulations(ctx)
+	return append(formulations, model.Formulation{ID: 99}), err
+}
+
+func TestImportRollback(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	file := `[
+		{"name": "Loratadina", "formulation_id": 1, "dose": 10},
+		{"name": "Cetirizina", "formulation_id": 99, "dose": 10}
+	]`
+	if _, err := Import(ctx, phantomFormulation{st}, Medicines, JSON, strings.NewReader(file), false); !errors.Is(err, store.ErrInvalidReference) {
+		t.Fatalf("Import error = %v, want ErrInvalidReference", err)
+	}
+
+	if n, err := st.CountMedicines(ctx, store.MedicineFilter{}); err != nil || n != 5 {
+		t.Errorf("CountMedicines = %d, %v, want the 5 seeded", n, err)
+	}
+}
+
+func TestImportMaxRows(t *testing.T) {
+	tests := []struct {
+		format string
+		file   func(rows int) string
+	}{
+		{CSV, func(rows int) string {
+			return "description\n" + strings.Repeat("Mareo\n", rows)
+		}},
+		{JSON, func(rows int) string {
+			return "[" + strings.TrimSuffix(strings.Repeat(`{"description": "Mareo"},`, rows), ",") + "]"
+		}},
+	}
+
+	for _, tt := range tests {
+		st := newStore(t)
+		ctx := context.Background()
+
+		result, err := Import(ctx, st, Symptoms, tt.format, strings.NewReader(tt.file(MaxRows)), true)
+		if err != nil || len(result.Rows) != MaxRows {
+			t.Errorf("%s: Import of %d rows = %d rows, %v", tt.format, MaxRows, len(result.Rows), err)
+		}
+
+		if _, err := Import(ctx, st, Symptoms, tt.format, strings.NewReader(tt.file(MaxRows+1)), false); !errors.Is(err, ErrFormat) {
+			t.Errorf("%s: Import of %d rows error = %v, want ErrFormat", tt.format, MaxRows+1, err)
+		}
+		if n, err := st.CountSymptoms(ctx, ""); err != nil || n != 6 {
+			t.Errorf("%s: CountSymptoms = %d, %v, want the 6 seeded", tt.format, n, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Formats of an import file.
+const (
+	CSV  = "csv"
+	JSON = "json"
+)
+
+// read returns the rows of a file as maps from column to value. A CSV file
+// starts with a header naming its columns, a JSON file is an array of
+// objects. Both must name the first required columns and no column
+// outside columns.
+func read(format string, r io.Reader, columns []string, required int) ([]map[string]string, error) {
+	var rows []map[string]string
+	var err error
+
+	switch format {
+	case CSV:
+		rows, err = readCSV(r, columns, required)
+	case JSON:
+		rows, err = readJSON(r, columns)
+	default:
+		return nil, fmt.Errorf("catalog: unknown format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) > MaxRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrFormat, MaxRows)
+	}
+
+	return rows, nil
+}
+
+func readCSV(r io.Reader, columns []string, required int) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing header", ErrFormat)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(columns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrFormat, name)
+		}
+		if slices.Contains(header[:i], name) {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrFormat, name)
+		}
+		header[i] = name
+	}
+
+	for _, name := range columns[:required] {
+		if !slices.Contains(header, name) {
+			return nil, fmt.Errorf("%w: missing column %q", ErrFormat, name)
+		}
+	}
+
+	var rows []map[string]string
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+		}
+
+		row := map[string]string{}
+		for i, value := range record {
+			row[header[i]] = value
+		}
+		rows = append(rows, row)
+
+		if len(rows) > MaxRows {
+			return rows, nil
+		}
+	}
+}
+
+func readJSON(r io.Reader, columns []string) ([]map[string]string, error) {
+	var objects []map[string]json.RawMessage
+
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%w: the file must hold an array of objects", ErrFormat)
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+
+	rows := make([]map[string]string, len(objects))
+
+	for i, object := range objects {
+		rows[i] = map[string]string{}
+
+		for name, raw := range object {
+			if !slices.Contains(columns, name) {
+				return nil, fmt.Errorf("%w: row %d: unknown column %q", ErrFormat, i, name)
+			}
+
+			value, err := scalar(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: row %d: %s %v", ErrFormat, i, name, err)
+			}
+			rows[i][name] = value
+		}
+	}
+
+	return rows, nil
+}
+
+// scalar returns a JSON string, number or null as a string, null being
+// empty.
+func scalar(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return "", nil
+	case len(raw) > 0 && raw[0] == '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case len(raw) > 0 && (raw[0] == '-' || raw[0] >= '0' && raw[0] <= '9'):
+		return string(raw), nil
+	}
+
+	return "", errors.New("must be a string or a number")
+}
@@ -0,0 +1,385 @@
+// Package config loads the api-server configuration.
+//
+// Values are resolved in increasing order of precedence: built-in
+// defaults, a YAML file given with --config (or MR_CONFIG), MR_*
+// environment variables and finally command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the full api-server configuration.
+type Config struct {
+	// Store selects the backend: mysql, sqlite or memory.
+	Store    string   `yaml:"store"`
+	Database Database `yaml:"database"`
+	Server   Server   `yaml:"server"`
+	Auth     Auth     `yaml:"auth"`
+	Log      Log      `yaml:"log"`
+	Tracing  Tracing  `yaml:"tracing"`
+}
+
+// Database holds the connection settings of the SQL backends.
+type Database struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// Addr is the MySQL host:port.
+	Addr string `yaml:"addr"`
+	// Name is the MySQL database name.
+	Name string `yaml:"name"`
+	// Path is the SQLite database file.
+	Path            string        `yaml:"path"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	// ConnectTimeout is how long the api-server keeps retrying to reach
+	// the database at startup, e.g. while it is still booting. Zero tries
+	// once.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+// Server holds the HTTP listener settings.
+type Server struct {
+	Addr string `yaml:"addr"`
+	// PageSize is the default number of items per page and MaxPageSize
+	// the largest page_size a client may request.
+	PageSize    int `yaml:"page_size"`
+	MaxPageSize int `yaml:"max_page_size"`
+	TLS         TLS `yaml:"tls"`
+	// ShutdownTimeout is how long the server waits for the requests in
+	// progress to finish once asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// TLS enables HTTPS when both files are set.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Enabled reports whether the server should listen with TLS.
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// Auth holds the token signing settings.
+type Auth struct {
+	// Secret signs access and refresh tokens. The api-server refuses to
+	// serve without one unless it runs on the memory store, in which case
+	// a random secret is generated.
+	Secret     string        `yaml:"secret"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+// Log holds the logger settings.
+type Log struct {
+	// Format is json, for log collectors, or text.
+	Format string `yaml:"format"`
+	// Level is the least severe level logged: debug, info, warn or
+	// error.
+	Level string `yaml:"level"`
+}
+
+// Tracing holds the OpenTelemetry settings.
+type Tracing struct {
+	// Exporter is none, stdout or otlp (OTLP over HTTP).
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP collector. When empty, the
+	// OTEL_EXPORTER_OTLP_ENDPOINT variable or localhost:4318 is used.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP.
+	Insecure bool `yaml:"insecure"`
+	// SampleRatio is the share of traces started by the api-server that
+	// are recorded. Requests continuing a trace follow its decision.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used when nothing is overridden. It
+// matches the settings the api-server historically hard-coded.
+func Default() Config {
+	return Config{
+		Store: "mysql",
+		Database: Database{
+			Addr:            "127.0.0.1:3306",
+			Name:            "medical_records",
+			Path:            "medical_records.db",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+		},
+		Server: Server{
+			Addr:            "localhost:8080",
+			PageSize:        10,
+			MaxPageSize:     100,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Auth: Auth{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Log: Log{
+			Format: "json",
+			Level:  "info",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load registers the configuration flags on flags, parses args and
+// returns the resolved, validated configuration. Positional arguments
+// remain available through flags.Args.
+func Load(flags *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+
+	configFile := flags.String("config", os.Getenv("MR_CONFIG"), "path to a YAML configuration file")
+	store := flags.String("store", "", "storage backend: mysql, sqlite or memory")
+	dbAddr := flags.String("db-addr", "", "MySQL host:port")
+	dbName := flags.String("db-name", "", "MySQL database name")
+	sqlitePath := flags.String("sqlite", "", "database file used by the sqlite store")
+	listen := flags.String("listen", "", "address the HTTP server listens on")
+	pageSize := flags.Int("page-size", 0, "number of items per page")
+
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return cfg, err
+	}
+
+	// Only flags given explicitly override the file and environment.
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "store":
+			cfg.Store = *store
+		case "db-addr":
+			cfg.Database.Addr = *dbAddr
+		case "db-name":
+			cfg.Database.Name = *dbName
+		case "sqlite":
+			cfg.Database.Path = *sqlitePath
+		case "listen":
+			cfg.Server.Addr = *listen
+		case "page-size":
+			cfg.Server.PageSize = *pageSize
+		}
+	})
+
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) readEnv() error {
+	var errs []error
+
+	str := func(dst *string, names ...string) {
+		for _, name := range names {
+			if v, ok := os.LookupEnv(name); ok {
+				*dst = v
+				return
+			}
+		}
+	}
+
+	integer := func(dst *int, name string) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: not an integer: %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+
+	boolean := func(dst *bool, name string) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: not a boolean: %q", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	float := func(dst *float64, name string) {
+		if v, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: not a number: %q", name, v))
+				return
+			}
+			*dst = f
+		}
+	}
+
+	duration := func(dst *time.Duration, name string) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: not a duration: %q", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	str(&c.Store, "MR_STORE")
+	// DBUSER and DBPASS are kept for existing deployments.
+	str(&c.Database.User, "MR_DB_USER", "DBUSER")
+	str(&c.Database.Password, "MR_DB_PASSWORD", "DBPASS")
+	str(&c.Database.Addr, "MR_DB_ADDR")
+	str(&c.Database.Name, "MR_DB_NAME")
+	str(&c.Database.Path, "MR_SQLITE_PATH")
+	integer(&c.Database.MaxOpenConns, "MR_DB_MAX_OPEN_CONNS")
+	integer(&c.Database.MaxIdleConns, "MR_DB_MAX_IDLE_CONNS")
+	duration(&c.Database.ConnMaxLifetime, "MR_DB_CONN_MAX_LIFETIME")
+	duration(&c.Database.ConnectTimeout, "MR_DB_CONNECT_TIMEOUT")
+	str(&c.Server.Addr, "MR_LISTEN_ADDR")
+	integer(&c.Server.PageSize, "MR_PAGE_SIZE")
+	integer(&c.Server.MaxPageSize, "MR_MAX_PAGE_SIZE")
+	str(&c.Server.TLS.CertFile, "MR_TLS_CERT_FILE")
+	str(&c.Server.TLS.KeyFile, "MR_TLS_KEY_FILE")
+	duration(&c.Server.ShutdownTimeout, "MR_SHUTDOWN_TIMEOUT")
+	str(&c.Auth.Secret, "MR_AUTH_SECRET")
+	duration(&c.Auth.AccessTTL, "MR_AUTH_ACCESS_TTL")
+	duration(&c.Auth.RefreshTTL, "MR_AUTH_REFRESH_TTL")
+	str(&c.Log.Format, "MR_LOG_FORMAT")
+	str(&c.Log.Level, "MR_LOG_LEVEL")
+	str(&c.Tracing.Exporter, "MR_TRACING_EXPORTER")
+	str(&c.Tracing.Endpoint, "MR_TRACING_ENDPOINT")
+	boolean(&c.Tracing.Insecure, "MR_TRACING_INSECURE")
+	float(&c.Tracing.SampleRatio, "MR_TRACING_SAMPLE_RATIO")
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	switch c.Store {
+	case "mysql":
+		if c.Database.Addr == "" {
+			errs = append(errs, errors.New("database.addr is required for the mysql store"))
+		}
+		if c.Database.Name == "" {
+			errs = append(errs, errors.New("database.name is required for the mysql store"))
+		}
+	case "sqlite":
+		if c.Database.Path == "" {
+			errs = append(errs, errors.New("database.path is required for the sqlite store"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("store: unknown backend %q", c.Store))
+	}
+
+	if c.Database.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("database.max_open_conns must not be negative"))
+	}
+	if c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database.max_idle_conns must not be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must not exceed max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database.conn_max_lifetime must not be negative"))
+	}
+	if c.Database.ConnectTimeout < 0 {
+		errs = append(errs, errors.New("database.connect_timeout must not be negative"))
+	}
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.PageSize < 1 || c.Server.PageSize > 1000 {
+		errs = append(errs, fmt.Errorf("server.page_size must be between 1 and 1000, got %d", c.Server.PageSize))
+	}
+	if c.Server.MaxPageSize < c.Server.PageSize || c.Server.MaxPageSize > 1000 {
+		errs = append(errs, fmt.Errorf("server.max_page_size must be between page_size and 1000, got %d", c.Server.MaxPageSize))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+
+	if tls := c.Server.TLS; tls.Enabled() {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			errs = append(errs, errors.New("server.tls needs both cert_file and key_file"))
+		}
+		for _, file := range []string{tls.CertFile, tls.KeyFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				errs = append(errs, fmt.Errorf("server.tls: %w", err))
+			}
+		}
+	}
+
+	if c.Auth.Secret != "" && len(c.Auth.Secret) < 32 {
+		errs = append(errs, errors.New("auth.secret must be at least 32 bytes long"))
+	}
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		errs = append(errs, errors.New("auth.access_ttl and auth.refresh_ttl must be positive"))
+	} else if c.Auth.RefreshTTL < c.Auth.AccessTTL {
+		errs = append(errs, errors.New("auth.refresh_ttl must not be shorter than access_ttl"))
+	}
+
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Log.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %g", c.Tracing.SampleRatio))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets the variables Load reads for the rest of the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, "MR_") || name == "DBUSER" || name == "DBPASS" {
+			// Setenv restores the variable once the test is over.
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func load(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+	return Load(flag.NewFlagSet("api-server", flag.ContinueOnError), args)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != Default() {
+		t.Errorf("Load = %+v, want the defaults %+v", cfg, Default())
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+store: sqlite
+database:
+  path: from-file.db
+  user: file-user
+server:
+  addr: file:8080
+  page_size: 20
+  max_page_size: 50
+log:
+  format: text
+`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MR_CONFIG", file)
+	t.Setenv("MR_PAGE_SIZE", "30")
+	t.Setenv("DBUSER", "legacy-user")
+	t.Setenv("MR_SHUTDOWN_TIMEOUT", "5s")
+
+	cfg, err := load(t, "--listen", "flag:9090", "--sqlite", "from-flag.db", "extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		setting   string
+		got, want any
+	}{
+		{"store from the file", cfg.Store, "sqlite"},
+		{"log format from the file", cfg.Log.Format, "text"},
+		{"max page size from the file", cfg.Server.MaxPageSize, 50},
+		{"page size from the environment", cfg.Server.PageSize, 30},
+		{"user from the legacy variable", cfg.Database.User, "legacy-user"},
+		{"shutdown timeout from the environment", cfg.Server.ShutdownTimeout, 5 * time.Second},
+		{"address from the flag", cfg.Server.Addr, "flag:9090"},
+		{"path from the flag", cfg.Database.Path, "from-flag.db"},
+		{"default log level", cfg.Log.Level, "info"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.setting, tt.got, tt.want)
+		}
+	}
+
+	// MR_DB_USER takes precedence over DBUSER.
+	t.Setenv("MR_DB_USER", "new-user")
+	if cfg, err := load(t); err != nil || cfg.Database.User != "new-user" {
+		t.Errorf("user = %q, %v, want new-user", cfg.Database.User, err)
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"MR_PAGE_SIZE", "ten", "MR_PAGE_SIZE: not an integer"},
+		{"MR_SHUTDOWN_TIMEOUT", "30", "MR_SHUTDOWN_TIMEOUT: not a duration"},
+		{"MR_TRACING_INSECURE", "maybe", "MR_TRACING_INSECURE: not a boolean"},
+		{"MR_TRACING_SAMPLE_RATIO", "half", "MR_TRACING_SAMPLE_RATIO: not a number"},
+		{"MR_STORE", "postgres", `unknown backend "postgres"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv(tt.name, tt.value)
+
+			_, err := load(t)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for _, file := range []string{cert, key} {
+		if err := os.WriteFile(file, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Config)
+		// want lists the settings the error must name, none if valid.
+		want []string
+	}{
+		{"defaults", func(*Config) {}, nil},
+		{"tls", func(c *Config) { c.Server.TLS = TLS{CertFile: cert, KeyFile: key} }, nil},
+		{"tls without key", func(c *Config) { c.Server.TLS.CertFile = cert }, []string{"needs both cert_file and key_file"}},
+		{"tls without cert", func(c *Config) { c.Server.TLS.KeyFile = key }, []string{"needs both cert_file and key_file"}},
+		{
+			"missing tls files",
+			func(c *Config) { c.Server.TLS = TLS{CertFile: cert + ".missing", KeyFile: key + ".missing"} },
+			[]string{"cert.pem.missing", "key.pem.missing"},
+		},
+		{"page size zero", func(c *Config) { c.Server.PageSize = 0 }, []string{"server.page_size"}},
+		{"page size over 1000", func(c *Config) { c.Server.PageSize, c.Server.MaxPageSize = 1001, 1001 }, []string{"server.page_size", "server.max_page_size"}},
+		{"max page size under page size", func(c *Config) { c.Server.PageSize, c.Server.MaxPageSize = 50, 20 }, []string{"server.max_page_size"}},
+		{"max page size equal to page size", func(c *Config) { c.Server.PageSize, c.Server.MaxPageSize = 50, 50 }, nil},
+		{"short secret", func(c *Config) { c.Auth.Secret = "secret" }, []string{"auth.secret"}},
+		{"refresh shorter than access", func(c *Config) { c.Auth.RefreshTTL = time.Minute }, []string{"auth.refresh_ttl"}},
+		{"idle over open connections", func(c *Config) { c.Database.MaxIdleConns = 20 }, []string{"max_idle_conns"}},
+		{"sqlite without a path", func(c *Config) { c.Store, c.Database.Path = "sqlite", "" }, []string{"database.path"}},
+		{
+			"every error at once",
+			func(c *Config) { c.Log.Level, c.Tracing.Exporter, c.Tracing.SampleRatio = "loud", "jaeger", 2 },
+			[]string{"log.level", "tracing.exporter", "tracing.sample_ratio"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Validate = nil, want an error naming %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, want it to name %q", err, want)
+				}
+			}
+		})
+	}
+}
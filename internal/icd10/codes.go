@@ -0,0 +1,83 @@
+package icd10
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Entry is a code of the classification with its title.
+type Entry struct {
+	Code  string
+	Title string
+}
+
+// maxTitleLength is the longest description the disease table holds.
+const maxTitleLength = 255
+
+// ReadCodes reads the codes file of the systematic ICD-10 tables, such as
+// icd102019syst_codes.txt, which holds one semicolon separated line per
+// category and subcategory. The 1st field is the level of the code, the
+// 7th the code without dagger or asterisk and the 9th its title. Only
+// three and four character codes are kept. Files that are not valid UTF-8
+// are read as ISO 8859-1, the encoding the tables are published in.
+func ReadCodes(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utf8.Valid(data) {
+		if data, err = charmap.ISO8859_1.NewDecoder().Bytes(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []Entry
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" {
+			continue
+		}
+
+		fields := strings.Split(text, ";")
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("icd10: line %d: %d fields, want at least 9", line, len(fields))
+		}
+
+		if level := fields[0]; level != "3" && level != "4" {
+			continue
+		}
+
+		code, ok := Normalize(fields[6])
+		if !ok {
+			return nil, fmt.Errorf("icd10: line %d: malformed code %q", line, fields[6])
+		}
+
+		title := strings.TrimSpace(fields[8])
+		if title == "" || utf8.RuneCountInString(title) > maxTitleLength {
+			return nil, fmt.Errorf("icd10: line %d: title of %s must be 1 to %d characters", line, code, maxTitleLength)
+		}
+
+		if seen[code] {
+			return nil, fmt.Errorf("icd10: line %d: duplicate code %s", line, code)
+		}
+		seen[code] = true
+
+		entries = append(entries, Entry{Code: code, Title: title})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
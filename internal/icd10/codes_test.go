@@ -0,0 +1,66 @@
+package icd10
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadCodes(t *testing.T) {
+	file, err := os.Open("testdata/icd102019syst_codes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	entries, err := ReadCodes(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The five character code is skipped.
+	want := []Entry{
+		{"A00", "Cholera"},
+		{"A00.0", "Cholera due to Vibrio cholerae 01, biovar cholerae"},
+		{"A00.9", "Cholera, unspecified"},
+		{"J02", "Acute pharyngitis"},
+		{"J02.9", "Acute pharyngitis, unspecified"},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("ReadCodes = %+v, want %+v", entries, want)
+	}
+}
+
+func TestReadCodesLatin1(t *testing.T) {
+	// A title in ISO 8859-1, the encoding the tables are published in.
+	line := "3;T;X;01;A00;A01.-;A01;A01;Fi\xe8vre typho\xefde;x;x;001\n"
+
+	entries, err := ReadCodes(strings.NewReader(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Entry{{"A01", "Fièvre typhoïde"}}; !slices.Equal(entries, want) {
+		t.Errorf("ReadCodes = %+v, want %+v", entries, want)
+	}
+}
+
+func TestReadCodesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"too few fields", "3;T;X;01;A00\n", "line 1: 5 fields"},
+		{"malformed code", "3;T;X;01;A00;A0.-;A0;A0;Cholera\n", `line 1: malformed code "A0"`},
+		{"empty title", "3;T;X;01;A00;A00.-;A00;A00; \n", "line 1: title of A00"},
+		{"duplicate code", "3;T;X;01;A00;A00.-;A00;A00;Cholera\n\n3;T;X;01;A00;A00.-;A00;A00;Cholera\n", "line 3: duplicate code A00"},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadCodes(strings.NewReader(tt.file))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ReadCodes error = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package icd10 knows the structure of ICD-10 codes and reads the code
+// files the WHO distributes with the classification.
+//
+// A code is a three character category, such as J02 (acute pharyngitis),
+// optionally followed by a dot and the digits of a subcategory, such as
+// J02.9. Categories are grouped in the chapters listed in Chapters.
+package icd10
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// Chapter is an ICD-10 chapter with the range of categories it covers.
+type Chapter struct {
+	model.ICD10Chapter
+	First string
+	Last  string
+}
+
+// Chapters lists the chapters of the 2019 edition of ICD-10.
+var Chapters = []Chapter{
+	{model.ICD10Chapter{Number: 1, Title: "Certain infectious and parasitic diseases"}, "A00", "B99"},
+	{model.ICD10Chapter{Number: 2, Title: "Neoplasms"}, "C00", "D48"},
+	{model.ICD10Chapter{Number: 3, Title: "Diseases of the blood and blood-forming organs and certain disorders involving the immune mechanism"}, "D50", "D89"},
+	{model.ICD10Chapter{Number: 4, Title: "Endocrine, nutritional and metabolic diseases"}, "E00", "E90"},
+	{model.ICD10Chapter{Number: 5, Title: "Mental and behavioural disorders"}, "F00", "F99"},
+	{model.ICD10Chapter{Number: 6, Title: "Diseases of the nervous system"}, "G00", "G99"},
+	{model.ICD10Chapter{Number: 7, Title: "Diseases of the eye and adnexa"}, "H00", "H59"},
+	{model.ICD10Chapter{Number: 8, Title: "Diseases of the ear and mastoid process"}, "H60", "H95"},
+	{model.ICD10Chapter{Number: 9, Title: "Diseases of the circulatory system"}, "I00", "I99"},
+	{model.ICD10Chapter{Number: 10, Title: "Diseases of the respiratory system"}, "J00", "J99"},
+	{model.ICD10Chapter{Number: 11, Title: "Diseases of the digestive system"}, "K00", "K93"},
+	{model.ICD10Chapter{Number: 12, Title: "Diseases of the skin and subcutaneous tissue"}, "L00", "L99"},
+	{model.ICD10Chapter{Number: 13, Title: "Diseases of the musculoskeletal system and connective tissue"}, "M00", "M99"},
+	{model.ICD10Chapter{Number: 14, Title: "Diseases of the genitourinary system"}, "N00", "N99"},
+	{model.ICD10Chapter{Number: 15, Title: "Pregnancy, childbirth and the puerperium"}, "O00", "O99"},
+	{model.ICD10Chapter{Number: 16, Title: "Certain conditions originating in the perinatal period"}, "P00", "P96"},
+	{model.ICD10Chapter{Number: 17, Title: "Congenital malformations, deformations and chromosomal abnormalities"}, "Q00", "Q99"},
+	{model.ICD10Chapter{Number: 18, Title: "Symptoms, signs and abnormal clinical and laboratory findings, not elsewhere classified"}, "R00", "R99"},
+	{model.ICD10Chapter{Number: 19, Title: "Injury, poisoning and certain other consequences of external causes"}, "S00", "T98"},
+	{model.ICD10Chapter{Number: 20, Title: "External causes of morbidity and mortality"}, "V01", "Y98"},
+	{model.ICD10Chapter{Number: 21, Title: "Factors influencing health status and contact with health services"}, "Z00", "Z99"},
+	{model.ICD10Chapter{Number: 22, Title: "Codes for special purposes"}, "U00", "U99"},
+}
+
+var (
+	codePattern   = regexp.MustCompile(`^[A-Z][0-9]{2}(\.[0-9]{1,2})?$`)
+	prefixPattern = regexp.MustCompile(`^[A-Z][0-9]([0-9](\.[0-9]{0,2})?)?$`)
+)
+
+// Normalize upper-cases code and reports whether it is a well formed
+// ICD-10 code.
+func Normalize(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return code, codePattern.MatchString(code)
+}
+
+// Prefix upper-cases q and reports whether it looks like the start of a
+// code: a letter followed by at least one digit, e.g. "j0" or "J02.".
+func Prefix(q string) (string, bool) {
+	q = strings.ToUpper(strings.TrimSpace(q))
+	return q, prefixPattern.MatchString(q)
+}
+
+// Category returns the three character category of code.
+func Category(code string) string {
+	if len(code) < 3 {
+		return code
+	}
+
+	return code[:3]
+}
+
+// ChapterOf returns the chapter holding code.
+func ChapterOf(code string) (Chapter, bool) {
+	category := Category(code)
+
+	for _, chapter := range Chapters {
+		if category >= chapter.First && category <= chapter.Last {
+			return chapter, true
+		}
+	}
+
+	return Chapter{}, false
+}
+
+// ChapterByNumber returns the chapter numbered number.
+func ChapterByNumber(number int) (Chapter, bool) {
+	for _, chapter := range Chapters {
+		if chapter.Number == number {
+			return chapter, true
+		}
+	}
+
+	return Chapter{}, false
+}
+
+// Describe fills in the category and chapter of a disease from its code.
+// Diseases without a code are left untouched.
+func Describe(disease *model.Disease) {
+	if disease.Code == "" {
+		return
+	}
+
+	if category := Category(disease.Code); category != disease.Code {
+		disease.Category = category
+	}
+
+	if chapter, ok := ChapterOf(disease.Code); ok {
+		disease.Chapter = &chapter.ICD10Chapter
+	}
+}
@@ -0,0 +1,126 @@
+package icd10
+
+import (
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"J02", "J02", true},
+		{" j02.9 ", "J02.9", true},
+		{"m00.00", "M00.00", true},
+		{"J02.", "J02.", false},
+		{"J02.123", "J02.123", false},
+		{"J2", "J2", false},
+		{"02.9", "02.9", false},
+		{"J029", "J029", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := Normalize(tt.code); got != tt.want || ok != tt.ok {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+		ok   bool
+	}{
+		{"j0", "J0", true},
+		{"J02", "J02", true},
+		{"J02.", "J02.", true},
+		{" j02.91", "J02.91", true},
+		{"J", "J", false},
+		{"J0.", "J0.", false},
+		{"J02.123", "J02.123", false},
+		{"fever", "FEVER", false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := Prefix(tt.q); got != tt.want || ok != tt.ok {
+			t.Errorf("Prefix(%q) = %q, %v, want %q, %v", tt.q, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestChapterOf(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+	}{
+		{"A00", 1},
+		{"B99.9", 1},
+		{"D48", 2},
+		{"D50", 3},
+		{"H59.9", 7},
+		{"H60", 8},
+		{"J02.9", 10},
+		{"T98", 19},
+		{"U07.1", 22},
+		{"V01", 20},
+		{"Z99", 21},
+		// Categories between chapters belong to none.
+		{"D49", 0},
+		{"K94", 0},
+	}
+
+	for _, tt := range tests {
+		chapter, ok := ChapterOf(tt.code)
+		if got := chapter.Number; got != tt.want || ok != (tt.want != 0) {
+			t.Errorf("ChapterOf(%q) = %d, %v, want %d", tt.code, got, ok, tt.want)
+		}
+	}
+}
+
+func TestChapters(t *testing.T) {
+	for i, chapter := range Chapters {
+		if chapter.Number != i+1 {
+			t.Errorf("chapter %d numbered %d", i+1, chapter.Number)
+		}
+		if got, ok := ChapterByNumber(chapter.Number); !ok || got != chapter {
+			t.Errorf("ChapterByNumber(%d) = %+v, %v", chapter.Number, got, ok)
+		}
+		if chapter.First > chapter.Last {
+			t.Errorf("chapter %d covers %s to %s", chapter.Number, chapter.First, chapter.Last)
+		}
+	}
+
+	if _, ok := ChapterByNumber(23); ok {
+		t.Error("ChapterByNumber(23) found a chapter")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		code     string
+		category string
+		chapter  int
+	}{
+		{"J02.9", "J02", 10},
+		{"J02", "", 10},
+		{"", "", 0},
+	}
+
+	for _, tt := range tests {
+		disease := model.Disease{Code: tt.code}
+		Describe(&disease)
+
+		var chapter int
+		if disease.Chapter != nil {
+			chapter = disease.Chapter.Number
+		}
+		if disease.Category != tt.category || chapter != tt.chapter {
+			t.Errorf("Describe(%q): category %q, chapter %d, want %q, %d", tt.code, disease.Category, chapter, tt.category, tt.chapter)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package logging sets up the structured logger of the api-server and
+// ties log lines to the request they belong to.
+//
+// Patient data must never reach the logs. The handlers returned by New
+// redact the attributes named after patient fields whatever the caller
+// passes, and the models holding patient data log their IDs only.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats of the log lines.
+const (
+	JSON = "json"
+	Text = "text"
+)
+
+// Redacted replaces the values kept out of the logs.
+const Redacted = "[REDACTED]"
+
+// phiKeys are the attribute keys, and query parameters, whose values may
+// hold patient data: names, the free text of searches and page cursors,
+// which carry the sort key of a row, e.g. a last name.
+var phiKeys = map[string]bool{
+	"name":         true,
+	"first_name":   true,
+	"last_name":    true,
+	"lastname":     true,
+	"patient_name": true,
+	"q":            true,
+	"cursor":       true,
+}
+
+// quoted matches the literals database errors quote, e.g. the value of
+// MySQL's "Duplicate entry '...' for key".
+var quoted = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+
+// New returns the logger configured by cfg, writing to w.
+func New(w io.Writer, cfg config.Log) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	switch cfg.Format {
+	case JSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case Text:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+}
+
+// redact replaces the values of patient attributes and the literals of
+// logged errors.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if phiKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+
+	if a.Key == "error" {
+		return slog.String(a.Key, quoted.ReplaceAllString(a.Value.String(), "'"+Redacted+"'"))
+	}
+
+	return a
+}
+
+type contextKey struct{}
+
+// requestLog is what a request carries in its context.
+type requestLog struct {
+	id     string
+	logger *slog.Logger
+}
+
+// withRequest tags the lines logged during the request with its ID and,
+// when traced, with the ID of its trace.
+func withRequest(ctx context.Context, id string, logger *slog.Logger) context.Context {
+	logger = logger.With("request_id", id)
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+
+	return context.WithValue(ctx, contextKey{}, requestLog{id: id, logger: logger})
+}
+
+// FromContext returns the logger of the request ctx belongs to, which
+// adds its ID to every line, or the default logger outside requests.
+func FromContext(ctx context.Context) *slog.Logger {
+	if r, ok := ctx.Value(contextKey{}).(requestLog); ok {
+		return r.logger
+	}
+
+	return slog.Default()
+}
+
+// RequestID returns the ID of the request ctx belongs to, if any.
+func RequestID(ctx context.Context) string {
+	r, _ := ctx.Value(contextKey{}).(requestLog)
+	return r.id
+}
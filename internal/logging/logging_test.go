@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jctorrestone/web-service-mr/internal/config"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// names are the patient data logged in the tests, none of which may be
+// found in the output.
+var names = []string{"María", "Pérez", "P%C3%A9rez", "Gutiérrez", "Brien", "eyJsYXN0X25hbWUi"}
+
+func checkRedacted(t *testing.T, output string) {
+	t.Helper()
+
+	for _, name := range names {
+		if strings.Contains(output, name) {
+			t.Errorf("%q logged:\n%s", name, output)
+		}
+	}
+	if !strings.Contains(output, Redacted) {
+		t.Errorf("nothing redacted:\n%s", output)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	for _, format := range []string{JSON, Text} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, config.Log{Format: format, Level: "debug"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			patient := model.Patient{ID: 7, Name: "María", Lastname: "Pérez"}
+			record := model.FullRecord{RecordObj: model.Record{ID: 3, Category: "primary", PatientObj: patient}}
+
+			logger.Info("created", "patient", patient, "record", record)
+			logger.Debug("search", "q", "Gutiérrez", "Last_Name", "Pérez", "cursor", "eyJsYXN0X25hbWUiOiJQw6lyZXoifQ")
+			logger.Warn("cannot update", "error", errors.New("Error 1062: Duplicate entry 'O\\'Brien' for key 'patient.name'"))
+
+			output := buf.String()
+			checkRedacted(t, output)
+
+			// The IDs are kept, being what the lines are traced by.
+			for _, kept := range []string{"7", "3", "primary", "Duplicate entry"} {
+				if !strings.Contains(output, kept) {
+					t.Errorf("%q not logged:\n%s", kept, output)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareRedactsQuery(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.Log{Format: JSON, Level: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(Middleware(logger))
+	router.GET("/patients/search", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("searching", "name", c.Query("q"))
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/patients/search?q=P%C3%A9rez&last_name=Guti%C3%A9rrez&limit=5", nil))
+
+	output := buf.String()
+	checkRedacted(t, output)
+
+	if want := `"query":"last_name=` + Redacted + `&limit=5&q=` + Redacted + `"`; !strings.Contains(output, want) {
+		t.Errorf("query not logged as %s:\n%s", want, output)
+	}
+	if id := w.Header().Get(Header); id == "" || !strings.Contains(output, id) {
+		t.Errorf("request ID %q not logged:\n%s", id, output)
+	}
+}
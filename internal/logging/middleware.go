@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the ID of a request, both in the request, when a proxy
+// already assigned one, and in the response.
+const Header = "X-Request-ID"
+
+// validID matches the request IDs accepted from clients; anything else
+// is replaced rather than copied into the logs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// Middleware tags every request with an ID, taken from the X-Request-ID
+// header or generated, and logs it once answered. Handlers reach the
+// tagged logger with FromContext.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(Header)
+		if !validID.MatchString(id) {
+			id = newID()
+		}
+		c.Header(Header, id)
+
+		ctx := withRequest(c.Request.Context(), id, logger)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		FromContext(ctx).LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("query", redactQuery(c.Request.URL.Query())),
+			slog.Int("status", c.Writer.Status()),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// redactQuery encodes query without the values of patient parameters,
+// e.g. the names searched for.
+func redactQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+
+			if phiKeys[key] {
+				value = Redacted
+			} else {
+				value = url.QueryEscape(value)
+			}
+			b.WriteString(url.QueryEscape(key) + "=" + value)
+		}
+	}
+
+	return b.String()
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,154 @@
+// Package metrics exposes the activity of the api-server to Prometheus:
+// HTTP requests, the database connection pool and domain events such as
+// records created.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric of the api-server.
+const namespace = "mr"
+
+// unmatched labels the requests no route matched. Their paths and methods
+// are chosen by clients, so they share one series.
+const unmatched = "unmatched"
+
+// Metrics holds the collectors of one server. Its registry is its own so
+// several servers, e.g. in a test, do not clash.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	recordsCreated     *prometheus.CounterVec
+	patientsRegistered prometheus.Counter
+}
+
+// New returns the metrics of a server, the Go runtime and process ones
+// included.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests answered, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to answer HTTP requests, by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		recordsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "records_created_total",
+			Help:      "Medical records created, by category.",
+		}, []string{"category"}),
+		patientsRegistered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "patients_registered_total",
+			Help:      "Patients registered.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.duration, m.recordsCreated, m.patientsRegistered,
+	)
+
+	// Export both categories from the start so rates need no special case.
+	for _, category := range []string{"primary", "secondary"} {
+		m.recordsCreated.WithLabelValues(category)
+	}
+
+	return m
+}
+
+// Pool is implemented by stores backed by a database connection pool.
+type Pool interface {
+	Stats() sql.DBStats
+}
+
+// WatchPool exposes the statistics of pool, read at every scrape.
+func (m *Metrics) WatchPool(pool Pool) {
+	gauge := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(pool.Stats()) })
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(pool.Stats()) })
+	}
+
+	m.registry.MustRegister(
+		gauge("max_open_connections", "Maximum number of open connections, 0 if unlimited.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("open_connections", "Connections open, in use or idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("in_use_connections", "Connections running a query or transaction.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("idle_connections", "Connections waiting for a query.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("wait_count_total", "Queries that waited for a free connection.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("wait_duration_seconds_total", "Time spent waiting for a free connection.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+		counter("closed_max_lifetime_total", "Connections closed for exceeding the maximum lifetime.",
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }),
+	)
+}
+
+// Middleware counts and times the requests. Routes are labeled with their
+// pattern, e.g. /records/:id, so the number of series stays bounded.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		method, route := c.Request.Method, c.FullPath()
+		if route == "" {
+			method, route = unmatched, unmatched
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.requests.WithLabelValues(method, route, status).Inc()
+		m.duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RecordCreated counts a medical record of category, primary or
+// secondary.
+func (m *Metrics) RecordCreated(category string) {
+	m.recordsCreated.WithLabelValues(category).Inc()
+}
+
+// PatientRegistered counts a new patient.
+func (m *Metrics) PatientRegistered() {
+	m.patientsRegistered.Inc()
+}
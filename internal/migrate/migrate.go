@@ -0,0 +1,273 @@
+// Package migrate applies the versioned schema migrations embedded in the
+// api-server binary.
+//
+// Migrations live in one directory per database dialect and are named
+// NNNN_description.up.sql / NNNN_description.down.sql. Statements in a
+// file are separated by a semicolon at the end of a line. Applied
+// versions are tracked in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// Migration is a single schema version.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrator runs the migrations of one dialect against a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for db using the migrations of dialect, which is
+// "mysql" or "sqlite".
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %q", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+
+		content, err := files.ReadFile(path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: missing up or down file", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// statements splits a migration file into its individual statements.
+func statements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if stmt := strings.TrimSpace(current.String()); stmt != ";" {
+				stmts = append(stmts, stmt)
+			}
+			current.Reset()
+		}
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at VARCHAR(32) NOT NULL
+		)`)
+
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]string, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]string{}
+
+	for rows.Next() {
+		var version int
+		var appliedAt string
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes script and records (or forgets) the migration version in
+// a single transaction. MySQL commits DDL implicitly, so a failing
+// statement there can leave a migration partially applied.
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script := migration.down
+	if up {
+		script = migration.up
+	}
+
+	for _, stmt := range statements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now().UTC().Format(time.DateTime))
+	} else {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Up applies every pending migration in order and returns the ones it
+// applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := m.run(ctx, migration, true); err != nil {
+			return done, err
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down reverts the most recently applied migration. It returns false if
+// there was nothing to revert.
+func (m *Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return Migration{}, false, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		return migration, true, m.run(ctx, migration, false)
+	}
+
+	return Migration{}, false, nil
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestRoundTrip applies every SQLite migration, reverts them one by one
+// and applies them again, checking the version table at each step.
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Every connection to :memory: opens a database of its own.
+	db.SetMaxOpenConns(1)
+
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := len(m.migrations)
+	if all == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != all {
+		t.Fatalf("Up applied %d migrations, want %d", len(applied), all)
+	}
+	checkVersions(t, db, m, all)
+
+	if again, err := m.Up(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("second Up = %v, %v, want nothing applied", again, err)
+	}
+
+	for want := all; want > 0; want-- {
+		reverted, ok, err := m.Down(ctx)
+		if err != nil || !ok {
+			t.Fatalf("Down = %v, %v", ok, err)
+		}
+		if reverted.Version != applied[want-1].Version {
+			t.Errorf("Down reverted version %d, want %d", reverted.Version, applied[want-1].Version)
+		}
+		checkVersions(t, db, m, want-1)
+	}
+
+	if _, ok, err := m.Down(ctx); err != nil || ok {
+		t.Fatalf("Down with nothing applied = %v, %v", ok, err)
+	}
+
+	// Reverting everything leaves only the version table behind.
+	var tables []string
+	rows, err := db.QueryContext(ctx,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> 'schema_migrations'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if len(tables) != 0 {
+		t.Errorf("tables left after reverting every migration: %v", tables)
+	}
+
+	if applied, err := m.Up(ctx); err != nil || len(applied) != all {
+		t.Fatalf("Up after Down = %d migrations, %v, want %d", len(applied), err, all)
+	}
+	checkVersions(t, db, m, all)
+}
+
+// checkVersions checks that exactly the first n migrations are recorded
+// as applied.
+func checkVersions(t *testing.T, db *sql.DB, m *Migrator, n int) {
+	t.Helper()
+
+	var recorded int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != n {
+		t.Errorf("schema_migrations holds %d versions, want %d", recorded, n)
+	}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range statuses {
+		if want := i < n; status.Applied != want {
+			t.Errorf("migration %d applied = %v, want %v", status.Version, status.Applied, want)
+		}
+	}
+}
+
+// TestDialectsMatch checks that both dialects define the same versions.
+func TestDialectsMatch(t *testing.T) {
+	mysql, err := load("mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mysql) != len(sqlite) {
+		t.Fatalf("%d MySQL migrations, %d SQLite ones", len(mysql), len(sqlite))
+	}
+	for i := range mysql {
+		if mysql[i].Version != sqlite[i].Version || mysql[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d is %d %s on MySQL, %d %s on SQLite",
+				i, mysql[i].Version, mysql[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+DROP TABLE treatment;
+
+DROP TABLE record_vital_sign;
+
+DROP TABLE record_exam;
+
+DROP TABLE idx;
+
+DROP TABLE record_symptom;
+
+DROP TABLE disease_history;
+
+DROP TABLE secondary_record;
+
+DROP TABLE record_description;
+
+DROP TABLE record;
+
+DROP TABLE medicine;
+
+DROP TABLE formulation;
+
+DROP TABLE shape;
+
+DROP TABLE vital_sign;
+
+DROP TABLE unit;
+
+DROP TABLE exam;
+
+DROP TABLE symptom;
+
+DROP TABLE disease;
+
+DROP TABLE patient;
@@ -0,0 +1,147 @@
+CREATE TABLE patient (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	name VARCHAR(100) NOT NULL,
+	last_name VARCHAR(100) NOT NULL,
+	gender BOOLEAN NOT NULL,
+	PRIMARY KEY (id),
+	INDEX patient_last_name (last_name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE disease (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	description VARCHAR(255) NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE symptom (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	description VARCHAR(255) NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE exam (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	description VARCHAR(255) NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE unit (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	symbol VARCHAR(20) NOT NULL,
+	description VARCHAR(100) NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE vital_sign (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	unit_id BIGINT NOT NULL,
+	description VARCHAR(100) NOT NULL,
+	PRIMARY KEY (id),
+	FOREIGN KEY (unit_id) REFERENCES unit (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE shape (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	description VARCHAR(100) NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE formulation (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	shape_id BIGINT NOT NULL,
+	unit_id BIGINT NOT NULL,
+	PRIMARY KEY (id),
+	FOREIGN KEY (shape_id) REFERENCES shape (id),
+	FOREIGN KEY (unit_id) REFERENCES unit (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE medicine (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	formulation_id BIGINT NOT NULL,
+	name VARCHAR(150) NOT NULL,
+	dose BIGINT NOT NULL,
+	PRIMARY KEY (id),
+	FOREIGN KEY (formulation_id) REFERENCES formulation (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE record (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	category ENUM('primary', 'secondary') NOT NULL,
+	rdate DATE NOT NULL,
+	PRIMARY KEY (id),
+	INDEX record_rdate (rdate)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE record_description (
+	record_id BIGINT NOT NULL,
+	patient_id BIGINT NOT NULL,
+	age BIGINT NOT NULL,
+	weight BIGINT NOT NULL,
+	height BIGINT NOT NULL,
+	duration BIGINT NOT NULL,
+	PRIMARY KEY (record_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (patient_id) REFERENCES patient (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE secondary_record (
+	record_id BIGINT NOT NULL,
+	primary_record_id BIGINT NOT NULL,
+	PRIMARY KEY (record_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (primary_record_id) REFERENCES record (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE disease_history (
+	record_id BIGINT NOT NULL,
+	disease_id BIGINT NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	PRIMARY KEY (record_id, disease_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (disease_id) REFERENCES disease (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE record_symptom (
+	record_id BIGINT NOT NULL,
+	symptom_id BIGINT NOT NULL,
+	PRIMARY KEY (record_id, symptom_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (symptom_id) REFERENCES symptom (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE idx (
+	record_id BIGINT NOT NULL,
+	disease_id BIGINT NOT NULL,
+	PRIMARY KEY (record_id, disease_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (disease_id) REFERENCES disease (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE record_exam (
+	record_id BIGINT NOT NULL,
+	exam_id BIGINT NOT NULL,
+	PRIMARY KEY (record_id, exam_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (exam_id) REFERENCES exam (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE record_vital_sign (
+	record_id BIGINT NOT NULL,
+	vital_sign_id BIGINT NOT NULL,
+	value DOUBLE NOT NULL,
+	PRIMARY KEY (record_id, vital_sign_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (vital_sign_id) REFERENCES vital_sign (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE treatment (
+	record_id BIGINT NOT NULL,
+	medicine_id BIGINT NOT NULL,
+	quantity BIGINT NOT NULL,
+	dosage DOUBLE NOT NULL,
+	frequency BIGINT NOT NULL,
+	instructions VARCHAR(255) NOT NULL,
+	PRIMARY KEY (record_id, medicine_id),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (medicine_id) REFERENCES medicine (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,7 @@
+CREATE TABLE app_user (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	username VARCHAR(100) NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX app_user_username (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE app_user ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'reception';
@@ -0,0 +1,21 @@
+CREATE TABLE audit_log (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	user_id BIGINT NULL,
+	username VARCHAR(100) NOT NULL,
+	action VARCHAR(20) NOT NULL,
+	resource_type VARCHAR(50) NOT NULL,
+	resource_id BIGINT NULL,
+	patient_id BIGINT NULL,
+	created_at DATETIME(6) NOT NULL,
+	client_ip VARCHAR(45) NOT NULL,
+	status SMALLINT NOT NULL,
+	outcome VARCHAR(20) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX audit_log_patient (patient_id, created_at),
+	INDEX audit_log_user (user_id, created_at),
+	INDEX audit_log_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
@@ -0,0 +1 @@
+ALTER TABLE patient DROP COLUMN deleted_at;
@@ -0,0 +1 @@
+ALTER TABLE patient ADD COLUMN deleted_at DATETIME(6) NULL;
@@ -0,0 +1,12 @@
+ALTER TABLE record ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE record_version (
+	record_id BIGINT NOT NULL,
+	version INT NOT NULL,
+	snapshot JSON NOT NULL,
+	replaced_by BIGINT NULL,
+	replaced_at DATETIME(6) NOT NULL,
+	PRIMARY KEY (record_id, version),
+	FOREIGN KEY (record_id) REFERENCES record (id),
+	FOREIGN KEY (replaced_by) REFERENCES app_user (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
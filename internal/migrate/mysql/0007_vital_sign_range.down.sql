@@ -0,0 +1 @@
+DROP TABLE vital_sign_range;
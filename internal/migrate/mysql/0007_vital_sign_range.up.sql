@@ -0,0 +1,9 @@
+CREATE TABLE vital_sign_range (
+	vital_sign_id BIGINT NOT NULL,
+	min_value DOUBLE NULL,
+	max_value DOUBLE NULL,
+	normal_low DOUBLE NULL,
+	normal_high DOUBLE NULL,
+	PRIMARY KEY (vital_sign_id),
+	FOREIGN KEY (vital_sign_id) REFERENCES vital_sign (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
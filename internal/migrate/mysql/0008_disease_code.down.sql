@@ -0,0 +1 @@
+ALTER TABLE disease DROP INDEX disease_code, DROP COLUMN code;
@@ -0,0 +1 @@
+ALTER TABLE disease ADD COLUMN code VARCHAR(8) NULL, ADD UNIQUE INDEX disease_code (code);
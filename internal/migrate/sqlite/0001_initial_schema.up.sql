@@ -0,0 +1,117 @@
+CREATE TABLE patient (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	last_name TEXT NOT NULL,
+	gender INTEGER NOT NULL
+);
+
+CREATE TABLE disease (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	description TEXT NOT NULL
+);
+
+CREATE TABLE symptom (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	description TEXT NOT NULL
+);
+
+CREATE TABLE exam (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	description TEXT NOT NULL
+);
+
+CREATE TABLE unit (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	symbol TEXT NOT NULL,
+	description TEXT NOT NULL
+);
+
+CREATE TABLE vital_sign (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	unit_id INTEGER NOT NULL REFERENCES unit (id),
+	description TEXT NOT NULL
+);
+
+CREATE TABLE shape (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	description TEXT NOT NULL
+);
+
+CREATE TABLE formulation (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	shape_id INTEGER NOT NULL REFERENCES shape (id),
+	unit_id INTEGER NOT NULL REFERENCES unit (id)
+);
+
+CREATE TABLE medicine (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	formulation_id INTEGER NOT NULL REFERENCES formulation (id),
+	name TEXT NOT NULL,
+	dose INTEGER NOT NULL
+);
+
+CREATE TABLE record (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	category TEXT NOT NULL CHECK (category IN ('primary', 'secondary')),
+	rdate TEXT NOT NULL
+);
+
+CREATE TABLE record_description (
+	record_id INTEGER PRIMARY KEY REFERENCES record (id),
+	patient_id INTEGER NOT NULL REFERENCES patient (id),
+	age INTEGER NOT NULL,
+	weight INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	duration INTEGER NOT NULL
+);
+
+CREATE TABLE secondary_record (
+	record_id INTEGER PRIMARY KEY REFERENCES record (id),
+	primary_record_id INTEGER NOT NULL REFERENCES record (id)
+);
+
+CREATE TABLE disease_history (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	disease_id INTEGER NOT NULL REFERENCES disease (id),
+	description TEXT NOT NULL,
+	PRIMARY KEY (record_id, disease_id)
+);
+
+CREATE TABLE record_symptom (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	symptom_id INTEGER NOT NULL REFERENCES symptom (id),
+	PRIMARY KEY (record_id, symptom_id)
+);
+
+CREATE TABLE idx (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	disease_id INTEGER NOT NULL REFERENCES disease (id),
+	PRIMARY KEY (record_id, disease_id)
+);
+
+CREATE TABLE record_exam (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	exam_id INTEGER NOT NULL REFERENCES exam (id),
+	PRIMARY KEY (record_id, exam_id)
+);
+
+CREATE TABLE record_vital_sign (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	vital_sign_id INTEGER NOT NULL REFERENCES vital_sign (id),
+	value REAL NOT NULL,
+	PRIMARY KEY (record_id, vital_sign_id)
+);
+
+CREATE TABLE treatment (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	medicine_id INTEGER NOT NULL REFERENCES medicine (id),
+	quantity INTEGER NOT NULL,
+	dosage REAL NOT NULL,
+	frequency INTEGER NOT NULL,
+	instructions TEXT NOT NULL,
+	PRIMARY KEY (record_id, medicine_id)
+);
+
+CREATE INDEX patient_last_name ON patient (last_name);
+
+CREATE INDEX record_rdate ON record (rdate);
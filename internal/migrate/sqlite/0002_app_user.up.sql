@@ -0,0 +1,5 @@
+CREATE TABLE app_user (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL
+);
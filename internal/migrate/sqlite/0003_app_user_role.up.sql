@@ -0,0 +1 @@
+ALTER TABLE app_user ADD COLUMN role TEXT NOT NULL DEFAULT 'reception';
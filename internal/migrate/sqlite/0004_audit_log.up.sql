@@ -0,0 +1,23 @@
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NULL,
+	username TEXT NOT NULL,
+	action TEXT NOT NULL,
+	resource_type TEXT NOT NULL,
+	resource_id INTEGER NULL,
+	patient_id INTEGER NULL,
+	created_at TEXT NOT NULL,
+	client_ip TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	outcome TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_patient ON audit_log (patient_id, created_at);
+
+CREATE INDEX audit_log_user ON audit_log (user_id, created_at);
+
+CREATE INDEX audit_log_created_at ON audit_log (created_at);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
@@ -0,0 +1 @@
+ALTER TABLE patient ADD COLUMN deleted_at TEXT NULL;
@@ -0,0 +1,3 @@
+DROP TABLE record_version;
+
+ALTER TABLE record DROP COLUMN version;
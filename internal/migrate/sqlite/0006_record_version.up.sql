@@ -0,0 +1,10 @@
+ALTER TABLE record ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE record_version (
+	record_id INTEGER NOT NULL REFERENCES record (id),
+	version INTEGER NOT NULL,
+	snapshot TEXT NOT NULL,
+	replaced_by INTEGER NULL REFERENCES app_user (id),
+	replaced_at TEXT NOT NULL,
+	PRIMARY KEY (record_id, version)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE vital_sign_range (
+	vital_sign_id INTEGER PRIMARY KEY REFERENCES vital_sign (id),
+	min_value REAL NULL,
+	max_value REAL NULL,
+	normal_low REAL NULL,
+	normal_high REAL NULL
+);
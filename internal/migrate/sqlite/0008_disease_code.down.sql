@@ -0,0 +1,2 @@
+DROP INDEX disease_code;
+ALTER TABLE disease DROP COLUMN code;
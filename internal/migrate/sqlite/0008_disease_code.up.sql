@@ -0,0 +1,2 @@
+ALTER TABLE disease ADD COLUMN code TEXT NULL;
+CREATE UNIQUE INDEX disease_code ON disease (code);
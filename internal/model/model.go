@@ -1,5 +1,10 @@
 package model
 
+import (
+	"log/slog"
+	"time"
+)
+
 type Data any
 
 type Response struct {
@@ -11,14 +16,54 @@ type Response struct {
 	Total    int64 `json:"total"`
 }
 
+// SearchResponse holds the best matches of a search, Data being a list
+// of search hits, and the number of matches.
+type SearchResponse struct {
+	Data  Data `json:"data"`
+	Total int  `json:"total"`
+}
+
+// CursorResponse is a page of a keyset paginated listing. NextCursor is
+// empty on the last page and Total is omitted when the client did not ask
+// for it.
+type CursorResponse struct {
+	Data       Data   `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// ImportResult reports a bulk catalog import, with the outcome of each
+// row in Rows. A dry run reports what would be created.
+type ImportResult struct {
+	Catalog    string      `json:"catalog"`
+	DryRun     bool        `json:"dry_run"`
+	Created    int         `json:"created"`
+	Duplicates int         `json:"duplicates"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+// ImportRow is the outcome of the row at Index, counted from 0: "created"
+// or "duplicate". ID is the id of the created row or of the row it
+// duplicates, unless it is not created yet.
+type ImportRow struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+}
+
 type FullRecord struct {
 	RecordObj       Record            `json:"record"`
-	DiseasesHistory []DiseaseHistory  `json:"diseases_history"`
-	Symptoms        []Symptom         `json:"symptoms"`
-	VitalSigns      []RecordVitalSign `json:"vital_signs"`
-	Diseases        []Disease         `json:"idx"`
-	Exams           []Exam            `json:"exams"`
-	Treatments      []Treatment       `json:"treatments"`
+	DiseasesHistory []DiseaseHistory  `json:"diseases_history" binding:"unique=DiseaseID,dive"`
+	Symptoms        []Symptom         `json:"symptoms" binding:"unique=ID"`
+	VitalSigns      []RecordVitalSign `json:"vital_signs" binding:"unique=VitalSignID,dive"`
+	Diseases        []Disease         `json:"idx" binding:"unique=ID"`
+	Exams           []Exam            `json:"exams" binding:"unique=ID"`
+	Treatments      []Treatment       `json:"treatments" binding:"unique=MedicineID,dive"`
+}
+
+// LogValue logs the record alone, see Record.LogValue.
+func (r FullRecord) LogValue() slog.Value {
+	return r.RecordObj.LogValue()
 }
 
 type Patient struct {
@@ -28,6 +73,11 @@ type Patient struct {
 	Gender   bool   `json:"gender"`
 }
 
+// LogValue keeps the patient's name out of the logs.
+func (p Patient) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int64("id", p.ID))
+}
+
 type Exam struct {
 	ID          int64  `json:"id"`
 	Description string `json:"description"`
@@ -35,14 +85,35 @@ type Exam struct {
 
 type Record struct {
 	ID         int64   `json:"id"`
-	Category   string  `json:"category"`
-	PrimaryID  int64   `json:"primary_record_id"`
+	Category   string  `json:"category" binding:"required,oneof=primary secondary"`
+	PrimaryID  int64   `json:"primary_record_id" binding:"required_if=Category secondary,gte=0"`
 	PatientObj Patient `json:"patient"`
-	Date       string  `json:"rdate"`
-	Age        int64   `json:"age"`
-	Weight     int64   `json:"weight"`
-	Height     int64   `json:"height"`
-	Duration   int64   `json:"duration"`
+	Date       string  `json:"rdate" binding:"required,datetime=2006-01-02"`
+	Age        int64   `json:"age" binding:"gte=0,lte=150"`
+	Weight     int64   `json:"weight" binding:"gte=0"`
+	Height     int64   `json:"height" binding:"gte=0"`
+	Duration   int64   `json:"duration" binding:"gte=0"`
+	Version    int     `json:"version,omitempty"`
+}
+
+// LogValue keeps the record's clinical data, and its patient's name, out
+// of the logs.
+func (r Record) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("id", r.ID),
+		slog.String("category", r.Category),
+		slog.Int64("patient_id", r.PatientObj.ID),
+	)
+}
+
+// RecordVersion describes one version of a medical record. Version 1 is
+// the record as created; every amendment adds the next one.
+type RecordVersion struct {
+	Version   int        `json:"version"`
+	AmendedBy int64      `json:"amended_by,omitempty"`
+	AmendedAt *time.Time `json:"amended_at,omitempty"`
+	Changes   []string   `json:"changes,omitempty"`
+	Record    FullRecord `json:"-"`
 }
 
 type RecordExam struct {
@@ -59,30 +130,53 @@ type Unit struct {
 }
 
 type VitalSign struct {
-	ID          int64  `json:"id"`
-	UnitObj     Unit   `json:"unit"`
-	Description string `json:"description"`
+	ID          int64           `json:"id"`
+	UnitObj     Unit            `json:"unit"`
+	Description string          `json:"description"`
+	Range       *VitalSignRange `json:"range,omitempty"`
+}
+
+// VitalSignRange holds the reference values of a vital sign, in the unit
+// of the vital sign. Min and Max are physiological hard limits; values
+// outside NormalLow and NormalHigh are flagged as abnormal. Any bound may
+// be missing.
+type VitalSignRange struct {
+	Min        *float64 `json:"min"`
+	Max        *float64 `json:"max"`
+	NormalLow  *float64 `json:"normal_low"`
+	NormalHigh *float64 `json:"normal_high"`
 }
 
 type RecordVitalSign struct {
 	RecordID    int64   `json:"record_id"`
-	VitalSignID int64   `json:"vital_sign_id"`
+	VitalSignID int64   `json:"vital_sign_id" binding:"required"`
 	Description string  `json:"vital_sign_desc"`
 	UnitID      int64   `json:"unit_id"`
 	Symbol      string  `json:"unit_symbol"`
 	Value       float64 `json:"value"`
+	Flag        string  `json:"flag,omitempty"`
 }
 
 type Disease struct {
-	ID          int64  `json:"id"`
-	Description string `json:"description"`
+	ID int64 `json:"id"`
+	// Code is the ICD-10 code of the disease, if it has one. Category and
+	// Chapter are derived from it.
+	Code        string        `json:"code,omitempty"`
+	Description string        `json:"description"`
+	Category    string        `json:"category,omitempty"`
+	Chapter     *ICD10Chapter `json:"chapter,omitempty"`
+}
+
+type ICD10Chapter struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
 }
 
 type DiseaseHistory struct {
 	RecordID    int64  `json:"record_id"`
-	DiseaseID   int64  `json:"disease_id"`
+	DiseaseID   int64  `json:"disease_id" binding:"required"`
 	DiseaseDesc string `json:"disease_desc"`
-	Description string `json:"description"`
+	Description string `json:"description" binding:"max=255"`
 }
 
 type Symptom struct {
@@ -124,7 +218,7 @@ type Medicine struct {
 
 type Treatment struct {
 	RecordID      int64   `json:"record_id"`
-	MedicineID    int64   `json:"medicine_id"`
+	MedicineID    int64   `json:"medicine_id" binding:"required"`
 	Name          string  `json:"medicine_name"`
 	Dose          int64   `json:"medicine_dose"`
 	FormulationID int64   `json:"formulation_id"`
@@ -132,8 +226,29 @@ type Treatment struct {
 	Description   string  `json:"shape_description"`
 	UnitID        int64   `json:"unit_id"`
 	Symbol        string  `json:"unit_symbol"`
-	Quantity      int64   `json:"quantity"`
-	Dosage        float64 `json:"dosage"`
-	Frequency     int64   `json:"frequency"`
-	Instructions  string  `json:"instructions"`
+	Quantity      int64   `json:"quantity" binding:"gt=0"`
+	Dosage        float64 `json:"dosage" binding:"gt=0"`
+	Frequency     int64   `json:"frequency" binding:"gt=0"`
+	Instructions  string  `json:"instructions" binding:"max=255"`
+}
+
+type User struct {
+	ID           int64  `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+}
+
+type AuditEntry struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	Username     string    `json:"username"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   int64     `json:"resource_id"`
+	PatientID    int64     `json:"patient_id"`
+	Time         time.Time `json:"time"`
+	ClientIP     string    `json:"client_ip"`
+	Status       int       `json:"status"`
+	Outcome      string    `json:"outcome"`
 }
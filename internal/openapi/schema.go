@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf returns the schema of the JSON encoding of v. Named structs
+// are added to the component schemas, under their capitalized type name,
+// and referenced. Their fields follow encoding/json, and the binding tags
+// gin validates requests with become constraints: required, oneof, min,
+// max, gt, gte, lte and datetime.
+func (d *Document) SchemaOf(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := d.schemaOf(t.Elem())
+		if schema.Ref != "" {
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return ArrayOf(d.schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return d.structSchema(t)
+	}
+
+	// Interfaces, such as model.Data, hold any value.
+	return &Schema{}
+}
+
+// structSchema returns a reference to the component schema of t, adding
+// it first if needed. Anonymous and generic structs are inlined.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	name := t.Name()
+	if name == "" || strings.Contains(name, "[") {
+		return d.objectSchema(t)
+	}
+	// Unexported request types, such as loginRequest, are named like
+	// exported ones.
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	if _, ok := d.Components.Schemas[name]; !ok {
+		// Register the name before walking the fields so recursive types
+		// refer to themselves.
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.objectSchema(t)
+	}
+
+	return Ref(name)
+}
+
+func (d *Document) objectSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(schema, t)
+
+	return schema
+}
+
+// addFields adds the JSON encoded fields of t to schema, the fields of
+// embedded structs included.
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			d.addFields(schema, field.Type)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaOf(field.Type)
+		if constrain(property, field.Tag.Get("binding")) {
+			// Required pointers only tell a zero value from a missing one.
+			property.Nullable = false
+			schema.Required = append(schema.Required, name)
+		}
+
+		schema.Properties[name] = property
+	}
+}
+
+// constrain adds the constraints of a binding tag to schema and reports
+// whether they make the field required.
+func constrain(schema *Schema, binding string) bool {
+	if binding == "" {
+		return false
+	}
+
+	// Constraints cannot sit next to a reference.
+	if schema.Ref != "" {
+		return strings.Contains(","+binding+",", ",required,")
+	}
+
+	required := false
+	text := schema.Type == "string"
+
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		n, err := strconv.ParseFloat(param, 64)
+		hasNumber := err == nil
+
+		switch {
+		case tag == "required":
+			required = true
+		case tag == "oneof":
+			schema.Enum = strings.Fields(param)
+		case tag == "datetime" && param == time.DateOnly:
+			schema.Format = "date"
+		case !hasNumber:
+		case text && tag == "min":
+			length := int(n)
+			schema.MinLength = &length
+		case text && tag == "max":
+			length := int(n)
+			schema.MaxLength = &length
+		case tag == "min" || tag == "gte":
+			schema.Minimum = &n
+		case tag == "gt":
+			schema.Minimum = &n
+			schema.ExclusiveMinimum = true
+		case tag == "max" || tag == "lte":
+			schema.Maximum = &n
+		}
+	}
+
+	return required
+}
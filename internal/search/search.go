@@ -0,0 +1,395 @@
+// Package search implements the ranked, accent-insensitive search behind
+// the api-server's /search routes.
+//
+// Text is split into words which are folded: lower-cased and stripped of
+// diacritics, so "Pérez" and "PEREZ" both index as "perez". An Index maps
+// every folded word to the documents holding it, and is kept up to date
+// as documents are put and deleted. A document matches a query when each
+// query word is found in one of its words, and ranks higher the more
+// exactly the words match.
+package search
+
+import (
+	"cmp"
+	"html"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Weights of a query word found as a whole word, at the start of a word
+// or anywhere inside one. A match on the first word of a field gets
+// firstWordBonus on top.
+const (
+	exactWeight    = 3
+	prefixWeight   = 2
+	infixWeight    = 1
+	firstWordBonus = 0.5
+)
+
+// Fold lower-cases s and removes its diacritics.
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+
+	return strings.ToLower(folded)
+}
+
+// word is a word of a text, folded, with its byte offsets in the text.
+type word struct {
+	folded     string
+	start, end int
+}
+
+// words splits s into words made of letters and digits.
+func words(s string) []word {
+	var ws []word
+
+	start := -1
+	for i, r := range s + " " {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			ws = append(ws, word{folded: Fold(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+
+	return ws
+}
+
+// Field is a piece of text a document is searched by.
+type Field struct {
+	Name string
+	Text string
+}
+
+// Hit is a document matching a query.
+type Hit[T any] struct {
+	Item  T       `json:"item"`
+	Score float64 `json:"score"`
+	// Highlights holds the matching fields, HTML escaped, with the matching
+	// words wrapped in <mark> tags.
+	Highlights map[string]string `json:"highlights"`
+}
+
+type document[T any] struct {
+	item   T
+	fields []Field
+	words  [][]word
+	// sortKey orders documents of equal score.
+	sortKey string
+}
+
+// gramSize is the length, in runes, of the longest substrings of terms
+// indexed in grams.
+const gramSize = 3
+
+// Index is an inverted index over a set of documents, each identified by
+// a key. It is safe for concurrent use: documents can be put and deleted
+// while it is searched.
+type Index[T any] struct {
+	key    func(T) int64
+	fields func(T) []Field
+
+	mu   sync.RWMutex
+	docs map[int64]*document[T]
+	// postings maps every term to the documents holding it, and whether
+	// one of them holds it as the first word of a field.
+	postings map[string]map[int64]bool
+	// grams maps every substring of up to gramSize runes to the terms
+	// containing it, so a query word is only compared to the terms
+	// sharing its substrings.
+	grams map[string]map[string]struct{}
+}
+
+// New indexes items, identified by key, by the fields returned by fields.
+func New[T any](items []T, key func(T) int64, fields func(T) []Field) *Index[T] {
+	ix := &Index[T]{
+		key:      key,
+		fields:   fields,
+		docs:     map[int64]*document[T]{},
+		postings: map[string]map[int64]bool{},
+		grams:    map[string]map[string]struct{}{},
+	}
+
+	for _, item := range items {
+		ix.put(item)
+	}
+
+	return ix
+}
+
+// Put indexes item, replacing the document with the same key if any.
+func (ix *Index[T]) Put(item T) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.put(item)
+}
+
+// Delete removes the document with key, if any.
+func (ix *Index[T]) Delete(key int64) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.delete(key)
+}
+
+func (ix *Index[T]) put(item T) {
+	key := ix.key(item)
+	ix.delete(key)
+
+	doc := &document[T]{item: item, fields: ix.fields(item)}
+
+	for _, field := range doc.fields {
+		ws := words(field.Text)
+		doc.words = append(doc.words, ws)
+		doc.sortKey += Fold(field.Text) + "\x00"
+
+		for j, w := range ws {
+			docs, ok := ix.postings[w.folded]
+			if !ok {
+				docs = map[int64]bool{}
+				ix.postings[w.folded] = docs
+				ix.addGrams(w.folded)
+			}
+			docs[key] = docs[key] || j == 0
+		}
+	}
+
+	ix.docs[key] = doc
+}
+
+func (ix *Index[T]) delete(key int64) {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+
+	for _, ws := range doc.words {
+		for _, w := range ws {
+			docs, ok := ix.postings[w.folded]
+			if !ok {
+				continue
+			}
+
+			delete(docs, key)
+			if len(docs) == 0 {
+				delete(ix.postings, w.folded)
+				ix.removeGrams(w.folded)
+			}
+		}
+	}
+
+	delete(ix.docs, key)
+}
+
+// termGrams returns the distinct substrings of term of up to gramSize
+// runes.
+func termGrams(term string) []string {
+	r := []rune(term)
+
+	var grams []string
+	for size := 1; size <= gramSize; size++ {
+		for i := 0; i+size <= len(r); i++ {
+			if gram := string(r[i : i+size]); !slices.Contains(grams, gram) {
+				grams = append(grams, gram)
+			}
+		}
+	}
+
+	return grams
+}
+
+func (ix *Index[T]) addGrams(term string) {
+	for _, gram := range termGrams(term) {
+		terms, ok := ix.grams[gram]
+		if !ok {
+			terms = map[string]struct{}{}
+			ix.grams[gram] = terms
+		}
+		terms[term] = struct{}{}
+	}
+}
+
+func (ix *Index[T]) removeGrams(term string) {
+	for _, gram := range termGrams(term) {
+		delete(ix.grams[gram], term)
+		if len(ix.grams[gram]) == 0 {
+			delete(ix.grams, gram)
+		}
+	}
+}
+
+// candidates returns terms that may contain q: those containing it if q
+// is at most gramSize runes long, otherwise those containing its rarest
+// substring of gramSize runes.
+func (ix *Index[T]) candidates(q string) map[string]struct{} {
+	r := []rune(q)
+	if len(r) <= gramSize {
+		return ix.grams[q]
+	}
+
+	var rarest map[string]struct{}
+	for i := 0; i+gramSize <= len(r); i++ {
+		terms := ix.grams[string(r[i:i+gramSize])]
+		if len(terms) == 0 {
+			return nil
+		}
+		if rarest == nil || len(terms) < len(rarest) {
+			rarest = terms
+		}
+	}
+
+	return rarest
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index[T]) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docs)
+}
+
+// Search returns the limit best documents matching query and kept by
+// keep, which may be nil, along with the number of matching documents.
+func (ix *Index[T]) Search(query string, limit int, keep func(T) bool) ([]Hit[T], int) {
+	var queryWords []string
+	for _, w := range words(query) {
+		if !slices.Contains(queryWords, w.folded) {
+			queryWords = append(queryWords, w.folded)
+		}
+	}
+
+	if len(queryWords) == 0 {
+		return nil, 0
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var scores map[int64]float64
+
+	for _, q := range queryWords {
+		best := ix.match(q)
+
+		if scores == nil {
+			scores = best
+			continue
+		}
+
+		for key, score := range scores {
+			if word, ok := best[key]; ok {
+				scores[key] = score + word
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	var matches []int64
+	for key := range scores {
+		if keep == nil || keep(ix.docs[key].item) {
+			matches = append(matches, key)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b int64) int {
+		switch {
+		case scores[a] > scores[b]:
+			return -1
+		case scores[a] < scores[b]:
+			return 1
+		}
+		if c := strings.Compare(ix.docs[a].sortKey, ix.docs[b].sortKey); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	hits := []Hit[T]{}
+	for _, key := range matches[:min(limit, len(matches))] {
+		hits = append(hits, Hit[T]{
+			Item:       ix.docs[key].item,
+			Score:      scores[key],
+			Highlights: ix.highlight(ix.docs[key], queryWords),
+		})
+	}
+
+	return hits, len(matches)
+}
+
+// match scores every document holding a word that contains q, keeping
+// the best scoring word of each.
+func (ix *Index[T]) match(q string) map[int64]float64 {
+	best := map[int64]float64{}
+
+	for term := range ix.candidates(q) {
+		var weight float64
+
+		switch {
+		case term == q:
+			weight = exactWeight
+		case strings.HasPrefix(term, q):
+			weight = prefixWeight
+		case strings.Contains(term, q):
+			weight = infixWeight
+		default:
+			continue
+		}
+
+		for key, first := range ix.postings[term] {
+			score := weight
+			if first {
+				score += firstWordBonus
+			}
+
+			best[key] = max(best[key], score)
+		}
+	}
+
+	return best
+}
+
+// highlight marks the words of doc that contain one of queryWords.
+func (ix *Index[T]) highlight(doc *document[T], queryWords []string) map[string]string {
+	highlights := map[string]string{}
+
+	for i, field := range doc.fields {
+		var b strings.Builder
+		last := 0
+		marked := false
+
+		for _, w := range doc.words[i] {
+			if !slices.ContainsFunc(queryWords, func(q string) bool { return strings.Contains(w.folded, q) }) {
+				continue
+			}
+
+			b.WriteString(html.EscapeString(field.Text[last:w.start]))
+			b.WriteString("<mark>" + html.EscapeString(field.Text[w.start:w.end]) + "</mark>")
+			last = w.end
+			marked = true
+		}
+
+		if marked {
+			b.WriteString(html.EscapeString(field.Text[last:]))
+			highlights[field.Name] = b.String()
+		}
+	}
+
+	return highlights
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+type person struct {
+	id          int64
+	first, last string
+}
+
+func newPeople(people ...person) *Index[person] {
+	return New(people,
+		func(p person) int64 { return p.id },
+		func(p person) []Field {
+			return []Field{{Name: "name", Text: p.first}, {Name: "last_name", Text: p.last}}
+		})
+}
+
+// ids returns the ids of the hits for query, best first.
+func ids(ix *Index[person], query string) []int64 {
+	hits, total := ix.Search(query, 100, nil)
+	if total != len(hits) {
+		panic("total differs from the hits of an unlimited search")
+	}
+
+	var ids []int64
+	for _, hit := range hits {
+		ids = append(ids, hit.Item.id)
+	}
+
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	ix := newPeople(
+		person{1, "Ana", "Pérez"},
+		person{2, "Luis", "Perezoso"},
+		person{3, "Inés", "López"},
+		person{4, "José", "Gómez Pérez"},
+	)
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		// Whole words, then first words of fields, rank first; ties go by
+		// name.
+		{"perez", []int64{1, 4, 2}},
+		{"PÉREZ ana", []int64{1}},
+		{"ez", []int64{1, 3, 4, 2}},
+		{"z", []int64{1, 3, 4, 2}},
+		{"erezo", []int64{2}},
+		{"omez", []int64{4}},
+		{"lopezz", nil},
+		{"ana luis", nil},
+	}
+
+	for _, tt := range tests {
+		if got := ids(ix, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPutAndDelete(t *testing.T) {
+	ix := newPeople(person{1, "Ana", "Pérez"}, person{2, "Luis", "Torres"})
+
+	ix.Put(person{3, "Rosa", "Pérez"})
+	if got := ids(ix, "perez"); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("after adding, perez = %v", got)
+	}
+
+	// Updating replaces the words of the document.
+	ix.Put(person{1, "Ana", "Quispe"})
+	if got := ids(ix, "perez"); !slices.Equal(got, []int64{3}) {
+		t.Errorf("after updating, perez = %v", got)
+	}
+	if got := ids(ix, "quis"); !slices.Equal(got, []int64{1}) {
+		t.Errorf("after updating, quis = %v", got)
+	}
+
+	ix.Delete(3)
+	ix.Delete(99)
+	if got := ids(ix, "perez"); got != nil {
+		t.Errorf("after deleting, perez = %v", got)
+	}
+	if ix.Len() != 2 {
+		t.Errorf("Len = %d, want 2", ix.Len())
+	}
+
+	// Terms no document holds anymore are gone from the index.
+	if len(ix.postings["perez"]) != 0 || len(ix.grams["rez"]) != 0 {
+		t.Errorf("perez still indexed: %v, %v", ix.postings["perez"], ix.grams["rez"])
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	ix := newPeople(person{1, "Ana María", "Pérez <b>"})
+
+	hits, _ := ix.Search("mari perez", 1, nil)
+	if len(hits) != 1 {
+		t.Fatalf("hits = %v", hits)
+	}
+
+	want := map[string]string{"name": "Ana <mark>María</mark>", "last_name": "<mark>Pérez</mark> &lt;b&gt;"}
+	for field, highlight := range want {
+		if hits[0].Highlights[field] != highlight {
+			t.Errorf("highlight of %s = %q, want %q", field, hits[0].Highlights[field], highlight)
+		}
+	}
+}
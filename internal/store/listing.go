@@ -0,0 +1,118 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The order of each listing when no sort is given. Keyset pagination
+// always uses it.
+var (
+	defaultPatientSort  = []SortField{{Field: "last_name"}}
+	defaultRecordSort   = []SortField{{Field: "rdate", Desc: true}}
+	defaultMedicineSort = []SortField{{Field: "name"}}
+	defaultDiseaseSort  = []SortField{{Field: "description"}}
+)
+
+// LoadAll returns every row of a listing: count rows, read by list from
+// offset 0. Rows added in between may be left out.
+func LoadAll[T any](count func() (int64, error), list func(offset, limit int) ([]T, error)) ([]T, error) {
+	total, err := count()
+	if err != nil || total == 0 {
+		return nil, err
+	}
+
+	return list(0, int(total))
+}
+
+// orderOf returns sort, or fallback if it is empty, followed by id in the
+// direction of the first field so rows with equal keys keep the same order
+// from one page to the next.
+func orderOf(sort, fallback []SortField) []SortField {
+	if len(sort) == 0 {
+		sort = fallback
+	}
+
+	for _, field := range sort {
+		if field.Field == "id" {
+			return sort
+		}
+	}
+
+	return append(sort[:len(sort):len(sort)], SortField{Field: "id", Desc: sort[0].Desc})
+}
+
+// listQuery builds the WHERE and ORDER BY clauses of a listing, so the SQL
+// listings only spell out what they select and join.
+type listQuery struct {
+	conditions []string
+	args       []any
+	order      []string
+}
+
+// where adds a condition with its arguments. Conditions are parenthesized
+// and ANDed together.
+func (q *listQuery) where(condition string, args ...any) {
+	q.conditions = append(q.conditions, "("+condition+")")
+	q.args = append(q.args, args...)
+}
+
+// sortColumn is the column sorting a listing by a field. Text columns
+// sort ignoring case.
+type sortColumn struct {
+	name string
+	text bool
+}
+
+// orderBy sorts by sort, or fallback, naming the column of each field
+// with columns.
+func (q *listQuery) orderBy(d dialect, sort, fallback []SortField, columns map[string]sortColumn) error {
+	q.order = nil
+
+	for _, field := range orderOf(sort, fallback) {
+		sortColumn, ok := columns[field.Field]
+		if !ok {
+			return fmt.Errorf("store: cannot sort by %q", field.Field)
+		}
+
+		column := sortColumn.name
+		if sortColumn.text {
+			column = d.fold(column)
+		}
+
+		if field.Desc {
+			column += " DESC"
+		} else {
+			column += " ASC"
+		}
+
+		q.order = append(q.order, column)
+	}
+
+	return nil
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(q.conditions, " AND ") + " "
+}
+
+// count returns the statement counting the rows selected from from.
+func (q *listQuery) count(from string) (string, []any) {
+	return "SELECT COUNT(*) AS total " + from + " " + q.whereClause(), q.args
+}
+
+// list returns the statement selecting limit rows starting at offset.
+func (q *listQuery) list(d dialect, selectFrom string, offset, limit int) (string, []any) {
+	limitClause, limitArgs := d.limit(offset, limit)
+
+	query := selectFrom + " " + q.whereClause()
+	if len(q.order) > 0 {
+		query += "ORDER BY " + strings.Join(q.order, ", ") + " "
+	}
+
+	return query + limitClause, append(q.args[:len(q.args):len(q.args)], limitArgs...)
+}
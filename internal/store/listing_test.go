@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// TestListingIgnoresCase checks that the backends agree on the order of
+// names differing in case, when sorting and when paging by cursor.
+func TestListingIgnoresCase(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for _, lastname := range []string{"Diaz", "alva", "de la Cruz", "DIAZ", "Bravo"} {
+				patient := model.Patient{Name: "Ana", Lastname: lastname}
+				if err := st.CreatePatient(ctx, &patient); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Equal names keep the order of their ids.
+			want := []string{"alva", "Bravo", "de la Cruz", "Diaz", "DIAZ"}
+
+			patients, err := st.ListPatients(ctx, PatientFilter{}, []SortField{{Field: "last_name"}}, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := lastNames(patients); !slices.Equal(got, want) {
+				t.Errorf("ListPatients = %q, want %q", got, want)
+			}
+
+			var paged []model.Patient
+			var after Cursor
+			for range want {
+				page, err := st.ListPatientsAfter(ctx, PatientFilter{}, after, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page) == 0 {
+					break
+				}
+
+				paged = append(paged, page...)
+				last := page[len(page)-1]
+				after = Cursor{Key: last.Lastname, ID: last.ID}
+			}
+			if got := lastNames(paged); !slices.Equal(got, want) {
+				t.Errorf("ListPatientsAfter pages = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func lastNames(patients []model.Patient) []string {
+	var names []string
+	for _, patient := range patients {
+		names = append(names, patient.Lastname)
+	}
+
+	return names
+}
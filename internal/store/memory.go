@@ -0,0 +1,1391 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// Memory is a Store that keeps every table in process memory. It mirrors
+// the behavior of the SQL backends, including foreign key checks, so it
+// can stand in for them in tests and demo mode.
+type Memory struct {
+	mu sync.RWMutex
+
+	lastID map[string]int64
+
+	patients        []model.Patient
+	deletedPatients map[int64]bool
+	diseases        []model.Disease
+	symptoms        []model.Symptom
+	exams           []model.Exam
+	units           []model.Unit
+	vitalSigns      []memVitalSign
+	vitalSignRanges map[int64]model.VitalSignRange
+	shapes          []model.Shape
+	formulations    []memFormulation
+	medicines       []memMedicine
+
+	records            []memRecord
+	recordDescriptions map[int64]memRecordDescription
+	secondaryRecords   map[int64]int64
+	diseaseHistories   []model.DiseaseHistory
+	recordSymptoms     []memLink
+	idx                []memLink
+	recordExams        []memLink
+	recordVitalSigns   []memRecordVitalSign
+	treatments         []memTreatment
+	recordVersions     []memRecordVersion
+
+	users []model.User
+	audit []model.AuditEntry
+}
+
+var _ Store = (*Memory)(nil)
+
+type memVitalSign struct {
+	id          int64
+	unitID      int64
+	description string
+}
+
+type memFormulation struct {
+	id      int64
+	shapeID int64
+	unitID  int64
+}
+
+type memMedicine struct {
+	id            int64
+	formulationID int64
+	name          string
+	dose          int64
+}
+
+type memRecord struct {
+	id       int64
+	category string
+	date     string
+	version  int
+}
+
+type memRecordDescription struct {
+	patientID int64
+	age       int64
+	weight    int64
+	height    int64
+	duration  int64
+}
+
+// memLink is a row of a (record_id, other_id) join table.
+type memLink struct {
+	recordID int64
+	id       int64
+}
+
+type memRecordVitalSign struct {
+	recordID    int64
+	vitalSignID int64
+	value       float64
+}
+
+type memTreatment struct {
+	recordID     int64
+	medicineID   int64
+	quantity     int64
+	dosage       float64
+	frequency    int64
+	instructions string
+}
+
+type memRecordVersion struct {
+	recordID   int64
+	version    int
+	snapshot   model.FullRecord
+	replacedBy int64
+	replacedAt time.Time
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		lastID:             map[string]int64{},
+		deletedPatients:    map[int64]bool{},
+		vitalSignRanges:    map[int64]model.VitalSignRange{},
+		recordDescriptions: map[int64]memRecordDescription{},
+		secondaryRecords:   map[int64]int64{},
+	}
+}
+
+// nextID emulates an AUTO_INCREMENT column for table. Callers must hold
+// the write lock.
+// Ping always succeeds: the memory store has nothing to reach.
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) nextID(table string) int64 {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// contains reports whether s contains substr, ignoring case, the way
+// LIKE '%substr%' does under MySQL's default collation.
+func contains(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// window returns items[offset:offset+limit], clamped to the slice bounds.
+func window[T any](items []T, offset, limit int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset >= len(items) || limit <= 0 {
+		return nil
+	}
+
+	end := min(offset+limit, len(items))
+	return slices.Clone(items[offset:end])
+}
+
+// ascending orders cursors by key, ignoring case like the SQL backends,
+// then by id.
+func ascending(a, b Cursor) int {
+	if c := cmp.Compare(strings.ToLower(a.Key), strings.ToLower(b.Key)); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func descending(a, b Cursor) int {
+	return ascending(b, a)
+}
+
+// keysetWindow returns up to limit items following after, items being
+// sorted by compare applied to their position.
+func keysetWindow[T any](items []T, after Cursor, position func(T) Cursor, compare func(a, b Cursor) int, limit int) []T {
+	start := 0
+
+	if after.ID != 0 {
+		start = len(items)
+
+		for i, item := range items {
+			if compare(position(item), after) > 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	return window(items, start, limit)
+}
+
+// sortBy sorts items like orderOf(sort, fallback), fields holding the
+// comparison of each sortable field.
+func sortBy[T any](items []T, sort, fallback []SortField, fields map[string]func(a, b T) int) error {
+	order := orderOf(sort, fallback)
+
+	for _, field := range order {
+		if _, ok := fields[field.Field]; !ok {
+			return fmt.Errorf("store: cannot sort by %q", field.Field)
+		}
+	}
+
+	slices.SortFunc(items, func(a, b T) int {
+		for _, field := range order {
+			c := fields[field.Field](a, b)
+			if field.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	return nil
+}
+
+// compareFold compares strings ignoring case, like the SQL backends do for
+// listings. The three fold letters outside ASCII differently: SQLite's
+// NOCASE does not fold them at all and MySQL also ignores accents, so
+// names such as "Ñ" and "Á" may sort differently from one backend to the
+// other.
+func compareFold(a, b string) int {
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func find[T any](items []T, match func(T) bool) (T, bool) {
+	for _, item := range items {
+		if match(item) {
+			return item, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+//--------------------------------------
+// Patients
+//--------------------------------------
+
+var patientFields = map[string]func(a, b model.Patient) int{
+	"id":        func(a, b model.Patient) int { return cmp.Compare(a.ID, b.ID) },
+	"name":      func(a, b model.Patient) int { return compareFold(a.Name, b.Name) },
+	"last_name": func(a, b model.Patient) int { return compareFold(a.Lastname, b.Lastname) },
+	"gender":    func(a, b model.Patient) int { return compareBool(a.Gender, b.Gender) },
+}
+
+func (m *Memory) filterPatients(filter PatientFilter) []model.Patient {
+	var patients []model.Patient
+
+	for _, patient := range m.patients {
+		if m.deletedPatients[patient.ID] {
+			continue
+		}
+
+		if filter.Name != "" && !contains(patient.Name, filter.Name) && !contains(patient.Lastname, filter.Name) {
+			continue
+		}
+
+		if filter.Gender != nil && patient.Gender != *filter.Gender {
+			continue
+		}
+
+		patients = append(patients, patient)
+	}
+
+	return patients
+}
+
+func patientPosition(p model.Patient) Cursor {
+	return Cursor{Key: p.Lastname, ID: p.ID}
+}
+
+func (m *Memory) CountPatients(ctx context.Context, filter PatientFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.filterPatients(filter))), nil
+}
+
+func (m *Memory) ListPatients(ctx context.Context, filter PatientFilter, sort []SortField, offset, limit int) ([]model.Patient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	patients := m.filterPatients(filter)
+	if err := sortBy(patients, sort, defaultPatientSort, patientFields); err != nil {
+		return nil, err
+	}
+
+	return window(patients, offset, limit), nil
+}
+
+func (m *Memory) ListPatientsAfter(ctx context.Context, filter PatientFilter, after Cursor, limit int) ([]model.Patient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	patients := m.filterPatients(filter)
+	if err := sortBy(patients, nil, defaultPatientSort, patientFields); err != nil {
+		return nil, err
+	}
+
+	return keysetWindow(patients, after, patientPosition, ascending, limit), nil
+}
+
+func (m *Memory) GetPatient(ctx context.Context, id int64) (model.Patient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	patient, ok := find(m.patients, func(p model.Patient) bool { return p.ID == id })
+	if !ok || m.deletedPatients[id] {
+		return model.Patient{}, ErrNotFound
+	}
+
+	return patient, nil
+}
+
+func (m *Memory) CreatePatient(ctx context.Context, patient *model.Patient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	patient.ID = m.nextID("patient")
+	m.patients = append(m.patients, *patient)
+
+	return nil
+}
+
+func (m *Memory) UpdatePatient(ctx context.Context, patient model.Patient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.patients, func(p model.Patient) bool { return p.ID == patient.ID })
+	if i < 0 || m.deletedPatients[patient.ID] {
+		return ErrNotFound
+	}
+
+	m.patients[i] = patient
+	return nil
+}
+
+func (m *Memory) DeletePatient(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := find(m.patients, func(p model.Patient) bool { return p.ID == id }); !ok || m.deletedPatients[id] {
+		return ErrNotFound
+	}
+
+	for _, description := range m.recordDescriptions {
+		if description.patientID == id {
+			return ErrConflict
+		}
+	}
+
+	m.deletedPatients[id] = true
+	return nil
+}
+
+//--------------------------------------
+// Records
+//--------------------------------------
+
+// recordSummaries joins record, record_description and patient like the
+// SQL backends do, keeping the rows for which match returns true.
+// Secondary records get the patient of their primary record.
+func (m *Memory) recordSummaries(match func(model.Record) bool) []model.Record {
+	var records []model.Record
+
+	for _, r := range m.records {
+		primaryID, secondary := m.secondaryRecords[r.id]
+		if !secondary {
+			primaryID = r.id
+		}
+
+		description, ok := m.recordDescriptions[primaryID]
+		if !ok {
+			continue
+		}
+
+		patient, ok := find(m.patients, func(p model.Patient) bool { return p.ID == description.patientID })
+		if !ok {
+			continue
+		}
+
+		record := model.Record{
+			ID:       r.id,
+			Category: r.category,
+			Date:     r.date,
+			PatientObj: model.Patient{
+				ID:       patient.ID,
+				Name:     patient.Name,
+				Lastname: patient.Lastname,
+			},
+		}
+
+		if secondary {
+			record.PrimaryID = primaryID
+		} else {
+			record.Duration = description.duration
+		}
+
+		if match(record) {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+var recordFields = map[string]func(a, b model.Record) int{
+	"id":        func(a, b model.Record) int { return cmp.Compare(a.ID, b.ID) },
+	"rdate":     func(a, b model.Record) int { return cmp.Compare(a.Date, b.Date) },
+	"duration":  func(a, b model.Record) int { return cmp.Compare(a.Duration, b.Duration) },
+	"last_name": func(a, b model.Record) int { return compareFold(a.PatientObj.Lastname, b.PatientObj.Lastname) },
+}
+
+func recordPosition(r model.Record) Cursor {
+	return Cursor{Key: r.Date, ID: r.ID}
+}
+
+// matchRecord returns whether a record summary matches filter. Callers
+// must hold the lock.
+func (m *Memory) matchRecord(filter RecordFilter) func(model.Record) bool {
+	return func(r model.Record) bool {
+		p := r.PatientObj
+
+		switch {
+		case filter.Category != "" && r.Category != filter.Category,
+			filter.From != "" && r.Date < filter.From,
+			filter.To != "" && r.Date > filter.To,
+			filter.PatientName != "" && !contains(p.Name, filter.PatientName) && !contains(p.Lastname, filter.PatientName),
+			filter.FirstName != "" && !contains(p.Name, filter.FirstName),
+			filter.LastName != "" && !contains(p.Lastname, filter.LastName),
+			filter.PatientID != 0 && p.ID != filter.PatientID:
+			return false
+		}
+
+		if filter.DiseaseID != 0 {
+			return slices.Contains(m.idx, memLink{recordID: r.ID, id: filter.DiseaseID})
+		}
+
+		return true
+	}
+}
+
+func (m *Memory) CountRecords(ctx context.Context, filter RecordFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.recordSummaries(m.matchRecord(filter)))), nil
+}
+
+func (m *Memory) ListRecords(ctx context.Context, filter RecordFilter, sort []SortField, offset, limit int) ([]model.Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := m.recordSummaries(m.matchRecord(filter))
+	if err := sortBy(records, sort, defaultRecordSort, recordFields); err != nil {
+		return nil, err
+	}
+
+	return window(records, offset, limit), nil
+}
+
+func (m *Memory) ListRecordsAfter(ctx context.Context, filter RecordFilter, after Cursor, limit int) ([]model.Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := m.recordSummaries(m.matchRecord(filter))
+	if err := sortBy(records, nil, defaultRecordSort, recordFields); err != nil {
+		return nil, err
+	}
+
+	return keysetWindow(records, after, recordPosition, descending, limit), nil
+}
+
+func (m *Memory) GetRecord(ctx context.Context, id int64) (model.FullRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.getRecord(id)
+}
+
+// getRecord reads a primary or secondary record. Callers must hold the
+// lock.
+func (m *Memory) getRecord(id int64) (model.FullRecord, error) {
+	var fullRecord model.FullRecord
+
+	r, ok := find(m.records, func(r memRecord) bool { return r.id == id })
+	if !ok {
+		return fullRecord, ErrNotFound
+	}
+
+	// A secondary record has no description of its own, its patient is
+	// the one of its primary record.
+	primaryID, secondary := m.secondaryRecords[id]
+	if !secondary {
+		primaryID = id
+	}
+
+	description, ok := m.recordDescriptions[primaryID]
+	if !ok {
+		return fullRecord, ErrNotFound
+	}
+
+	patient, ok := find(m.patients, func(p model.Patient) bool { return p.ID == description.patientID })
+	if !ok {
+		return fullRecord, ErrNotFound
+	}
+
+	fullRecord.RecordObj = model.Record{
+		ID:         r.id,
+		Category:   r.category,
+		PatientObj: patient,
+		Date:       r.date,
+		Version:    r.version,
+	}
+
+	if secondary {
+		fullRecord.RecordObj.PrimaryID = primaryID
+	} else {
+		fullRecord.RecordObj.Age = description.age
+		fullRecord.RecordObj.Weight = description.weight
+		fullRecord.RecordObj.Height = description.height
+		fullRecord.RecordObj.Duration = description.duration
+	}
+
+	for _, history := range m.diseaseHistories {
+		if history.RecordID != id {
+			continue
+		}
+
+		if disease, ok := find(m.diseases, func(d model.Disease) bool { return d.ID == history.DiseaseID }); ok {
+			history.DiseaseDesc = disease.Description
+			fullRecord.DiseasesHistory = append(fullRecord.DiseasesHistory, history)
+		}
+	}
+
+	for _, symptom := range m.symptoms {
+		if slices.Contains(m.recordSymptoms, memLink{id, symptom.ID}) {
+			fullRecord.Symptoms = append(fullRecord.Symptoms, symptom)
+		}
+	}
+
+	for _, disease := range m.diseases {
+		if slices.Contains(m.idx, memLink{id, disease.ID}) {
+			fullRecord.Diseases = append(fullRecord.Diseases, disease)
+		}
+	}
+
+	for _, exam := range m.exams {
+		if slices.Contains(m.recordExams, memLink{id, exam.ID}) {
+			fullRecord.Exams = append(fullRecord.Exams, exam)
+		}
+	}
+
+	for _, rvs := range m.recordVitalSigns {
+		if rvs.recordID != id {
+			continue
+		}
+
+		vitalSign, ok := m.vitalSign(rvs.vitalSignID)
+		if !ok {
+			continue
+		}
+
+		fullRecord.VitalSigns = append(fullRecord.VitalSigns, model.RecordVitalSign{
+			RecordID:    id,
+			VitalSignID: vitalSign.ID,
+			Description: vitalSign.Description,
+			UnitID:      vitalSign.UnitObj.ID,
+			Symbol:      vitalSign.UnitObj.Symbol,
+			Value:       rvs.value,
+		})
+	}
+
+	fullRecord.Treatments = m.listTreatments(id)
+
+	return fullRecord, nil
+}
+
+func (m *Memory) listTreatments(recordID int64) []model.Treatment {
+	var treatments []model.Treatment
+
+	for _, t := range m.treatments {
+		if t.recordID != recordID {
+			continue
+		}
+
+		medicine, ok := m.medicine(t.medicineID)
+		if !ok {
+			continue
+		}
+
+		formulation := medicine.FormulationObj
+
+		treatments = append(treatments, model.Treatment{
+			RecordID:      recordID,
+			MedicineID:    medicine.ID,
+			Name:          medicine.Name,
+			Dose:          medicine.Dose,
+			FormulationID: formulation.ID,
+			ShapeID:       formulation.ShapeObj.ID,
+			Description:   formulation.ShapeObj.Description,
+			UnitID:        formulation.UnitObj.ID,
+			Symbol:        formulation.UnitObj.Symbol,
+			Quantity:      t.quantity,
+			Dosage:        t.dosage,
+			Frequency:     t.frequency,
+			Instructions:  t.instructions,
+		})
+	}
+
+	return treatments
+}
+
+func (m *Memory) ListSecondaryRecords(ctx context.Context, primaryID int64) ([]model.FullRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var fullSecRecords []model.FullRecord
+
+	for _, r := range m.records {
+		if r.category != "secondary" || m.secondaryRecords[r.id] != primaryID {
+			continue
+		}
+
+		fullSecRecords = append(fullSecRecords, model.FullRecord{
+			RecordObj: model.Record{
+				ID:        r.id,
+				Category:  r.category,
+				PrimaryID: primaryID,
+				Date:      r.date,
+			},
+			Treatments: m.listTreatments(r.id),
+		})
+	}
+
+	return fullSecRecords, nil
+}
+
+// exists reports whether the row of kind what with id exists. Callers
+// must hold the lock.
+func (m *Memory) exists(what string, id int64) bool {
+	var ok bool
+
+	switch what {
+	case refPatient:
+		_, ok = find(m.patients, func(p model.Patient) bool { return p.ID == id })
+		ok = ok && !m.deletedPatients[id]
+	case refPrimaryRecord:
+		_, ok = m.recordDescriptions[id]
+	case refDisease:
+		_, ok = find(m.diseases, func(d model.Disease) bool { return d.ID == id })
+	case refSymptom:
+		_, ok = find(m.symptoms, func(s model.Symptom) bool { return s.ID == id })
+	case refVitalSign:
+		_, ok = m.vitalSign(id)
+	case refExam:
+		_, ok = find(m.exams, func(e model.Exam) bool { return e.ID == id })
+	case refMedicine:
+		_, ok = m.medicine(id)
+	}
+
+	return ok
+}
+
+func (m *Memory) invalidReferences(fullRecord model.FullRecord) []Reference {
+	var invalid []Reference
+
+	for _, ref := range recordReferences(fullRecord) {
+		if !m.exists(ref.What, ref.ID) {
+			invalid = append(invalid, ref)
+		}
+	}
+
+	return invalid
+}
+
+func (m *Memory) InvalidReferences(ctx context.Context, fullRecord model.FullRecord) ([]Reference, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.invalidReferences(fullRecord), nil
+}
+
+// checkRecord performs the foreign key checks the SQL schema enforces on
+// a new record and its child collections.
+func (m *Memory) checkRecord(fullRecord *model.FullRecord) error {
+	if invalid := m.invalidReferences(*fullRecord); len(invalid) > 0 {
+		return fmt.Errorf("%w: no such %s %d", ErrInvalidReference, invalid[0].What, invalid[0].ID)
+	}
+
+	return nil
+}
+
+func (m *Memory) CreateRecord(ctx context.Context, fullRecord *model.FullRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRecord(fullRecord); err != nil {
+		return err
+	}
+
+	record := &fullRecord.RecordObj
+	id := m.nextID("record")
+
+	m.records = append(m.records, memRecord{id: id, category: record.Category, date: record.Date, version: 1})
+
+	if record.Category == "primary" {
+		m.recordDescriptions[id] = memRecordDescription{
+			patientID: record.PatientObj.ID,
+			age:       record.Age,
+			weight:    record.Weight,
+			height:    record.Height,
+			duration:  record.Duration,
+		}
+	} else if record.Category == "secondary" {
+		m.secondaryRecords[id] = record.PrimaryID
+	}
+
+	m.insertRecordChildren(id, fullRecord)
+
+	record.ID = id
+	return nil
+}
+
+// insertRecordChildren stores the child collections of fullRecord under
+// record id. Callers must hold the write lock.
+func (m *Memory) insertRecordChildren(id int64, fullRecord *model.FullRecord) {
+	for _, history := range fullRecord.DiseasesHistory {
+		m.diseaseHistories = append(m.diseaseHistories, model.DiseaseHistory{
+			RecordID:    id,
+			DiseaseID:   history.DiseaseID,
+			Description: history.Description,
+		})
+	}
+
+	for _, symptom := range fullRecord.Symptoms {
+		m.recordSymptoms = append(m.recordSymptoms, memLink{id, symptom.ID})
+	}
+
+	for _, vitalSign := range fullRecord.VitalSigns {
+		m.recordVitalSigns = append(m.recordVitalSigns, memRecordVitalSign{id, vitalSign.VitalSignID, vitalSign.Value})
+	}
+
+	for _, disease := range fullRecord.Diseases {
+		m.idx = append(m.idx, memLink{id, disease.ID})
+	}
+
+	for _, exam := range fullRecord.Exams {
+		m.recordExams = append(m.recordExams, memLink{id, exam.ID})
+	}
+
+	for _, t := range fullRecord.Treatments {
+		m.treatments = append(m.treatments, memTreatment{
+			recordID:     id,
+			medicineID:   t.MedicineID,
+			quantity:     t.Quantity,
+			dosage:       t.Dosage,
+			frequency:    t.Frequency,
+			instructions: t.Instructions,
+		})
+	}
+}
+
+// deleteRecordChildren removes the child collections of record id.
+// Callers must hold the write lock.
+func (m *Memory) deleteRecordChildren(id int64) {
+	m.diseaseHistories = slices.DeleteFunc(m.diseaseHistories, func(h model.DiseaseHistory) bool { return h.RecordID == id })
+	m.recordSymptoms = slices.DeleteFunc(m.recordSymptoms, func(l memLink) bool { return l.recordID == id })
+	m.recordVitalSigns = slices.DeleteFunc(m.recordVitalSigns, func(v memRecordVitalSign) bool { return v.recordID == id })
+	m.idx = slices.DeleteFunc(m.idx, func(l memLink) bool { return l.recordID == id })
+	m.recordExams = slices.DeleteFunc(m.recordExams, func(l memLink) bool { return l.recordID == id })
+	m.treatments = slices.DeleteFunc(m.treatments, func(t memTreatment) bool { return t.recordID == id })
+}
+
+func (m *Memory) UpdateRecord(ctx context.Context, fullRecord *model.FullRecord, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := &fullRecord.RecordObj
+
+	current, err := m.getRecord(record.ID)
+	if err != nil {
+		return err
+	}
+
+	if record.Version != 0 && record.Version != current.RecordObj.Version {
+		return ErrConflict
+	}
+
+	record.Category = current.RecordObj.Category
+	record.PatientObj = current.RecordObj.PatientObj
+	record.PrimaryID = current.RecordObj.PrimaryID
+
+	if err := m.checkRecord(fullRecord); err != nil {
+		return err
+	}
+
+	m.recordVersions = append(m.recordVersions, memRecordVersion{
+		recordID:   record.ID,
+		version:    current.RecordObj.Version,
+		snapshot:   current,
+		replacedBy: userID,
+		replacedAt: time.Now().UTC(),
+	})
+
+	i := slices.IndexFunc(m.records, func(r memRecord) bool { return r.id == record.ID })
+	m.records[i].date = record.Date
+	m.records[i].version++
+
+	if record.Category == "primary" {
+		description := m.recordDescriptions[record.ID]
+		description.age = record.Age
+		description.weight = record.Weight
+		description.height = record.Height
+		description.duration = record.Duration
+		m.recordDescriptions[record.ID] = description
+	}
+
+	m.deleteRecordChildren(record.ID)
+	m.insertRecordChildren(record.ID, fullRecord)
+
+	record.Version = m.records[i].version
+	return nil
+}
+
+func (m *Memory) ListRecordVersions(ctx context.Context, id int64) ([]model.RecordVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	current, err := m.getRecord(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []model.RecordVersion
+
+	for _, v := range m.recordVersions {
+		if v.recordID != id {
+			continue
+		}
+
+		replacedAt := v.replacedAt
+		versions = append(versions, model.RecordVersion{
+			Version:   v.version,
+			AmendedBy: v.replacedBy,
+			AmendedAt: &replacedAt,
+			Record:    v.snapshot,
+		})
+	}
+
+	versions = append(versions, model.RecordVersion{Version: current.RecordObj.Version, Record: current})
+
+	return shiftAmendments(versions), nil
+}
+
+func (m *Memory) GetRecordVersion(ctx context.Context, id int64, version int) (model.FullRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	current, err := m.getRecord(id)
+	if err != nil || current.RecordObj.Version == version {
+		return current, err
+	}
+
+	v, ok := find(m.recordVersions, func(v memRecordVersion) bool { return v.recordID == id && v.version == version })
+	if !ok {
+		return model.FullRecord{}, ErrNotFound
+	}
+
+	return v.snapshot, nil
+}
+
+//--------------------------------------
+// Catalogs
+//--------------------------------------
+
+// filterCatalog returns the items whose description contains q, sorted
+// by description.
+func filterCatalog[T any](items []T, describe func(T) string, q string) []T {
+	var matched []T
+
+	for _, item := range items {
+		if q == "" || contains(describe(item), q) {
+			matched = append(matched, item)
+		}
+	}
+
+	slices.SortStableFunc(matched, func(a, b T) int {
+		return cmp.Compare(strings.ToLower(describe(a)), strings.ToLower(describe(b)))
+	})
+
+	return matched
+}
+
+func symptomDesc(s model.Symptom) string { return s.Description }
+
+var diseaseFields = map[string]func(a, b model.Disease) int{
+	"id":          func(a, b model.Disease) int { return cmp.Compare(a.ID, b.ID) },
+	"code":        func(a, b model.Disease) int { return cmp.Compare(a.Code, b.Code) },
+	"description": func(a, b model.Disease) int { return compareFold(a.Description, b.Description) },
+}
+
+func (m *Memory) filterDiseases(filter DiseaseFilter) []model.Disease {
+	chapter, chapterOK := icd10.ChapterByNumber(filter.Chapter)
+
+	var diseases []model.Disease
+
+	for _, disease := range m.diseases {
+		category := icd10.Category(disease.Code)
+
+		switch {
+		case filter.Description != "" && !contains(disease.Description, filter.Description):
+		case filter.CodePrefix != "" && !strings.HasPrefix(disease.Code, filter.CodePrefix):
+		case filter.Chapter != 0 && (!chapterOK || disease.Code == "" || category < chapter.First || category > chapter.Last):
+		default:
+			diseases = append(diseases, disease)
+		}
+	}
+
+	return diseases
+}
+
+func (m *Memory) CountDiseases(ctx context.Context, filter DiseaseFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.filterDiseases(filter))), nil
+}
+
+func (m *Memory) ListDiseases(ctx context.Context, filter DiseaseFilter, sort []SortField, offset, limit int) ([]model.Disease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	diseases := m.filterDiseases(filter)
+	if err := sortBy(diseases, sort, defaultDiseaseSort, diseaseFields); err != nil {
+		return nil, err
+	}
+
+	return window(diseases, offset, limit), nil
+}
+
+func (m *Memory) CreateDisease(ctx context.Context, disease *model.Disease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if disease.Code != "" {
+		if _, ok := find(m.diseases, func(d model.Disease) bool { return d.Code == disease.Code }); ok {
+			return ErrConflict
+		}
+	}
+
+	disease.ID = m.nextID("disease")
+	icd10.Describe(disease)
+	m.diseases = append(m.diseases, *disease)
+
+	return nil
+}
+
+func (m *Memory) ImportDiseases(ctx context.Context, diseases []model.Disease) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coded := map[string]int{}
+	for i, disease := range m.diseases {
+		if disease.Code != "" {
+			coded[disease.Code] = i
+		}
+	}
+
+	created, updated := 0, 0
+
+	for _, disease := range diseases {
+		i, ok := coded[disease.Code]
+
+		switch {
+		case !ok:
+			disease.ID = m.nextID("disease")
+			icd10.Describe(&disease)
+			coded[disease.Code] = len(m.diseases)
+			m.diseases = append(m.diseases, disease)
+			created++
+		case m.diseases[i].Description != disease.Description:
+			m.diseases[i].Description = disease.Description
+			updated++
+		}
+	}
+
+	return created, updated, nil
+}
+
+func (m *Memory) CountSymptoms(ctx context.Context, description string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(filterCatalog(m.symptoms, symptomDesc, description))), nil
+}
+
+func (m *Memory) ListSymptoms(ctx context.Context, description string, offset, limit int) ([]model.Symptom, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return window(filterCatalog(m.symptoms, symptomDesc, description), offset, limit), nil
+}
+
+func (m *Memory) CreateSymptom(ctx context.Context, symptom *model.Symptom) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	symptom.ID = m.nextID("symptom")
+	m.symptoms = append(m.symptoms, *symptom)
+
+	return nil
+}
+
+func (m *Memory) CreateCatalogBatch(ctx context.Context, batch *CatalogBatch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check everything first so a failing batch leaves no rows behind.
+	codes := map[string]bool{}
+	for _, disease := range m.diseases {
+		codes[disease.Code] = true
+	}
+
+	for _, disease := range batch.Diseases {
+		if disease.Code == "" {
+			continue
+		}
+		if codes[disease.Code] {
+			return ErrConflict
+		}
+		codes[disease.Code] = true
+	}
+
+	for _, medicine := range batch.Medicines {
+		if _, ok := m.formulation(medicine.FormulationObj.ID); !ok {
+			return fmt.Errorf("%w: no such formulation %d", ErrInvalidReference, medicine.FormulationObj.ID)
+		}
+	}
+
+	for i := range batch.Diseases {
+		disease := &batch.Diseases[i]
+		disease.ID = m.nextID("disease")
+		icd10.Describe(disease)
+		m.diseases = append(m.diseases, *disease)
+	}
+
+	for i := range batch.Symptoms {
+		symptom := &batch.Symptoms[i]
+		symptom.ID = m.nextID("symptom")
+		m.symptoms = append(m.symptoms, *symptom)
+	}
+
+	for i := range batch.Exams {
+		exam := &batch.Exams[i]
+		exam.ID = m.nextID("exam")
+		m.exams = append(m.exams, *exam)
+	}
+
+	for i := range batch.Medicines {
+		medicine := &batch.Medicines[i]
+		medicine.ID = m.nextID("medicine")
+		m.medicines = append(m.medicines, memMedicine{
+			id:            medicine.ID,
+			formulationID: medicine.FormulationObj.ID,
+			name:          medicine.Name,
+			dose:          medicine.Dose,
+		})
+	}
+
+	return nil
+}
+
+func (m *Memory) ListExams(ctx context.Context) ([]model.Exam, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.exams), nil
+}
+
+func (m *Memory) unit(id int64) (model.Unit, bool) {
+	return find(m.units, func(u model.Unit) bool { return u.ID == id })
+}
+
+func (m *Memory) vitalSign(id int64) (model.VitalSign, bool) {
+	row, ok := find(m.vitalSigns, func(vs memVitalSign) bool { return vs.id == id })
+	if !ok {
+		return model.VitalSign{}, false
+	}
+
+	unit, ok := m.unit(row.unitID)
+	if !ok {
+		return model.VitalSign{}, false
+	}
+
+	vitalSign := model.VitalSign{ID: row.id, UnitObj: unit, Description: row.description}
+
+	if vitalSignRange, ok := m.vitalSignRanges[id]; ok {
+		vitalSign.Range = &vitalSignRange
+	}
+
+	return vitalSign, true
+}
+
+func (m *Memory) ListVitalSigns(ctx context.Context) ([]model.VitalSign, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var vitalSigns []model.VitalSign
+
+	for _, row := range m.vitalSigns {
+		if vitalSign, ok := m.vitalSign(row.id); ok {
+			vitalSigns = append(vitalSigns, vitalSign)
+		}
+	}
+
+	slices.SortStableFunc(vitalSigns, func(a, b model.VitalSign) int {
+		return cmp.Compare(a.Description, b.Description)
+	})
+
+	return vitalSigns, nil
+}
+
+func (m *Memory) SetVitalSignRange(ctx context.Context, id int64, vitalSignRange *model.VitalSignRange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.vitalSign(id); !ok {
+		return ErrNotFound
+	}
+
+	if vitalSignRange == nil {
+		delete(m.vitalSignRanges, id)
+		return nil
+	}
+
+	m.vitalSignRanges[id] = *vitalSignRange
+	return nil
+}
+
+//--------------------------------------
+// Medicines
+//--------------------------------------
+
+func (m *Memory) formulation(id int64) (model.Formulation, bool) {
+	row, ok := find(m.formulations, func(f memFormulation) bool { return f.id == id })
+	if !ok {
+		return model.Formulation{}, false
+	}
+
+	shape, ok := find(m.shapes, func(s model.Shape) bool { return s.ID == row.shapeID })
+	if !ok {
+		return model.Formulation{}, false
+	}
+
+	unit, ok := m.unit(row.unitID)
+	if !ok {
+		return model.Formulation{}, false
+	}
+
+	return model.Formulation{ID: row.id, ShapeObj: shape, UnitObj: unit}, true
+}
+
+func (m *Memory) medicine(id int64) (model.Medicine, bool) {
+	row, ok := find(m.medicines, func(med memMedicine) bool { return med.id == id })
+	if !ok {
+		return model.Medicine{}, false
+	}
+
+	formulation, ok := m.formulation(row.formulationID)
+	if !ok {
+		return model.Medicine{}, false
+	}
+
+	return model.Medicine{ID: row.id, FormulationObj: formulation, Name: row.name, Dose: row.dose}, true
+}
+
+func (m *Memory) ListFormulations(ctx context.Context) ([]model.Formulation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var formulations []model.Formulation
+
+	for _, row := range m.formulations {
+		if formulation, ok := m.formulation(row.id); ok {
+			formulations = append(formulations, formulation)
+		}
+	}
+
+	slices.SortStableFunc(formulations, func(a, b model.Formulation) int {
+		return cmp.Compare(a.ShapeObj.Description, b.ShapeObj.Description)
+	})
+
+	return formulations, nil
+}
+
+var medicineFields = map[string]func(a, b model.Medicine) int{
+	"id":   func(a, b model.Medicine) int { return cmp.Compare(a.ID, b.ID) },
+	"name": func(a, b model.Medicine) int { return compareFold(a.Name, b.Name) },
+	"dose": func(a, b model.Medicine) int { return cmp.Compare(a.Dose, b.Dose) },
+}
+
+func (m *Memory) filterMedicines(filter MedicineFilter) []model.Medicine {
+	var medicines []model.Medicine
+
+	for _, row := range m.medicines {
+		if filter.Name != "" && !contains(row.name, filter.Name) {
+			continue
+		}
+
+		medicine, ok := m.medicine(row.id)
+		if !ok {
+			continue
+		}
+
+		if filter.FormulationID != 0 && medicine.FormulationObj.ID != filter.FormulationID {
+			continue
+		}
+
+		if filter.ShapeID != 0 && medicine.FormulationObj.ShapeObj.ID != filter.ShapeID {
+			continue
+		}
+
+		medicines = append(medicines, medicine)
+	}
+
+	return medicines
+}
+
+func medicinePosition(m model.Medicine) Cursor {
+	return Cursor{Key: m.Name, ID: m.ID}
+}
+
+func (m *Memory) CountMedicines(ctx context.Context, filter MedicineFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.filterMedicines(filter))), nil
+}
+
+func (m *Memory) ListMedicines(ctx context.Context, filter MedicineFilter, sort []SortField, offset, limit int) ([]model.Medicine, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	medicines := m.filterMedicines(filter)
+	if err := sortBy(medicines, sort, defaultMedicineSort, medicineFields); err != nil {
+		return nil, err
+	}
+
+	return window(medicines, offset, limit), nil
+}
+
+func (m *Memory) ListMedicinesAfter(ctx context.Context, filter MedicineFilter, after Cursor, limit int) ([]model.Medicine, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	medicines := m.filterMedicines(filter)
+	if err := sortBy(medicines, nil, defaultMedicineSort, medicineFields); err != nil {
+		return nil, err
+	}
+
+	return keysetWindow(medicines, after, medicinePosition, ascending, limit), nil
+}
+
+func (m *Memory) CreateMedicine(ctx context.Context, medicine *model.Medicine) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	formulation, ok := m.formulation(medicine.FormulationObj.ID)
+	if !ok {
+		return fmt.Errorf("%w: no such formulation %d", ErrInvalidReference, medicine.FormulationObj.ID)
+	}
+
+	medicine.ID = m.nextID("medicine")
+	m.medicines = append(m.medicines, memMedicine{
+		id:            medicine.ID,
+		formulationID: formulation.ID,
+		name:          medicine.Name,
+		dose:          medicine.Dose,
+	})
+
+	return nil
+}
+
+//--------------------------------------
+// Users
+//--------------------------------------
+
+func (m *Memory) GetUser(ctx context.Context, id int64) (model.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := find(m.users, func(u model.User) bool { return u.ID == id })
+	if !ok {
+		return user, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (m *Memory) GetUserByUsername(ctx context.Context, username string) (model.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := find(m.users, func(u model.User) bool { return u.Username == username })
+	if !ok {
+		return user, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, user *model.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := find(m.users, func(u model.User) bool { return u.Username == user.Username }); ok {
+		return ErrConflict
+	}
+
+	user.ID = m.nextID("app_user")
+	m.users = append(m.users, *user)
+
+	return nil
+}
+
+//--------------------------------------
+// Audit
+//--------------------------------------
+
+func (m *Memory) filterAudit(filter AuditFilter) []model.AuditEntry {
+	var entries []model.AuditEntry
+
+	// Newest first, as the SQL backends order them.
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		entry := m.audit[i]
+
+		if filter.PatientID != 0 && entry.PatientID != filter.PatientID ||
+			filter.UserID != 0 && entry.UserID != filter.UserID ||
+			!filter.From.IsZero() && entry.Time.Before(filter.From) ||
+			!filter.To.IsZero() && entry.Time.After(filter.To) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func (m *Memory) AppendAudit(ctx context.Context, entry *model.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.ID = m.nextID("audit_log")
+	m.audit = append(m.audit, *entry)
+
+	return nil
+}
+
+func (m *Memory) CountAudit(ctx context.Context, filter AuditFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.filterAudit(filter))), nil
+}
+
+func (m *Memory) ListAudit(ctx context.Context, filter AuditFilter, offset, limit int) ([]model.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return window(m.filterAudit(filter), offset, limit), nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) limit(offset, limit int) (string, []any) {
+	return "LIMIT ?, ?", []any{offset, limit}
+}
+
+func (mysqlDialect) insert(ctx context.Context, db execer, query string, args ...any) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (mysqlDialect) isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+func (mysqlDialect) isForeignKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1452
+}
+
+// fold leaves the column as is: the default collation of utf8mb4 tables
+// already ignores case, and accents too.
+func (mysqlDialect) fold(column string) string {
+	return column
+}
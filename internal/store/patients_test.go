@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func TestDeletePatient(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := seedRecords(t, st)
+
+			newcomer := model.Patient{Name: "Rosa", Lastname: "Quispe"}
+			if err := st.CreatePatient(ctx, &newcomer); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name string
+				id   int64
+				want error
+			}{
+				{"patient with records", f.anaPerez, ErrConflict},
+				{"unknown patient", 999, ErrNotFound},
+				{"patient without records", newcomer.ID, nil},
+				{"patient already deleted", newcomer.ID, ErrNotFound},
+			}
+			for _, tt := range tests {
+				if err := st.DeletePatient(ctx, tt.id); !errors.Is(err, tt.want) {
+					t.Errorf("%s: DeletePatient = %v, want %v", tt.name, err, tt.want)
+				}
+			}
+
+			if _, err := st.GetPatient(ctx, f.anaPerez); err != nil {
+				t.Errorf("patient with records not kept: %v", err)
+			}
+
+			record := model.FullRecord{
+				RecordObj: model.Record{Category: "primary", PatientObj: model.Patient{ID: newcomer.ID}, Date: "2024-06-01"},
+			}
+			if err := st.CreateRecord(ctx, &record); !errors.Is(err, ErrInvalidReference) {
+				t.Errorf("CreateRecord for a deleted patient = %v, want ErrInvalidReference", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/migrate"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// testStores returns an empty memory store and an empty SQLite store with
+// every migration applied, by name.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "mr.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Store{
+		"memory": NewMemory(),
+		"sqlite": NewSQLite(db),
+	}
+}
+
+// recordFixture holds the ids of the rows created by seedRecords.
+type recordFixture struct {
+	anaLopez, anaPerez, luisPerez int64
+	asthma, flu                   int64
+	// The records, in creation order: the primary records of Ana López
+	// (asthma), Ana Pérez (asthma), Luis Pérez (flu), the secondary
+	// record following up the one of Luis Pérez, and a second primary
+	// record of Ana Pérez (flu).
+	records [5]int64
+}
+
+func seedRecords(t *testing.T, st Store) recordFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	var f recordFixture
+
+	for _, p := range []struct {
+		id             *int64
+		name, lastname string
+	}{
+		{&f.anaLopez, "Ana", "López"},
+		{&f.anaPerez, "Ana", "Pérez"},
+		{&f.luisPerez, "Luis", "Pérez"},
+	} {
+		patient := model.Patient{Name: p.name, Lastname: p.lastname}
+		if err := st.CreatePatient(ctx, &patient); err != nil {
+			t.Fatal(err)
+		}
+		*p.id = patient.ID
+	}
+
+	for _, d := range []struct {
+		id                *int64
+		code, description string
+	}{
+		{&f.asthma, "J45", "Asma"},
+		{&f.flu, "J11", "Gripe"},
+	} {
+		disease := model.Disease{Code: d.code, Description: d.description}
+		if err := st.CreateDisease(ctx, &disease); err != nil {
+			t.Fatal(err)
+		}
+		*d.id = disease.ID
+	}
+
+	primary := func(patientID int64, date string, diseaseID int64) model.FullRecord {
+		return model.FullRecord{
+			RecordObj: model.Record{Category: "primary", PatientObj: model.Patient{ID: patientID}, Date: date},
+			Diseases:  []model.Disease{{ID: diseaseID}},
+		}
+	}
+
+	for i, fullRecord := range []model.FullRecord{
+		primary(f.anaLopez, "2024-01-10", f.asthma),
+		primary(f.anaPerez, "2024-02-10", f.asthma),
+		primary(f.luisPerez, "2024-03-10", f.flu),
+		{RecordObj: model.Record{Category: "secondary", Date: "2024-03-20"}},
+		primary(f.anaPerez, "2024-05-01", f.flu),
+	} {
+		if fullRecord.RecordObj.Category == "secondary" {
+			fullRecord.RecordObj.PrimaryID = f.records[2]
+		}
+		if err := st.CreateRecord(ctx, &fullRecord); err != nil {
+			t.Fatal(err)
+		}
+		f.records[i] = fullRecord.RecordObj.ID
+	}
+
+	return f
+}
+
+func TestRecordFilter(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := seedRecords(t, st)
+			r := f.records
+
+			tests := []struct {
+				name   string
+				filter RecordFilter
+				want   []int64
+			}{
+				{
+					name:   "secondary records left out of a last name match",
+					filter: RecordFilter{Category: "primary", LastName: "Pérez"},
+					want:   []int64{r[1], r[2], r[4]},
+				},
+				{
+					name:   "secondary records matched by the patient of their primary record",
+					filter: RecordFilter{Category: "secondary", LastName: "Pérez"},
+					want:   []int64{r[3]},
+				},
+				{
+					name:   "free text on either name",
+					filter: RecordFilter{Category: "primary", PatientName: "ana"},
+					want:   []int64{r[0], r[1], r[4]},
+				},
+				{
+					name:   "first and last name",
+					filter: RecordFilter{Category: "primary", FirstName: "Ana", LastName: "Pérez"},
+					want:   []int64{r[1], r[4]},
+				},
+				{
+					name:   "first name and disease",
+					filter: RecordFilter{Category: "primary", FirstName: "Ana", DiseaseID: f.asthma},
+					want:   []int64{r[0], r[1]},
+				},
+				{
+					name:   "patient and date range",
+					filter: RecordFilter{Category: "primary", PatientID: f.anaPerez, From: "2024-03-01", To: "2024-12-31"},
+					want:   []int64{r[4]},
+				},
+				{
+					name:   "last name, disease and end date",
+					filter: RecordFilter{Category: "primary", LastName: "Pérez", DiseaseID: f.flu, To: "2024-04-01"},
+					want:   []int64{r[2]},
+				},
+				{
+					name: "every filter at once",
+					filter: RecordFilter{
+						Category: "primary", FirstName: "Ana", LastName: "Pérez", PatientID: f.anaPerez,
+						From: "2024-01-01", To: "2024-03-01", DiseaseID: f.asthma,
+					},
+					want: []int64{r[1]},
+				},
+				{
+					name:   "no record matches all",
+					filter: RecordFilter{Category: "primary", FirstName: "Luis", DiseaseID: f.asthma},
+					want:   nil,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					records, err := st.ListRecords(ctx, tt.filter, nil, 0, 100)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got := recordIDs(records); !slices.Equal(got, tt.want) {
+						t.Errorf("ListRecords = %v, want %v", got, tt.want)
+					}
+
+					after, err := st.ListRecordsAfter(ctx, tt.filter, Cursor{}, 100)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got := recordIDs(after); !slices.Equal(got, tt.want) {
+						t.Errorf("ListRecordsAfter = %v, want %v", got, tt.want)
+					}
+
+					total, err := st.CountRecords(ctx, tt.filter)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if total != int64(len(records)) {
+						t.Errorf("CountRecords = %d, want the %d records listed", total, len(records))
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestRecordVersions(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := seedRecords(t, st)
+
+			user := model.User{Username: "doctor", PasswordHash: "hash", Role: "physician"}
+			if err := st.CreateUser(ctx, &user); err != nil {
+				t.Fatal(err)
+			}
+
+			id := f.records[0]
+			amend := func(version int, age int64, diseaseID int64) (model.FullRecord, error) {
+				fullRecord := model.FullRecord{
+					RecordObj: model.Record{ID: id, Date: "2024-01-11", Age: age, Version: version},
+					Diseases:  []model.Disease{{ID: diseaseID}},
+				}
+				err := st.UpdateRecord(ctx, &fullRecord, user.ID)
+				return fullRecord, err
+			}
+
+			second, err := amend(1, 30, f.flu)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The immutable fields are copied back.
+			if second.RecordObj.Version != 2 || second.RecordObj.Category != "primary" || second.RecordObj.PatientObj.ID != f.anaLopez {
+				t.Errorf("first amendment = %+v, want version 2 of the primary record of Ana López", second.RecordObj)
+			}
+
+			if _, err := amend(1, 31, f.asthma); !errors.Is(err, ErrConflict) {
+				t.Errorf("amending a stale version = %v, want ErrConflict", err)
+			}
+
+			// Version 0 skips the check.
+			third, err := amend(0, 31, f.asthma)
+			if err != nil || third.RecordObj.Version != 3 {
+				t.Fatalf("amending without a version = version %d, %v, want version 3", third.RecordObj.Version, err)
+			}
+
+			current, err := st.GetRecord(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.RecordObj.Version != 3 || current.RecordObj.Age != 31 {
+				t.Errorf("current = %+v, want version 3 aged 31", current.RecordObj)
+			}
+
+			versions, err := st.ListRecordVersions(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(versions) != 3 {
+				t.Fatalf("versions = %+v, want 3", versions)
+			}
+			for i, v := range versions {
+				wantAmendedBy := user.ID
+				if i == 0 {
+					wantAmendedBy = 0
+				}
+				if v.Version != i+1 || v.AmendedBy != wantAmendedBy || (v.AmendedAt == nil) != (i == 0) {
+					t.Errorf("version %d = %d amended by %d at %v, want %d amended by %d",
+						i, v.Version, v.AmendedBy, v.AmendedAt, i+1, wantAmendedBy)
+				}
+			}
+
+			for _, tt := range []struct {
+				version   int
+				age       int64
+				diseaseID int64
+			}{
+				{1, 0, f.asthma},
+				{2, 30, f.flu},
+				{3, 31, f.asthma},
+			} {
+				old, err := st.GetRecordVersion(ctx, id, tt.version)
+				if err != nil {
+					t.Fatalf("GetRecordVersion %d: %v", tt.version, err)
+				}
+				if old.RecordObj.Version != tt.version || old.RecordObj.Age != tt.age ||
+					len(old.Diseases) != 1 || old.Diseases[0].ID != tt.diseaseID {
+					t.Errorf("version %d = %+v with diagnosis %+v, want age %d and disease %d",
+						tt.version, old.RecordObj, old.Diseases, tt.age, tt.diseaseID)
+				}
+			}
+
+			if _, err := st.GetRecordVersion(ctx, id, 4); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetRecordVersion of a future version = %v, want ErrNotFound", err)
+			}
+			if _, err := st.ListRecordVersions(ctx, 999); !errors.Is(err, ErrNotFound) {
+				t.Errorf("ListRecordVersions of an unknown record = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestUpdateSecondaryRecord(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := seedRecords(t, st)
+
+			user := model.User{Username: "doctor", PasswordHash: "hash", Role: "physician"}
+			if err := st.CreateUser(ctx, &user); err != nil {
+				t.Fatal(err)
+			}
+
+			// Secondary records have no measurements; those given are
+			// ignored rather than stored.
+			fullRecord := model.FullRecord{
+				RecordObj: model.Record{ID: f.records[3], Date: "2024-03-21", Age: 40, Weight: 70, Height: 170, Duration: 5},
+			}
+			if err := st.UpdateRecord(ctx, &fullRecord, user.ID); err != nil {
+				t.Fatal(err)
+			}
+			if r := fullRecord.RecordObj; r.Category != "secondary" || r.PrimaryID != f.records[2] || r.Version != 2 {
+				t.Errorf("amended = %+v, want version 2 following up record %d", r, f.records[2])
+			}
+
+			current, err := st.GetRecord(ctx, f.records[3])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := current.RecordObj; r.Date != "2024-03-21" || r.Age != 0 || r.Weight != 0 || r.Height != 0 || r.Duration != 0 {
+				t.Errorf("current = %+v, want the new date and no measurements", r)
+			}
+			if current.RecordObj.PatientObj.ID != f.luisPerez {
+				t.Errorf("patient = %d, want that of the primary record, %d", current.RecordObj.PatientObj.ID, f.luisPerez)
+			}
+		})
+	}
+}
+
+// recordIDs returns the ids of records, sorted.
+func recordIDs(records []model.Record) []int64 {
+	var ids []int64
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// Kinds of rows a medical record can refer to.
+const (
+	refPatient       = "patient"
+	refPrimaryRecord = "primary record"
+	refDisease       = "disease"
+	refSymptom       = "symptom"
+	refVitalSign     = "vital sign"
+	refExam          = "exam"
+	refMedicine      = "medicine"
+)
+
+// recordReferences lists every row fullRecord refers to.
+func recordReferences(fullRecord model.FullRecord) []Reference {
+	var refs []Reference
+
+	add := func(what string, id int64, format string, args ...any) {
+		refs = append(refs, Reference{Field: fmt.Sprintf(format, args...), What: what, ID: id})
+	}
+
+	record := fullRecord.RecordObj
+
+	switch record.Category {
+	case "primary":
+		add(refPatient, record.PatientObj.ID, "record.patient.id")
+	case "secondary":
+		add(refPrimaryRecord, record.PrimaryID, "record.primary_record_id")
+	}
+
+	for i, history := range fullRecord.DiseasesHistory {
+		add(refDisease, history.DiseaseID, "diseases_history[%d].disease_id", i)
+	}
+
+	for i, symptom := range fullRecord.Symptoms {
+		add(refSymptom, symptom.ID, "symptoms[%d].id", i)
+	}
+
+	for i, vitalSign := range fullRecord.VitalSigns {
+		add(refVitalSign, vitalSign.VitalSignID, "vital_signs[%d].vital_sign_id", i)
+	}
+
+	for i, disease := range fullRecord.Diseases {
+		add(refDisease, disease.ID, "idx[%d].id", i)
+	}
+
+	for i, exam := range fullRecord.Exams {
+		add(refExam, exam.ID, "exams[%d].id", i)
+	}
+
+	for i, treatment := range fullRecord.Treatments {
+		add(refMedicine, treatment.MedicineID, "treatments[%d].medicine_id", i)
+	}
+
+	return refs
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+func TestInvalidReferences(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := seedRecords(t, st)
+
+			deleted := model.Patient{Name: "Rosa", Lastname: "Quispe"}
+			if err := st.CreatePatient(ctx, &deleted); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.DeletePatient(ctx, deleted.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name       string
+				fullRecord model.FullRecord
+				want       []Reference
+			}{
+				{
+					name: "valid",
+					fullRecord: model.FullRecord{
+						RecordObj:       model.Record{Category: "primary", PatientObj: model.Patient{ID: f.anaLopez}},
+						DiseasesHistory: []model.DiseaseHistory{{DiseaseID: f.flu}},
+						Diseases:        []model.Disease{{ID: f.asthma}},
+					},
+				},
+				{
+					name: "every kind of row missing",
+					fullRecord: model.FullRecord{
+						RecordObj:       model.Record{Category: "primary", PatientObj: model.Patient{ID: deleted.ID}},
+						DiseasesHistory: []model.DiseaseHistory{{DiseaseID: f.flu}, {DiseaseID: 901}},
+						Symptoms:        []model.Symptom{{ID: 902}},
+						VitalSigns:      []model.RecordVitalSign{{VitalSignID: 903}},
+						Diseases:        []model.Disease{{ID: 904}, {ID: f.asthma}},
+						Exams:           []model.Exam{{ID: 905}},
+						Treatments:      []model.Treatment{{MedicineID: 906}},
+					},
+					want: []Reference{
+						{Field: "record.patient.id", What: "patient", ID: deleted.ID},
+						{Field: "diseases_history[1].disease_id", What: "disease", ID: 901},
+						{Field: "symptoms[0].id", What: "symptom", ID: 902},
+						{Field: "vital_signs[0].vital_sign_id", What: "vital sign", ID: 903},
+						{Field: "idx[0].id", What: "disease", ID: 904},
+						{Field: "exams[0].id", What: "exam", ID: 905},
+						{Field: "treatments[0].medicine_id", What: "medicine", ID: 906},
+					},
+				},
+				{
+					name: "unknown primary record",
+					fullRecord: model.FullRecord{
+						RecordObj: model.Record{Category: "secondary", PrimaryID: 999},
+					},
+					want: []Reference{{Field: "record.primary_record_id", What: "primary record", ID: 999}},
+				},
+				{
+					name: "secondary record as primary record",
+					fullRecord: model.FullRecord{
+						RecordObj: model.Record{Category: "secondary", PrimaryID: f.records[3]},
+					},
+					want: []Reference{{Field: "record.primary_record_id", What: "primary record", ID: f.records[3]}},
+				},
+			}
+
+			for _, tt := range tests {
+				got, err := st.InvalidReferences(ctx, tt.fullRecord)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s: InvalidReferences = %+v, want %+v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
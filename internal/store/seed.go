@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// Seed fills m with a small demo data set: the lookup catalogs that have
+// no POST endpoint (units, vital signs, shapes, formulations, exams), a
+// few diseases, symptoms, medicines and patients, and one primary record
+// with a follow-up.
+func (m *Memory) Seed() error {
+	m.mu.Lock()
+
+	for _, unit := range []model.Unit{
+		{Symbol: "°C", Description: "grados Celsius"},
+		{Symbol: "lpm", Description: "latidos por minuto"},
+		{Symbol: "rpm", Description: "respiraciones por minuto"},
+		{Symbol: "mmHg", Description: "milímetros de mercurio"},
+		{Symbol: "%", Description: "porcentaje"},
+		{Symbol: "mg", Description: "miligramos"},
+		{Symbol: "ml", Description: "mililitros"},
+	} {
+		unit.ID = m.nextID("unit")
+		m.units = append(m.units, unit)
+	}
+
+	for _, vitalSign := range []memVitalSign{
+		{unitID: 1, description: "Temperatura"},
+		{unitID: 2, description: "Frecuencia cardiaca"},
+		{unitID: 3, description: "Frecuencia respiratoria"},
+		{unitID: 4, description: "Presión arterial sistólica"},
+		{unitID: 4, description: "Presión arterial diastólica"},
+		{unitID: 5, description: "Saturación de oxígeno"},
+	} {
+		vitalSign.id = m.nextID("vital_sign")
+		m.vitalSigns = append(m.vitalSigns, vitalSign)
+	}
+
+	// Adult reference ranges, indexed like the vital signs above.
+	for i, bounds := range [][4]float64{
+		{25, 45, 36, 37.5},
+		{20, 250, 60, 100},
+		{4, 70, 12, 20},
+		{50, 260, 90, 130},
+		{30, 160, 60, 85},
+		{50, 100, 95, 100},
+	} {
+		bounds := bounds
+		m.vitalSignRanges[m.vitalSigns[i].id] = model.VitalSignRange{
+			Min: &bounds[0], Max: &bounds[1], NormalLow: &bounds[2], NormalHigh: &bounds[3],
+		}
+	}
+
+	for _, shape := range []string{"Tableta", "Cápsula", "Jarabe"} {
+		m.shapes = append(m.shapes, model.Shape{ID: m.nextID("shape"), Description: shape})
+	}
+
+	for _, formulation := range []memFormulation{
+		{shapeID: 1, unitID: 6},
+		{shapeID: 2, unitID: 6},
+		{shapeID: 3, unitID: 7},
+	} {
+		formulation.id = m.nextID("formulation")
+		m.formulations = append(m.formulations, formulation)
+	}
+
+	for _, exam := range []string{"Hemograma completo", "Examen de orina", "Radiografía de tórax", "Glucosa en ayunas"} {
+		m.exams = append(m.exams, model.Exam{ID: m.nextID("exam"), Description: exam})
+	}
+
+	m.mu.Unlock()
+
+	ctx := context.Background()
+
+	for _, disease := range []model.Disease{
+		{Code: "I10", Description: "Hipertensión arterial"},
+		{Code: "E11", Description: "Diabetes mellitus tipo 2"},
+		{Code: "J02.9", Description: "Faringitis aguda"},
+		{Code: "K29.7", Description: "Gastritis"},
+		{Code: "J45.9", Description: "Asma"},
+	} {
+		if err := m.CreateDisease(ctx, &disease); err != nil {
+			return err
+		}
+	}
+
+	for _, description := range []string{"Fiebre", "Tos", "Dolor de garganta", "Cefalea", "Dolor abdominal", "Disnea"} {
+		if err := m.CreateSymptom(ctx, &model.Symptom{Description: description}); err != nil {
+			return err
+		}
+	}
+
+	for _, medicine := range []model.Medicine{
+		{FormulationObj: model.Formulation{ID: 1}, Name: "Paracetamol", Dose: 500},
+		{FormulationObj: model.Formulation{ID: 1}, Name: "Ibuprofeno", Dose: 400},
+		{FormulationObj: model.Formulation{ID: 2}, Name: "Amoxicilina", Dose: 500},
+		{FormulationObj: model.Formulation{ID: 2}, Name: "Omeprazol", Dose: 20},
+		{FormulationObj: model.Formulation{ID: 3}, Name: "Salbutamol", Dose: 2},
+	} {
+		if err := m.CreateMedicine(ctx, &medicine); err != nil {
+			return err
+		}
+	}
+
+	for _, patient := range []model.Patient{
+		{Name: "María", Lastname: "Pérez", Gender: false},
+		{Name: "José", Lastname: "Gutiérrez", Gender: true},
+		{Name: "Ana", Lastname: "Torres", Gender: false},
+		{Name: "Luis", Lastname: "Ramírez", Gender: true},
+	} {
+		if err := m.CreatePatient(ctx, &patient); err != nil {
+			return err
+		}
+	}
+
+	primary := model.FullRecord{
+		RecordObj: model.Record{
+			Category:   "primary",
+			PatientObj: model.Patient{ID: 1},
+			Date:       "2024-03-12",
+			Age:        34,
+			Weight:     62,
+			Height:     160,
+			Duration:   3,
+		},
+		DiseasesHistory: []model.DiseaseHistory{{DiseaseID: 5, Description: "Desde la infancia"}},
+		Symptoms:        []model.Symptom{{ID: 1}, {ID: 2}, {ID: 3}},
+		VitalSigns: []model.RecordVitalSign{
+			{VitalSignID: 1, Value: 38.4},
+			{VitalSignID: 2, Value: 96},
+			{VitalSignID: 6, Value: 97},
+		},
+		Diseases: []model.Disease{{ID: 3}},
+		Exams:    []model.Exam{{ID: 1}},
+		Treatments: []model.Treatment{
+			{MedicineID: 1, Quantity: 10, Dosage: 1, Frequency: 8, Instructions: "Tomar después de las comidas"},
+			{MedicineID: 3, Quantity: 21, Dosage: 1, Frequency: 8, Instructions: "Completar 7 días"},
+		},
+	}
+
+	if err := m.CreateRecord(ctx, &primary); err != nil {
+		return err
+	}
+
+	secondary := model.FullRecord{
+		RecordObj: model.Record{
+			Category:  "secondary",
+			PrimaryID: primary.RecordObj.ID,
+			Date:      "2024-03-19",
+		},
+		Treatments: []model.Treatment{
+			{MedicineID: 2, Quantity: 6, Dosage: 1, Frequency: 12, Instructions: "Solo si hay dolor"},
+		},
+	}
+
+	return m.CreateRecord(ctx, &secondary)
+}
@@ -0,0 +1,1427 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jctorrestone/web-service-mr/internal/icd10"
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+// SQL is a Store backed by a relational database holding the
+// medical_records schema. The dialect takes care of the few statements
+// whose syntax differs between backends.
+type SQL struct {
+	db      tracedDB
+	dialect dialect
+}
+
+var _ Store = (*SQL)(nil)
+
+// NewMySQL returns a Store using an already opened MySQL handle.
+func NewMySQL(db *sql.DB) *SQL {
+	return &SQL{db: tracedDB{DB: db, system: "mysql"}, dialect: mysqlDialect{}}
+}
+
+func (s *SQL) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+// Stats returns the statistics of the connection pool.
+func (s *SQL) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// execer is implemented by *sql.DB and *sql.Tx, and their traced
+// versions.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// dialect hides the syntax differences between the supported databases.
+type dialect interface {
+	// limit returns a clause selecting limit rows starting at offset,
+	// along with its arguments.
+	limit(offset, limit int) (string, []any)
+	// insert runs an INSERT statement on a table with an auto increment
+	// id column and returns the generated id.
+	insert(ctx context.Context, db execer, query string, args ...any) (int64, error)
+	// isDuplicate reports whether err is a unique constraint violation.
+	isDuplicate(err error) bool
+	// isForeignKey reports whether err is a foreign key violation.
+	isForeignKey(err error) bool
+	// fold returns the text column compared ignoring case, as listings
+	// sort and page by it.
+	fold(column string) string
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// queryAll runs query and scans every resulting row with scan.
+func queryAll[T any](ctx context.Context, db execer, scan func(scanner) (T, error), query string, args ...any) ([]T, error) {
+	var items []T
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func count(ctx context.Context, db execer, query string, args ...any) (int64, error) {
+	var total int64
+
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func like(q string) string {
+	return "%" + q + "%"
+}
+
+// reference translates foreign key violations into ErrInvalidReference.
+func (s *SQL) reference(err error) error {
+	if err != nil && s.dialect.isForeignKey(err) {
+		return ErrInvalidReference
+	}
+
+	return err
+}
+
+// timeLayout is the layout timestamps are stored with. It sorts lexically
+// and is accepted by both MySQL DATETIME and SQLite TEXT.
+const timeLayout = "2006-01-02 15:04:05.000000"
+
+func scanPatient(row scanner) (model.Patient, error) {
+	var patient model.Patient
+
+	err := row.Scan(
+		&patient.ID, &patient.Name,
+		&patient.Lastname, &patient.Gender)
+
+	return patient, err
+}
+
+func scanDisease(row scanner) (model.Disease, error) {
+	var disease model.Disease
+	var code sql.NullString
+
+	err := row.Scan(&disease.ID, &code, &disease.Description)
+
+	disease.Code = code.String
+	icd10.Describe(&disease)
+
+	return disease, err
+}
+
+func scanSymptom(row scanner) (model.Symptom, error) {
+	var symptom model.Symptom
+	err := row.Scan(&symptom.ID, &symptom.Description)
+	return symptom, err
+}
+
+func scanExam(row scanner) (model.Exam, error) {
+	var exam model.Exam
+	err := row.Scan(&exam.ID, &exam.Description)
+	return exam, err
+}
+
+func scanMedicine(row scanner) (model.Medicine, error) {
+	var medicine model.Medicine
+
+	err := row.Scan(
+		&medicine.ID, &medicine.FormulationObj.ID,
+		&medicine.FormulationObj.ShapeObj.ID,
+		&medicine.FormulationObj.ShapeObj.Description,
+		&medicine.FormulationObj.UnitObj.ID,
+		&medicine.FormulationObj.UnitObj.Symbol,
+		&medicine.FormulationObj.UnitObj.Description,
+		&medicine.Name, &medicine.Dose)
+
+	return medicine, err
+}
+
+func scanRecordSummary(row scanner) (model.Record, error) {
+	var record model.Record
+
+	err := row.Scan(
+		&record.ID, &record.Category, &record.PrimaryID, &record.PatientObj.ID,
+		&record.PatientObj.Name, &record.PatientObj.Lastname,
+		&record.Date, &record.Duration)
+
+	return record, err
+}
+
+func scanTreatment(row scanner) (model.Treatment, error) {
+	var treatment model.Treatment
+
+	err := row.Scan(
+		&treatment.RecordID, &treatment.MedicineID, &treatment.Name,
+		&treatment.Dose, &treatment.FormulationID, &treatment.ShapeID, &treatment.Description,
+		&treatment.UnitID, &treatment.Symbol, &treatment.Quantity, &treatment.Dosage,
+		&treatment.Frequency, &treatment.Instructions)
+
+	return treatment, err
+}
+
+//--------------------------------------
+// Patients
+//--------------------------------------
+
+// patientColumns maps PatientSortFields to their column.
+var patientColumns = map[string]sortColumn{
+	"id":        {name: "id"},
+	"name":      {name: "name", text: true},
+	"last_name": {name: "last_name", text: true},
+	"gender":    {name: "gender"},
+}
+
+// patientQuery selects the patients that are not soft deleted and match
+// filter.
+func patientQuery(filter PatientFilter) listQuery {
+	var q listQuery
+
+	q.where("deleted_at IS NULL")
+
+	if filter.Name != "" {
+		q.where("name LIKE ? OR last_name LIKE ?", like(filter.Name), like(filter.Name))
+	}
+
+	if filter.Gender != nil {
+		q.where("gender = ?", *filter.Gender)
+	}
+
+	return q
+}
+
+func (s *SQL) CountPatients(ctx context.Context, filter PatientFilter) (int64, error) {
+	q := patientQuery(filter)
+	query, args := q.count("FROM patient")
+	return count(ctx, s.db, query, args...)
+}
+
+func (s *SQL) ListPatients(ctx context.Context, filter PatientFilter, sort []SortField, offset, limit int) ([]model.Patient, error) {
+	q := patientQuery(filter)
+	return s.listPatients(ctx, q, sort, offset, limit)
+}
+
+func (s *SQL) ListPatientsAfter(ctx context.Context, filter PatientFilter, after Cursor, limit int) ([]model.Patient, error) {
+	q := patientQuery(filter)
+
+	if after.ID != 0 {
+		lastName := s.dialect.fold("last_name")
+		q.where(lastName+" > ? OR ("+lastName+" = ? AND id > ?)", after.Key, after.Key, after.ID)
+	}
+
+	return s.listPatients(ctx, q, nil, 0, limit)
+}
+
+func (s *SQL) listPatients(ctx context.Context, q listQuery, sort []SortField, offset, limit int) ([]model.Patient, error) {
+	if err := q.orderBy(s.dialect, sort, defaultPatientSort, patientColumns); err != nil {
+		return nil, err
+	}
+
+	query, args := q.list(s.dialect, "SELECT id, name, last_name, gender FROM patient", offset, limit)
+	return queryAll(ctx, s.db, scanPatient, query, args...)
+}
+
+func (s *SQL) GetPatient(ctx context.Context, id int64) (model.Patient, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, name, last_name, gender FROM patient WHERE id = ? AND deleted_at IS NULL", id)
+
+	patient, err := scanPatient(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return patient, ErrNotFound
+	}
+
+	return patient, err
+}
+
+func (s *SQL) CreatePatient(ctx context.Context, patient *model.Patient) error {
+	id, err := s.dialect.insert(ctx, s.db,
+		"INSERT INTO patient (name, last_name, gender) VALUES (?, ?, ?)",
+		patient.Name, patient.Lastname, patient.Gender)
+
+	if err != nil {
+		return err
+	}
+
+	patient.ID = id
+	return nil
+}
+
+func (s *SQL) UpdatePatient(ctx context.Context, patient model.Patient) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE patient SET name = ?, last_name = ?, gender = ? WHERE id = ? AND deleted_at IS NULL",
+		patient.Name, patient.Lastname, patient.Gender, patient.ID)
+
+	if err != nil {
+		return err
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so check
+	// existence separately.
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		_, err := s.GetPatient(ctx, patient.ID)
+		return err
+	}
+
+	return nil
+}
+
+// DeletePatient checks for records and deletes in a single statement. A
+// record created concurrently either commits first and the patient is
+// kept, or finds the patient deleted, see CreateRecord.
+func (s *SQL) DeletePatient(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE patient SET deleted_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM record_description WHERE patient_id = ?)`,
+		time.Now().UTC().Format(timeLayout), id, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	// Tell a missing patient from one with records.
+	if _, err := s.GetPatient(ctx, id); err != nil {
+		return err
+	}
+
+	return ErrConflict
+}
+
+//--------------------------------------
+// Records
+//--------------------------------------
+
+// recordSummaryFrom joins records with their patient, which secondary
+// records share with their primary record. Only primary records have a
+// duration.
+const recordSummaryFrom = `FROM record AS r
+		LEFT JOIN secondary_record AS sr
+		ON r.id = sr.record_id
+		LEFT JOIN record_description AS own
+		ON r.id = own.record_id
+		INNER JOIN record_description AS rd
+		ON rd.record_id = COALESCE(sr.primary_record_id, r.id)
+		INNER JOIN patient AS p
+		ON rd.patient_id = p.id`
+
+const recordSummarySelect = `SELECT r.id, r.category, COALESCE(sr.primary_record_id, 0),
+		p.id, p.name, p.last_name, r.rdate, COALESCE(own.duration, 0)
+		` + recordSummaryFrom
+
+// recordColumns maps RecordSortFields to their column.
+var recordColumns = map[string]sortColumn{
+	"id":        {name: "r.id"},
+	"rdate":     {name: "r.rdate"},
+	"duration":  {name: "COALESCE(own.duration, 0)"},
+	"last_name": {name: "p.last_name", text: true},
+}
+
+func recordQuery(filter RecordFilter) listQuery {
+	var q listQuery
+
+	if filter.Category != "" {
+		q.where("r.category = ?", filter.Category)
+	}
+
+	if filter.From != "" {
+		q.where("r.rdate >= ?", filter.From)
+	}
+
+	if filter.To != "" {
+		q.where("r.rdate <= ?", filter.To)
+	}
+
+	if filter.PatientName != "" {
+		q.where("p.name LIKE ? OR p.last_name LIKE ?", like(filter.PatientName), like(filter.PatientName))
+	}
+
+	if filter.FirstName != "" {
+		q.where("p.name LIKE ?", like(filter.FirstName))
+	}
+
+	if filter.LastName != "" {
+		q.where("p.last_name LIKE ?", like(filter.LastName))
+	}
+
+	if filter.PatientID != 0 {
+		q.where("p.id = ?", filter.PatientID)
+	}
+
+	if filter.DiseaseID != 0 {
+		q.where("EXISTS (SELECT 1 FROM idx WHERE idx.record_id = r.id AND idx.disease_id = ?)", filter.DiseaseID)
+	}
+
+	return q
+}
+
+func (s *SQL) CountRecords(ctx context.Context, filter RecordFilter) (int64, error) {
+	q := recordQuery(filter)
+	query, args := q.count(recordSummaryFrom)
+	return count(ctx, s.db, query, args...)
+}
+
+func (s *SQL) ListRecords(ctx context.Context, filter RecordFilter, sort []SortField, offset, limit int) ([]model.Record, error) {
+	q := recordQuery(filter)
+	return s.listRecords(ctx, q, sort, offset, limit)
+}
+
+func (s *SQL) ListRecordsAfter(ctx context.Context, filter RecordFilter, after Cursor, limit int) ([]model.Record, error) {
+	q := recordQuery(filter)
+
+	if after.ID != 0 {
+		q.where("r.rdate < ? OR (r.rdate = ? AND r.id < ?)", after.Key, after.Key, after.ID)
+	}
+
+	return s.listRecords(ctx, q, nil, 0, limit)
+}
+
+func (s *SQL) listRecords(ctx context.Context, q listQuery, sort []SortField, offset, limit int) ([]model.Record, error) {
+	if err := q.orderBy(s.dialect, sort, defaultRecordSort, recordColumns); err != nil {
+		return nil, err
+	}
+
+	query, args := q.list(s.dialect, recordSummarySelect, offset, limit)
+	return queryAll(ctx, s.db, scanRecordSummary, query, args...)
+}
+
+func (s *SQL) GetRecord(ctx context.Context, id int64) (model.FullRecord, error) {
+	return s.getRecord(ctx, s.db, id)
+}
+
+// getRecord reads a primary or secondary record through db, which may be
+// a transaction.
+func (s *SQL) getRecord(ctx context.Context, db execer, id int64) (model.FullRecord, error) {
+	var fullRecord model.FullRecord
+	record := &fullRecord.RecordObj
+
+	row := db.QueryRowContext(ctx,
+		`SELECT r.id, r.category, COALESCE(sr.primary_record_id, 0), p.id, p.name, p.last_name, p.gender, r.rdate,
+			COALESCE(rd.age, 0), COALESCE(rd.weight, 0), COALESCE(rd.height, 0), COALESCE(rd.duration, 0), r.version
+		FROM record AS r
+		LEFT JOIN record_description AS rd
+		ON r.id = rd.record_id
+		LEFT JOIN secondary_record AS sr
+		ON r.id = sr.record_id
+		INNER JOIN record_description AS prd
+		ON prd.record_id = COALESCE(sr.primary_record_id, r.id)
+		INNER JOIN patient AS p
+		ON prd.patient_id = p.id
+		WHERE r.id = ?`, id)
+
+	if err := row.Scan(
+		&record.ID, &record.Category, &record.PrimaryID, &record.PatientObj.ID, &record.PatientObj.Name,
+		&record.PatientObj.Lastname, &record.PatientObj.Gender, &record.Date,
+		&record.Age, &record.Weight, &record.Height, &record.Duration, &record.Version); err != nil {
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return fullRecord, ErrNotFound
+		}
+
+		return fullRecord, err
+	}
+
+	var err error
+
+	fullRecord.DiseasesHistory, err = queryAll(ctx, db,
+		func(row scanner) (model.DiseaseHistory, error) {
+			var history model.DiseaseHistory
+			err := row.Scan(
+				&history.RecordID, &history.DiseaseID, &history.DiseaseDesc, &history.Description)
+			return history, err
+		},
+		`SELECT dh.record_id, d.id, d.description, dh.description
+		FROM disease_history AS dh
+		INNER JOIN disease AS d
+		ON dh.disease_id=d.id
+		WHERE dh.record_id=?`, id)
+
+	if err != nil {
+		return fullRecord, err
+	}
+
+	fullRecord.Symptoms, err = queryAll(ctx, db, scanSymptom,
+		`SELECT id, description FROM symptom
+		WHERE id IN (
+			SELECT symptom_id
+			FROM record_symptom
+			WHERE record_id=?
+		)`, id)
+
+	if err != nil {
+		return fullRecord, err
+	}
+
+	fullRecord.Diseases, err = queryAll(ctx, db, scanDisease,
+		`SELECT id, code, description FROM disease
+		WHERE id IN (
+			SELECT disease_id FROM idx
+			WHERE record_id=?
+		)`, id)
+
+	if err != nil {
+		return fullRecord, err
+	}
+
+	fullRecord.Exams, err = queryAll(ctx, db, scanExam,
+		`SELECT id, description FROM exam
+		WHERE id IN (
+			SELECT exam_id FROM record_exam
+			WHERE record_id=?
+		)`, id)
+
+	if err != nil {
+		return fullRecord, err
+	}
+
+	fullRecord.VitalSigns, err = queryAll(ctx, db,
+		func(row scanner) (model.RecordVitalSign, error) {
+			var vitalSign model.RecordVitalSign
+			err := row.Scan(
+				&vitalSign.RecordID, &vitalSign.VitalSignID,
+				&vitalSign.Description, &vitalSign.UnitID, &vitalSign.Symbol, &vitalSign.Value)
+			return vitalSign, err
+		},
+		`SELECT rvs.record_id, vs.id, vs.description, u.id, u.symbol, rvs.value
+		FROM record_vital_sign AS rvs
+		INNER JOIN vital_sign AS vs
+		ON rvs.vital_sign_id=vs.id
+		INNER JOIN unit AS u
+		ON vs.unit_id=u.id
+		WHERE rvs.record_id=?`, id)
+
+	if err != nil {
+		return fullRecord, err
+	}
+
+	fullRecord.Treatments, err = s.listTreatments(ctx, db, id)
+
+	return fullRecord, err
+}
+
+func (s *SQL) listTreatments(ctx context.Context, db execer, recordID int64) ([]model.Treatment, error) {
+	return queryAll(ctx, db, scanTreatment,
+		`SELECT t.record_id, m.id, m.name, m.dose, f.id, s.id, s.description, u.id, u.symbol, t.quantity, t.dosage, t.frequency, t.instructions
+		FROM treatment AS t
+		INNER JOIN medicine AS m
+		ON t.medicine_id=m.id
+		INNER JOIN formulation AS f
+		ON m.formulation_id=f.id
+		INNER JOIN shape AS s
+		ON f.shape_id=s.id
+		INNER JOIN unit AS u
+		ON f.unit_id=u.id
+		WHERE t.record_id=?`, recordID)
+}
+
+func (s *SQL) ListSecondaryRecords(ctx context.Context, primaryID int64) ([]model.FullRecord, error) {
+	fullSecRecords, err := queryAll(ctx, s.db,
+		func(row scanner) (model.FullRecord, error) {
+			var fullSecRecord model.FullRecord
+			err := row.Scan(
+				&fullSecRecord.RecordObj.ID, &fullSecRecord.RecordObj.Category,
+				&fullSecRecord.RecordObj.PrimaryID, &fullSecRecord.RecordObj.Date)
+			return fullSecRecord, err
+		},
+		`SELECT r.id, r.category, sr.primary_record_id, r.rdate
+		FROM record AS r
+		INNER JOIN secondary_record AS sr
+		ON r.id = sr.record_id
+		WHERE r.category='secondary' AND sr.primary_record_id=?`, primaryID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range fullSecRecords {
+		fullSecRecords[i].Treatments, err = s.listTreatments(ctx, s.db, fullSecRecords[i].RecordObj.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fullSecRecords, nil
+}
+
+// referenceQueries select a row of each kind a record can refer to.
+var referenceQueries = map[string]string{
+	refPatient:       "SELECT id FROM patient WHERE id = ? AND deleted_at IS NULL",
+	refPrimaryRecord: "SELECT record_id FROM record_description WHERE record_id = ?",
+	refDisease:       "SELECT id FROM disease WHERE id = ?",
+	refSymptom:       "SELECT id FROM symptom WHERE id = ?",
+	refVitalSign:     "SELECT id FROM vital_sign WHERE id = ?",
+	refExam:          "SELECT id FROM exam WHERE id = ?",
+	refMedicine:      "SELECT id FROM medicine WHERE id = ?",
+}
+
+func (s *SQL) InvalidReferences(ctx context.Context, fullRecord model.FullRecord) ([]Reference, error) {
+	var invalid []Reference
+
+	for _, ref := range recordReferences(fullRecord) {
+		var id int64
+
+		err := s.db.QueryRowContext(ctx, referenceQueries[ref.What], ref.ID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			invalid = append(invalid, ref)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return invalid, nil
+}
+
+func (s *SQL) CreateRecord(ctx context.Context, fullRecord *model.FullRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	record := &fullRecord.RecordObj
+
+	id, err := s.dialect.insert(ctx, tx,
+		"INSERT INTO record (category, rdate) VALUES (?, ?)",
+		record.Category, record.Date)
+
+	if err != nil {
+		return err
+	}
+
+	if record.Category == "primary" {
+		// The patient may have been deleted since the references were
+		// checked; the foreign key does not see soft deletes.
+		var result sql.Result
+		result, err = tx.ExecContext(ctx,
+			`INSERT INTO record_description (record_id, patient_id, age, weight, height, duration)
+			SELECT ?, id, ?, ?, ?, ? FROM patient WHERE id = ? AND deleted_at IS NULL`,
+			id, record.Age, record.Weight, record.Height, record.Duration, record.PatientObj.ID)
+
+		var n int64
+		if err == nil {
+			n, err = result.RowsAffected()
+		}
+		if err == nil && n == 0 {
+			return ErrInvalidReference
+		}
+	} else if record.Category == "secondary" {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO secondary_record (record_id, primary_record_id) VALUES (?, ?)",
+			id, record.PrimaryID)
+	}
+
+	if err != nil {
+		return s.reference(err)
+	}
+
+	if err := insertRecordChildren(ctx, tx, id, fullRecord); err != nil {
+		return s.reference(err)
+	}
+
+	// Commit the transaction.
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	record.ID = id
+	return nil
+}
+
+// recordChildren lists the tables holding the child collections of a
+// record.
+var recordChildren = []string{
+	"disease_history", "record_symptom", "record_vital_sign", "idx", "record_exam", "treatment",
+}
+
+// insertRecordChildren stores the child collections of fullRecord under
+// record id.
+func insertRecordChildren(ctx context.Context, tx execer, id int64, fullRecord *model.FullRecord) error {
+	var err error
+
+	for _, diseaseHistory := range fullRecord.DiseasesHistory {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO disease_history (record_id, disease_id, description) VALUES (?, ?, ?)",
+			id, diseaseHistory.DiseaseID, diseaseHistory.Description); err != nil {
+			return err
+		}
+	}
+
+	for _, symptom := range fullRecord.Symptoms {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO record_symptom (record_id, symptom_id) VALUES (?, ?)",
+			id, symptom.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, vitalSign := range fullRecord.VitalSigns {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO record_vital_sign (record_id, vital_sign_id, value) VALUES (?, ?, ?)",
+			id, vitalSign.VitalSignID, vitalSign.Value); err != nil {
+			return err
+		}
+	}
+
+	for _, disease := range fullRecord.Diseases {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO idx (record_id, disease_id) VALUES (?, ?)",
+			id, disease.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, exam := range fullRecord.Exams {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO record_exam (record_id, exam_id) VALUES (?, ?)",
+			id, exam.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, treatment := range fullRecord.Treatments {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO treatment (record_id, medicine_id, quantity, dosage, frequency, instructions) VALUES (?, ?, ?, ?, ?, ?)",
+			id, treatment.MedicineID, treatment.Quantity, treatment.Dosage, treatment.Frequency, treatment.Instructions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQL) UpdateRecord(ctx context.Context, fullRecord *model.FullRecord, userID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	record := &fullRecord.RecordObj
+
+	current, err := s.getRecord(ctx, tx, record.ID)
+	if err != nil {
+		return err
+	}
+
+	if record.Version != 0 && record.Version != current.RecordObj.Version {
+		return ErrConflict
+	}
+
+	snapshot, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	// Two concurrent amendments both try to store the same version, so
+	// the primary key lets only one of them through.
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO record_version (record_id, version, snapshot, replaced_by, replaced_at) VALUES (?, ?, ?, ?, ?)",
+		record.ID, current.RecordObj.Version, string(snapshot), nullInt(userID), time.Now().UTC().Format(timeLayout)); err != nil {
+		if s.dialect.isDuplicate(err) {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE record SET rdate = ?, version = ? WHERE id = ?",
+		record.Date, current.RecordObj.Version+1, record.ID); err != nil {
+		return err
+	}
+
+	// Only primary records have a description; secondary ones share the
+	// one of their primary record.
+	if current.RecordObj.Category == "primary" {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE record_description SET age = ?, weight = ?, height = ?, duration = ? WHERE record_id = ?",
+			record.Age, record.Weight, record.Height, record.Duration, record.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range recordChildren {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE record_id = ?", record.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := insertRecordChildren(ctx, tx, record.ID, fullRecord); err != nil {
+		return s.reference(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	record.Category = current.RecordObj.Category
+	record.PatientObj = current.RecordObj.PatientObj
+	record.PrimaryID = current.RecordObj.PrimaryID
+	record.Version = current.RecordObj.Version + 1
+	return nil
+}
+
+func (s *SQL) ListRecordVersions(ctx context.Context, id int64) ([]model.RecordVersion, error) {
+	current, err := s.GetRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := queryAll(ctx, s.db,
+		func(row scanner) (model.RecordVersion, error) {
+			var version model.RecordVersion
+			var snapshot, replacedAt string
+			var replacedBy sql.NullInt64
+
+			if err := row.Scan(&version.Version, &snapshot, &replacedBy, &replacedAt); err != nil {
+				return version, err
+			}
+
+			if err := json.Unmarshal([]byte(snapshot), &version.Record); err != nil {
+				return version, err
+			}
+
+			// The row describes who replaced this version; move it to the
+			// version that replaced it below.
+			amendedAt, err := time.Parse(timeLayout, replacedAt)
+			version.AmendedBy = replacedBy.Int64
+			version.AmendedAt = &amendedAt
+			return version, err
+		},
+		`SELECT version, snapshot, replaced_by, replaced_at
+		FROM record_version
+		WHERE record_id = ?
+		ORDER BY version`, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions = append(versions, model.RecordVersion{Version: current.RecordObj.Version, Record: current})
+
+	return shiftAmendments(versions), nil
+}
+
+// shiftAmendments turns rows describing who replaced each version into
+// versions describing who created them.
+func shiftAmendments(versions []model.RecordVersion) []model.RecordVersion {
+	for i := len(versions) - 1; i > 0; i-- {
+		versions[i].AmendedBy = versions[i-1].AmendedBy
+		versions[i].AmendedAt = versions[i-1].AmendedAt
+	}
+
+	if len(versions) > 0 {
+		versions[0].AmendedBy = 0
+		versions[0].AmendedAt = nil
+	}
+
+	return versions
+}
+
+func (s *SQL) GetRecordVersion(ctx context.Context, id int64, version int) (model.FullRecord, error) {
+	current, err := s.GetRecord(ctx, id)
+	if err != nil || current.RecordObj.Version == version {
+		return current, err
+	}
+
+	var fullRecord model.FullRecord
+	var snapshot string
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT snapshot FROM record_version WHERE record_id = ? AND version = ?", id, version)
+
+	if err := row.Scan(&snapshot); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fullRecord, ErrNotFound
+		}
+
+		return fullRecord, err
+	}
+
+	err = json.Unmarshal([]byte(snapshot), &fullRecord)
+	return fullRecord, err
+}
+
+//--------------------------------------
+// Catalogs
+//--------------------------------------
+
+// diseaseColumns maps DiseaseSortFields to their column.
+var diseaseColumns = map[string]sortColumn{
+	"id":          {name: "id"},
+	"code":        {name: "code"},
+	"description": {name: "description", text: true},
+}
+
+func diseaseQuery(filter DiseaseFilter) listQuery {
+	var q listQuery
+
+	if filter.Description != "" {
+		q.where("description LIKE ?", like(filter.Description))
+	}
+
+	if filter.CodePrefix != "" {
+		q.where("code LIKE ?", filter.CodePrefix+"%")
+	}
+
+	if filter.Chapter != 0 {
+		chapter, ok := icd10.ChapterByNumber(filter.Chapter)
+		if !ok {
+			q.where("1 = 0")
+		} else {
+			q.where("SUBSTR(code, 1, 3) BETWEEN ? AND ?", chapter.First, chapter.Last)
+		}
+	}
+
+	return q
+}
+
+func (s *SQL) CountDiseases(ctx context.Context, filter DiseaseFilter) (int64, error) {
+	q := diseaseQuery(filter)
+	query, args := q.count("FROM disease")
+	return count(ctx, s.db, query, args...)
+}
+
+func (s *SQL) ListDiseases(ctx context.Context, filter DiseaseFilter, sort []SortField, offset, limit int) ([]model.Disease, error) {
+	q := diseaseQuery(filter)
+	if err := q.orderBy(s.dialect, sort, defaultDiseaseSort, diseaseColumns); err != nil {
+		return nil, err
+	}
+
+	query, args := q.list(s.dialect, "SELECT id, code, description FROM disease", offset, limit)
+	return queryAll(ctx, s.db, scanDisease, query, args...)
+}
+
+func (s *SQL) CreateDisease(ctx context.Context, disease *model.Disease) error {
+	id, err := s.dialect.insert(ctx, s.db,
+		"INSERT INTO disease (code, description) VALUES (?, ?)",
+		nullString(disease.Code), disease.Description)
+
+	if s.dialect.isDuplicate(err) {
+		return ErrConflict
+	}
+
+	if err != nil {
+		return err
+	}
+
+	disease.ID = id
+	icd10.Describe(disease)
+
+	return nil
+}
+
+func (s *SQL) ImportDiseases(ctx context.Context, diseases []model.Disease) (int, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	coded, err := queryAll(ctx, tx, scanDisease,
+		"SELECT id, code, description FROM disease WHERE code IS NOT NULL")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	existing := make(map[string]model.Disease, len(coded))
+	for _, disease := range coded {
+		existing[disease.Code] = disease
+	}
+
+	created, updated := 0, 0
+
+	for _, disease := range diseases {
+		current, ok := existing[disease.Code]
+
+		switch {
+		case !ok:
+			current.ID, err = s.dialect.insert(ctx, tx,
+				"INSERT INTO disease (code, description) VALUES (?, ?)",
+				disease.Code, disease.Description)
+			if err != nil {
+				return 0, 0, err
+			}
+			created++
+		case current.Description != disease.Description:
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE disease SET description = ? WHERE id = ?",
+				disease.Description, current.ID); err != nil {
+				return 0, 0, err
+			}
+			updated++
+		default:
+			continue
+		}
+
+		current.Description = disease.Description
+		existing[disease.Code] = current
+	}
+
+	return created, updated, tx.Commit()
+}
+
+func (s *SQL) CountSymptoms(ctx context.Context, description string) (int64, error) {
+	if description == "" {
+		return count(ctx, s.db, "SELECT COUNT(id) AS total FROM symptom")
+	}
+
+	return count(ctx, s.db,
+		"SELECT COUNT(id) AS total FROM symptom WHERE description LIKE ?", like(description))
+}
+
+func (s *SQL) ListSymptoms(ctx context.Context, description string, offset, limit int) ([]model.Symptom, error) {
+	where := ""
+	args := []any{}
+
+	if description != "" {
+		where = "WHERE description LIKE ? "
+		args = append(args, like(description))
+	}
+
+	limitClause, limitArgs := s.dialect.limit(offset, limit)
+
+	return queryAll(ctx, s.db, scanSymptom,
+		"SELECT id, description FROM symptom "+where+"ORDER BY description ASC "+limitClause,
+		append(args, limitArgs...)...)
+}
+
+func (s *SQL) CreateSymptom(ctx context.Context, symptom *model.Symptom) error {
+	id, err := s.dialect.insert(ctx, s.db,
+		"INSERT INTO symptom (description) VALUES (?)",
+		symptom.Description)
+
+	if err != nil {
+		return err
+	}
+
+	symptom.ID = id
+	return nil
+}
+
+func (s *SQL) CreateCatalogBatch(ctx context.Context, batch *CatalogBatch) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range batch.Diseases {
+		disease := &batch.Diseases[i]
+
+		disease.ID, err = s.dialect.insert(ctx, tx,
+			"INSERT INTO disease (code, description) VALUES (?, ?)",
+			nullString(disease.Code), disease.Description)
+		if s.dialect.isDuplicate(err) {
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		icd10.Describe(disease)
+	}
+
+	for i := range batch.Symptoms {
+		symptom := &batch.Symptoms[i]
+
+		symptom.ID, err = s.dialect.insert(ctx, tx,
+			"INSERT INTO symptom (description) VALUES (?)",
+			symptom.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range batch.Exams {
+		exam := &batch.Exams[i]
+
+		exam.ID, err = s.dialect.insert(ctx, tx,
+			"INSERT INTO exam (description) VALUES (?)",
+			exam.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range batch.Medicines {
+		medicine := &batch.Medicines[i]
+
+		medicine.ID, err = s.dialect.insert(ctx, tx,
+			"INSERT INTO medicine (formulation_id, name, dose) VALUES (?, ?, ?)",
+			medicine.FormulationObj.ID, medicine.Name, medicine.Dose)
+		if err != nil {
+			return s.reference(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL) ListExams(ctx context.Context) ([]model.Exam, error) {
+	return queryAll(ctx, s.db, scanExam, "SELECT id, description FROM exam")
+}
+
+func (s *SQL) ListVitalSigns(ctx context.Context) ([]model.VitalSign, error) {
+	return queryAll(ctx, s.db,
+		func(row scanner) (model.VitalSign, error) {
+			var vitalSign model.VitalSign
+			var ranged sql.NullInt64
+			var bounds [4]sql.NullFloat64
+
+			err := row.Scan(
+				&vitalSign.ID, &vitalSign.UnitObj.ID,
+				&vitalSign.UnitObj.Symbol, &vitalSign.UnitObj.Description,
+				&vitalSign.Description, &ranged,
+				&bounds[0], &bounds[1], &bounds[2], &bounds[3])
+
+			if ranged.Valid {
+				vitalSign.Range = &model.VitalSignRange{
+					Min:        nullFloat(bounds[0]),
+					Max:        nullFloat(bounds[1]),
+					NormalLow:  nullFloat(bounds[2]),
+					NormalHigh: nullFloat(bounds[3]),
+				}
+			}
+
+			return vitalSign, err
+		},
+		`SELECT vs.id, u.id, u.symbol, u.description, vs.description,
+			r.vital_sign_id, r.min_value, r.max_value, r.normal_low, r.normal_high
+		FROM vital_sign AS vs
+		INNER JOIN unit AS u
+		ON unit_id = u.id
+		LEFT JOIN vital_sign_range AS r
+		ON r.vital_sign_id = vs.id
+		ORDER BY vs.description ASC`)
+}
+
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+
+	return &f.Float64
+}
+
+func (s *SQL) SetVitalSignRange(ctx context.Context, id int64, vitalSignRange *model.VitalSignRange) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM vital_sign WHERE id = ?", id).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM vital_sign_range WHERE vital_sign_id = ?", id); err != nil {
+		return err
+	}
+
+	if vitalSignRange != nil {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO vital_sign_range (vital_sign_id, min_value, max_value, normal_low, normal_high) VALUES (?, ?, ?, ?, ?)",
+			id, vitalSignRange.Min, vitalSignRange.Max, vitalSignRange.NormalLow, vitalSignRange.NormalHigh); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//--------------------------------------
+// Medicines
+//--------------------------------------
+
+func (s *SQL) ListFormulations(ctx context.Context) ([]model.Formulation, error) {
+	return queryAll(ctx, s.db,
+		func(row scanner) (model.Formulation, error) {
+			var formulation model.Formulation
+			err := row.Scan(
+				&formulation.ID, &formulation.ShapeObj.ID,
+				&formulation.ShapeObj.Description,
+				&formulation.UnitObj.ID, &formulation.UnitObj.Symbol,
+				&formulation.UnitObj.Description)
+			return formulation, err
+		},
+		`SELECT f.id, s.id, s.description, u.id, u.symbol, u.description
+		FROM formulation AS f
+		INNER JOIN shape AS s
+		ON shape_id = s.id
+		INNER JOIN unit AS u
+		ON unit_id = u.id
+		ORDER BY s.description ASC`)
+}
+
+// medicineFrom joins medicines with their formulation.
+const medicineFrom = `FROM medicine AS m
+		INNER JOIN formulation AS f
+		ON m.formulation_id = f.id
+		INNER JOIN shape AS s
+		ON f.shape_id = s.id
+		INNER JOIN unit AS u
+		ON f.unit_id = u.id`
+
+// medicineColumns maps MedicineSortFields to their column.
+var medicineColumns = map[string]sortColumn{
+	"id":   {name: "m.id"},
+	"name": {name: "m.name", text: true},
+	"dose": {name: "m.dose"},
+}
+
+func medicineQuery(filter MedicineFilter) listQuery {
+	var q listQuery
+
+	if filter.Name != "" {
+		q.where("m.name LIKE ?", like(filter.Name))
+	}
+
+	if filter.FormulationID != 0 {
+		q.where("m.formulation_id = ?", filter.FormulationID)
+	}
+
+	if filter.ShapeID != 0 {
+		q.where("f.shape_id = ?", filter.ShapeID)
+	}
+
+	return q
+}
+
+func (s *SQL) CountMedicines(ctx context.Context, filter MedicineFilter) (int64, error) {
+	q := medicineQuery(filter)
+	query, args := q.count(medicineFrom)
+	return count(ctx, s.db, query, args...)
+}
+
+func (s *SQL) ListMedicines(ctx context.Context, filter MedicineFilter, sort []SortField, offset, limit int) ([]model.Medicine, error) {
+	q := medicineQuery(filter)
+	return s.listMedicines(ctx, q, sort, offset, limit)
+}
+
+func (s *SQL) ListMedicinesAfter(ctx context.Context, filter MedicineFilter, after Cursor, limit int) ([]model.Medicine, error) {
+	q := medicineQuery(filter)
+
+	if after.ID != 0 {
+		name := s.dialect.fold("m.name")
+		q.where(name+" > ? OR ("+name+" = ? AND m.id > ?)", after.Key, after.Key, after.ID)
+	}
+
+	return s.listMedicines(ctx, q, nil, 0, limit)
+}
+
+func (s *SQL) listMedicines(ctx context.Context, q listQuery, sort []SortField, offset, limit int) ([]model.Medicine, error) {
+	if err := q.orderBy(s.dialect, sort, defaultMedicineSort, medicineColumns); err != nil {
+		return nil, err
+	}
+
+	query, args := q.list(s.dialect,
+		"SELECT m.id, f.id, s.id, s.description, u.id, u.symbol, u.description, m.name, m.dose "+medicineFrom,
+		offset, limit)
+	return queryAll(ctx, s.db, scanMedicine, query, args...)
+}
+
+func (s *SQL) CreateMedicine(ctx context.Context, medicine *model.Medicine) error {
+	id, err := s.dialect.insert(ctx, s.db,
+		"INSERT INTO medicine (formulation_id, name, dose) VALUES (?, ?, ?)",
+		medicine.FormulationObj.ID, medicine.Name, medicine.Dose)
+
+	if err != nil {
+		return s.reference(err)
+	}
+
+	medicine.ID = id
+	return nil
+}
+
+//--------------------------------------
+// Users
+//--------------------------------------
+
+func scanUser(row scanner) (model.User, error) {
+	var user model.User
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role)
+	return user, err
+}
+
+func (s *SQL) getUser(ctx context.Context, where string, arg any) (model.User, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, username, password_hash, role FROM app_user WHERE "+where, arg)
+
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+
+	return user, err
+}
+
+func (s *SQL) GetUser(ctx context.Context, id int64) (model.User, error) {
+	return s.getUser(ctx, "id = ?", id)
+}
+
+func (s *SQL) GetUserByUsername(ctx context.Context, username string) (model.User, error) {
+	return s.getUser(ctx, "username = ?", username)
+}
+
+func (s *SQL) CreateUser(ctx context.Context, user *model.User) error {
+	id, err := s.dialect.insert(ctx, s.db,
+		"INSERT INTO app_user (username, password_hash, role) VALUES (?, ?, ?)",
+		user.Username, user.PasswordHash, user.Role)
+
+	if s.dialect.isDuplicate(err) {
+		return ErrConflict
+	}
+
+	if err != nil {
+		return err
+	}
+
+	user.ID = id
+	return nil
+}
+
+//--------------------------------------
+// Audit
+//--------------------------------------
+
+func nullInt(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func auditWhere(filter AuditFilter) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+
+	if filter.PatientID != 0 {
+		conditions = append(conditions, "patient_id = ?")
+		args = append(args, filter.PatientID)
+	}
+
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC().Format(timeLayout))
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.To.UTC().Format(timeLayout))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND ") + " ", args
+}
+
+func (s *SQL) AppendAudit(ctx context.Context, entry *model.AuditEntry) error {
+	id, err := s.dialect.insert(ctx, s.db,
+		`INSERT INTO audit_log (user_id, username, action, resource_type, resource_id, patient_id, created_at, client_ip, status, outcome)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullInt(entry.UserID), entry.Username, entry.Action, entry.ResourceType,
+		nullInt(entry.ResourceID), nullInt(entry.PatientID), entry.Time.UTC().Format(timeLayout),
+		entry.ClientIP, entry.Status, entry.Outcome)
+
+	if err != nil {
+		return err
+	}
+
+	entry.ID = id
+	return nil
+}
+
+func (s *SQL) CountAudit(ctx context.Context, filter AuditFilter) (int64, error) {
+	where, args := auditWhere(filter)
+	return count(ctx, s.db, "SELECT COUNT(id) AS total FROM audit_log "+where, args...)
+}
+
+func (s *SQL) ListAudit(ctx context.Context, filter AuditFilter, offset, limit int) ([]model.AuditEntry, error) {
+	where, args := auditWhere(filter)
+	limitClause, limitArgs := s.dialect.limit(offset, limit)
+
+	return queryAll(ctx, s.db,
+		func(row scanner) (model.AuditEntry, error) {
+			var entry model.AuditEntry
+			var userID, resourceID, patientID sql.NullInt64
+			var createdAt string
+
+			if err := row.Scan(
+				&entry.ID, &userID, &entry.Username, &entry.Action, &entry.ResourceType,
+				&resourceID, &patientID, &createdAt, &entry.ClientIP, &entry.Status,
+				&entry.Outcome); err != nil {
+				return entry, err
+			}
+
+			entry.UserID = userID.Int64
+			entry.ResourceID = resourceID.Int64
+			entry.PatientID = patientID.Int64
+
+			var err error
+			entry.Time, err = time.Parse(timeLayout, createdAt)
+			return entry, err
+		},
+		`SELECT id, user_id, username, action, resource_type, resource_id, patient_id, created_at, client_ip, status, outcome
+		FROM audit_log
+		`+where+`
+		ORDER BY created_at DESC, id DESC
+		`+limitClause, append(args, limitArgs...)...)
+}
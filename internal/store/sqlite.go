@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) limit(offset, limit int) (string, []any) {
+	return "LIMIT ? OFFSET ?", []any{limit, offset}
+}
+
+// insert relies on RETURNING rather than LastInsertId, which is only
+// reliable while no other statement runs on the same connection.
+func (sqliteDialect) insert(ctx context.Context, db execer, query string, args ...any) (int64, error) {
+	var id int64
+
+	if err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (sqliteDialect) isDuplicate(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+func (sqliteDialect) isForeignKey(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+// fold compares the column with NOCASE rather than the default BINARY
+// collation. NOCASE only folds ASCII letters, so "Ñ" and "ñ" still differ.
+func (sqliteDialect) fold(column string) string {
+	return column + " COLLATE NOCASE"
+}
+
+// OpenSQLite opens (creating it if needed) the SQLite database at path
+// with foreign keys enabled. The schema is created by the migrate
+// subcommand.
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; sharing one connection avoids
+	// "database is locked" errors between concurrent transactions.
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// NewSQLite returns a Store using a handle returned by OpenSQLite.
+func NewSQLite(db *sql.DB) *SQL {
+	return &SQL{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect{}}
+}
@@ -0,0 +1,243 @@
+// Package store defines the data access layer used by the api-server
+// handlers. Handlers only talk to the Store interface so the backing
+// database can be swapped without touching them.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jctorrestone/web-service-mr/internal/model"
+)
+
+var (
+	// ErrNotFound is returned when a single requested row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a row would violate a uniqueness
+	// constraint.
+	ErrConflict = errors.New("already exists")
+	// ErrInvalidReference is returned when a row refers to another one
+	// that does not exist.
+	ErrInvalidReference = errors.New("invalid reference")
+)
+
+// Store groups every repository the api-server needs.
+type Store interface {
+	PatientStore
+	RecordStore
+	CatalogStore
+	MedicineStore
+	UserStore
+	AuditStore
+
+	// Ping reports whether the backend can serve requests.
+	Ping(ctx context.Context) error
+	// Close releases the backend, waiting for the queries in progress.
+	Close() error
+}
+
+// PatientStore gives access to the patient table. Soft deleted patients
+// are never returned.
+type PatientStore interface {
+	CountPatients(ctx context.Context, filter PatientFilter) (int64, error)
+	// ListPatients sorts by PatientSortFields, by last name if sort is
+	// empty.
+	ListPatients(ctx context.Context, filter PatientFilter, sort []SortField, offset, limit int) ([]model.Patient, error)
+	// ListPatientsAfter returns the patients following after in last name
+	// order.
+	ListPatientsAfter(ctx context.Context, filter PatientFilter, after Cursor, limit int) ([]model.Patient, error)
+	GetPatient(ctx context.Context, id int64) (model.Patient, error)
+	CreatePatient(ctx context.Context, patient *model.Patient) error
+	// UpdatePatient overwrites the patient with the same id.
+	UpdatePatient(ctx context.Context, patient model.Patient) error
+	// DeletePatient soft deletes a patient. It returns ErrConflict if any
+	// medical record references the patient.
+	DeletePatient(ctx context.Context, id int64) error
+}
+
+// RecordStore gives access to primary and secondary medical records and
+// all of their child collections.
+type RecordStore interface {
+	CountRecords(ctx context.Context, filter RecordFilter) (int64, error)
+	// ListRecords sorts by RecordSortFields, newest first if sort is
+	// empty.
+	ListRecords(ctx context.Context, filter RecordFilter, sort []SortField, offset, limit int) ([]model.Record, error)
+	// ListRecordsAfter returns the records following after, newest first.
+	ListRecordsAfter(ctx context.Context, filter RecordFilter, after Cursor, limit int) ([]model.Record, error)
+	// GetRecord returns a primary or secondary record. A secondary record
+	// has the patient of its primary record and no measurements.
+	GetRecord(ctx context.Context, id int64) (model.FullRecord, error)
+	ListSecondaryRecords(ctx context.Context, primaryID int64) ([]model.FullRecord, error)
+	// InvalidReferences returns every row fullRecord refers to that does
+	// not exist, so all of them can be reported at once.
+	InvalidReferences(ctx context.Context, fullRecord model.FullRecord) ([]Reference, error)
+	// CreateRecord stores the record and its child collections in a single
+	// transaction and sets fullRecord.RecordObj.ID.
+	CreateRecord(ctx context.Context, fullRecord *model.FullRecord) error
+	// UpdateRecord replaces the date, measurements and child collections
+	// of the record fullRecord.RecordObj.ID in a single transaction,
+	// keeping the previous content as a version amended by userID. The
+	// category, patient and primary record cannot change and are copied
+	// back into fullRecord along with the new version number. Secondary
+	// records have no measurements, those of fullRecord are ignored. A
+	// non-zero RecordObj.Version must match the current version, otherwise
+	// ErrConflict is returned.
+	UpdateRecord(ctx context.Context, fullRecord *model.FullRecord, userID int64) error
+	// ListRecordVersions returns every version of a record, oldest first
+	// and ending with the current one.
+	ListRecordVersions(ctx context.Context, id int64) ([]model.RecordVersion, error)
+	// GetRecordVersion returns a record as it was at version.
+	GetRecordVersion(ctx context.Context, id int64, version int) (model.FullRecord, error)
+}
+
+// CatalogStore gives access to diseases, symptoms, exams and vital signs.
+// An empty symptom description matches every row.
+type CatalogStore interface {
+	CountDiseases(ctx context.Context, filter DiseaseFilter) (int64, error)
+	// ListDiseases sorts by DiseaseSortFields, by description if sort is
+	// empty.
+	ListDiseases(ctx context.Context, filter DiseaseFilter, sort []SortField, offset, limit int) ([]model.Disease, error)
+	// CreateDisease returns ErrConflict if another disease has its code.
+	CreateDisease(ctx context.Context, disease *model.Disease) error
+	// ImportDiseases creates the diseases whose code is not in use yet and
+	// updates the description of the others. Either every disease is
+	// imported or none is.
+	ImportDiseases(ctx context.Context, diseases []model.Disease) (created, updated int, err error)
+	CountSymptoms(ctx context.Context, description string) (int64, error)
+	ListSymptoms(ctx context.Context, description string, offset, limit int) ([]model.Symptom, error)
+	CreateSymptom(ctx context.Context, symptom *model.Symptom) error
+	ListExams(ctx context.Context) ([]model.Exam, error)
+	// ListVitalSigns returns every vital sign along with its reference
+	// range, if it has one.
+	ListVitalSigns(ctx context.Context) ([]model.VitalSign, error)
+	// SetVitalSignRange replaces the reference range of a vital sign. A
+	// nil vitalSignRange removes it.
+	SetVitalSignRange(ctx context.Context, id int64, vitalSignRange *model.VitalSignRange) error
+	// CreateCatalogBatch creates every row of batch and sets their ids, or
+	// none of them if one fails. It returns ErrConflict if a disease code
+	// is taken and ErrInvalidReference if a medicine formulation does not
+	// exist.
+	CreateCatalogBatch(ctx context.Context, batch *CatalogBatch) error
+}
+
+// MedicineStore gives access to medicines and their formulations.
+type MedicineStore interface {
+	ListFormulations(ctx context.Context) ([]model.Formulation, error)
+	CountMedicines(ctx context.Context, filter MedicineFilter) (int64, error)
+	// ListMedicines sorts by MedicineSortFields, by name if sort is empty.
+	ListMedicines(ctx context.Context, filter MedicineFilter, sort []SortField, offset, limit int) ([]model.Medicine, error)
+	// ListMedicinesAfter returns the medicines following after in name
+	// order.
+	ListMedicinesAfter(ctx context.Context, filter MedicineFilter, after Cursor, limit int) ([]model.Medicine, error)
+	CreateMedicine(ctx context.Context, medicine *model.Medicine) error
+}
+
+// UserStore gives access to the accounts allowed to use the api-server.
+type UserStore interface {
+	GetUser(ctx context.Context, id int64) (model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (model.User, error)
+	// CreateUser returns ErrConflict if the username is taken.
+	CreateUser(ctx context.Context, user *model.User) error
+}
+
+// PatientFilter narrows down patient listings. Zero fields match every
+// patient; Name matches part of the first or last name.
+type PatientFilter struct {
+	Name   string
+	Gender *bool
+}
+
+// RecordFilter narrows down record listings. Zero fields match every
+// record and the others must all match. From and To are inclusive
+// YYYY-MM-DD dates.
+type RecordFilter struct {
+	// Category is "primary" or "secondary". Secondary records are listed
+	// with the patient of their primary record.
+	Category string
+	From     string
+	To       string
+	// PatientName matches part of the first or last name of the patient,
+	// FirstName and LastName part of either one.
+	PatientName string
+	FirstName   string
+	LastName    string
+	PatientID   int64
+	// DiseaseID matches the records diagnosing the disease.
+	DiseaseID int64
+}
+
+// CatalogBatch holds catalog rows created together.
+type CatalogBatch struct {
+	Diseases  []model.Disease
+	Symptoms  []model.Symptom
+	Exams     []model.Exam
+	Medicines []model.Medicine
+}
+
+// DiseaseFilter narrows down disease listings. Zero fields match every
+// disease and the others must all match.
+type DiseaseFilter struct {
+	// Description matches part of the description.
+	Description string
+	// CodePrefix matches the ICD-10 codes starting with it.
+	CodePrefix string
+	// Chapter matches the diseases coded in the ICD-10 chapter numbered
+	// Chapter.
+	Chapter int
+}
+
+// MedicineFilter narrows down medicine listings. Zero fields match every
+// medicine; Name matches part of the name.
+type MedicineFilter struct {
+	Name          string
+	FormulationID int64
+	ShapeID       int64
+}
+
+// SortField orders a listing by one of its sortable fields.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// The fields each listing can be sorted by.
+var (
+	PatientSortFields  = []string{"id", "name", "last_name", "gender"}
+	RecordSortFields   = []string{"id", "rdate", "duration", "last_name"}
+	MedicineSortFields = []string{"id", "name", "dose"}
+	DiseaseSortFields  = []string{"id", "code", "description"}
+)
+
+// Cursor marks the position of a row in a keyset paginated listing: its
+// sort key (last name, record date or medicine name) and id, which breaks
+// ties. The zero Cursor starts at the first row.
+type Cursor struct {
+	Key string
+	ID  int64
+}
+
+// Reference is a row a medical record refers to. Field is the JSON path
+// of the referring value and What names the kind of row, e.g. "patient".
+type Reference struct {
+	Field string
+	What  string
+	ID    int64
+}
+
+// AuditFilter narrows down audit log queries. Zero fields match every
+// entry; From and To are inclusive.
+type AuditFilter struct {
+	PatientID int64
+	UserID    int64
+	From      time.Time
+	To        time.Time
+}
+
+// AuditStore gives append-only access to the audit log. Entries are
+// listed newest first.
+type AuditStore interface {
+	AppendAudit(ctx context.Context, entry *model.AuditEntry) error
+	CountAudit(ctx context.Context, filter AuditFilter) (int64, error)
+	ListAudit(ctx context.Context, filter AuditFilter, offset, limit int) ([]model.AuditEntry, error)
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts a span per SQL statement, under the span of the request.
+var tracer = otel.Tracer("github.com/jctorrestone/web-service-mr/internal/store")
+
+// tracedDB is a *sql.DB tracing the statements it runs, and those of its
+// transactions. A span lasts until the statement returns its first
+// results, reading the rows is not included.
+type tracedDB struct {
+	*sql.DB
+	// system is the database kind, mysql or sqlite.
+	system string
+}
+
+func (db tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := db.start(ctx, query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	end(span, err)
+
+	return rows, err
+}
+
+func (db tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := db.start(ctx, query)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	end(span, row.Err())
+
+	return row
+}
+
+func (db tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := db.start(ctx, query)
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	end(span, err)
+
+	return result, err
+}
+
+func (db tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tracedTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	return tracedTx{Tx: tx, system: db.system}, err
+}
+
+func (db tracedDB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	return startStatement(ctx, db.system, query)
+}
+
+// tracedTx is a *sql.Tx tracing its statements like tracedDB.
+type tracedTx struct {
+	*sql.Tx
+	system string
+}
+
+func (tx tracedTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, tx.system, query)
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	end(span, err)
+
+	return rows, err
+}
+
+func (tx tracedTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(ctx, tx.system, query)
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	end(span, row.Err())
+
+	return row
+}
+
+func (tx tracedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, tx.system, query)
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	end(span, err)
+
+	return result, err
+}
+
+// statementTable matches the table a statement is about: the first one
+// read from or written to.
+var statementTable = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE)\s+([a-z_][a-z0-9_]*)`)
+
+// startStatement starts the span of query, named after the statement it
+// runs, e.g. "SELECT disease_history". The query text only holds
+// placeholders; its arguments, which may be patient data, are left out.
+func startStatement(ctx context.Context, system, query string) (context.Context, trace.Span) {
+	statement := strings.Join(strings.Fields(query), " ")
+
+	operation, _, _ := strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+
+	name := operation
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", system),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", statement),
+	}
+	if m := statementTable.FindStringSubmatch(statement); m != nil {
+		name += " " + m[1]
+		attrs = append(attrs, attribute.String("db.sql.table", m[1]))
+	}
+
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// end ends span, marking it failed if err is. Only the type of the error
+// is recorded: database errors may quote the values of a row.
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.SetAttributes(attribute.String("error.type", fmt.Sprintf("%T", err)))
+		span.SetStatus(codes.Error, "statement failed")
+	}
+
+	span.End()
+}